@@ -0,0 +1,760 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mhmdibrahimm/goautogui/mcp"
+	"github.com/mhmdibrahimm/goautogui/script"
+	"github.com/mhmdibrahimm/goautogui/server"
+	"github.com/mhmdibrahimm/goautogui/vnc"
+	goautogui "github.com/mhmdibrahimm/goautogui/windows"
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (p point) String() string {
+	return fmt.Sprintf("%d %d", p.X, p.Y)
+}
+
+func cursor() point {
+	p := goautogui.Position()
+	return point{p.X, p.Y}
+}
+
+type rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func toRect(r image.Rectangle) rect {
+	return rect{r.Min.X, r.Min.Y, r.Dx(), r.Dy()}
+}
+
+func (r rect) String() string {
+	return fmt.Sprintf("%d %d %d %d", r.X, r.Y, r.Width, r.Height)
+}
+
+// regionFlag is a region given as x,y,width,height.
+type regionFlag image.Rectangle
+
+func (r *regionFlag) String() string {
+	return toRect(image.Rectangle(*r)).String()
+}
+
+func (r *regionFlag) Set(s string) error {
+	n, err := intList(s, 4)
+	if err != nil {
+		return err
+	}
+	*r = regionFlag(image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]))
+	return nil
+}
+
+// pointFlag is a point given as x,y. set reports whether the flag was given.
+type pointFlag struct {
+	image.Point
+	set bool
+}
+
+func (p *pointFlag) String() string {
+	return fmt.Sprintf("%d,%d", p.X, p.Y)
+}
+
+func (p *pointFlag) Set(s string) error {
+	n, err := intList(s, 2)
+	if err != nil {
+		return err
+	}
+	p.Point, p.set = image.Pt(n[0], n[1]), true
+	return nil
+}
+
+var buttons = map[string]goautogui.MouseButton{
+	"left":   goautogui.MouseLeftButton,
+	"right":  goautogui.MouseRightButton,
+	"middle": goautogui.MouseMiddleButton,
+	"x1":     goautogui.MouseX1Button,
+	"x2":     goautogui.MouseX2Button,
+}
+
+func button(name string) (goautogui.MouseButton, error) {
+	b, ok := buttons[name]
+	if !ok {
+		return 0, usagef("unknown mouse button %q", name)
+	}
+	return b, nil
+}
+
+func key(name string) (goautogui.KeyboardKeys, error) {
+	k, ok := goautogui.KeyByName(name)
+	if !ok {
+		return 0, usagef("unknown key %q", name)
+	}
+	return k, nil
+}
+
+// perform runs actions with a Player, returning the error of the failed action rather
+// than a goautogui.StepError.
+func perform(ctx context.Context, g globals, actions ...goautogui.Action) error {
+	return performWith(ctx, newPlayer(g), actions...)
+}
+
+// performWith is like perform but runs the actions with player.
+func performWith(ctx context.Context, player *goautogui.Player, actions ...goautogui.Action) error {
+	_, err := player.Run(ctx, actions)
+	var stepErr *goautogui.StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Err
+	}
+	return err
+}
+
+func newPlayer(g globals, opts ...goautogui.PlayerOption) *goautogui.Player {
+	if g.failSafe {
+		opts = append(opts, goautogui.WithFailSafe())
+	}
+	return goautogui.NewPlayer(opts...)
+}
+
+func cmdPosition(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("position", flag.ContinueOnError)
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	return cursor(), nil
+}
+
+func cmdMove(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("move", flag.ContinueOnError)
+	duration := fs.Duration("duration", 0, "glide to the point over this duration")
+	pos, err := parse(fs, args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	n, err := ints(pos...)
+	if err != nil {
+		return nil, err
+	}
+	err = perform(ctx, g, goautogui.Action{Kind: goautogui.ActionMove, Point: image.Pt(n[0], n[1]), Duration: *duration})
+	if err != nil {
+		return nil, err
+	}
+	return cursor(), nil
+}
+
+func cmdClick(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("click", flag.ContinueOnError)
+	buttonName := fs.String("button", "left", "left, right, middle, x1 or x2")
+	clicks := fs.Int("clicks", 1, "number of clicks")
+	duration := fs.Duration("duration", 0, "glide to the point over this duration")
+	pos, err := parse(fs, args, 0, 2)
+	if err != nil {
+		return nil, err
+	}
+	b, err := button(*buttonName)
+	if err != nil {
+		return nil, err
+	}
+	p := cursor()
+	switch len(pos) {
+	case 1:
+		return nil, usagef("click: want both x and y")
+	case 2:
+		n, err := ints(pos...)
+		if err != nil {
+			return nil, err
+		}
+		p = point{n[0], n[1]}
+	}
+	err = perform(ctx, g, goautogui.Action{Kind: goautogui.ActionClick, Point: image.Pt(p.X, p.Y), Button: b, Clicks: *clicks, Duration: *duration})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func cmdDrag(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("drag", flag.ContinueOnError)
+	buttonName := fs.String("button", "left", "left, right, middle, x1 or x2")
+	var from pointFlag
+	fs.Var(&from, "from", "start at x,y instead of the cursor")
+	duration := fs.Duration("duration", 0, "drag over this duration")
+	pos, err := parse(fs, args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	n, err := ints(pos...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := button(*buttonName)
+	if err != nil {
+		return nil, err
+	}
+	a := goautogui.Action{Kind: goautogui.ActionDrag, Point: image.Pt(n[0], n[1]), Button: b, Duration: *duration}
+	if from.set {
+		a.Path = []image.Point{from.Point, a.Point}
+	}
+	if err := perform(ctx, g, a); err != nil {
+		return nil, err
+	}
+	return cursor(), nil
+}
+
+func cmdScroll(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("scroll", flag.ContinueOnError)
+	var at pointFlag
+	fs.Var(&at, "at", "scroll at x,y instead of the cursor")
+	horizontal := fs.Bool("horizontal", false, "scroll sideways, positive notches right")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	notches, err := strconv.ParseFloat(pos[0], 64)
+	if err != nil {
+		return nil, usagef("%q is not a number of notches", pos[0])
+	}
+	if !at.set {
+		c := cursor()
+		at.Point = image.Pt(c.X, c.Y)
+	}
+	return nil, perform(ctx, g, goautogui.Action{Kind: goautogui.ActionScroll, Point: at.Point, Amount: notches, Horizontal: *horizontal})
+}
+
+func cmdType(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("type", flag.ContinueOnError)
+	interval := fs.Duration("interval", 0, "pause between characters")
+	pos, err := parse(fs, args, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	return nil, perform(ctx, g, goautogui.Action{Kind: goautogui.ActionType, Text: strings.Join(pos, " "), Duration: *interval})
+}
+
+func cmdPress(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("press", flag.ContinueOnError)
+	presses := fs.Int("presses", 1, "number of presses")
+	interval := fs.Duration("interval", 0, "pause between presses")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	k, err := key(pos[0])
+	if err != nil {
+		return nil, err
+	}
+	if *presses < 0 {
+		return nil, usagef("--presses %d is negative", *presses)
+	}
+	// One press at a time, so a large --presses does not build a long list of actions
+	player := newPlayer(g)
+	for i := range *presses {
+		actions := []goautogui.Action{{Kind: goautogui.ActionKey, Key: k}}
+		if i > 0 && *interval > 0 {
+			actions = append([]goautogui.Action{{Kind: goautogui.ActionWait, Duration: *interval}}, actions...)
+		}
+		if err := performWith(ctx, player, actions...); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func cmdHotkey(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("hotkey", flag.ContinueOnError)
+	pos, err := parse(fs, args, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	var keys []goautogui.KeyboardKeys
+	for _, arg := range pos {
+		names := []string{arg}
+		if arg != "+" {
+			names = strings.Split(arg, "+")
+		}
+		for _, name := range names {
+			k, err := key(name)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		}
+	}
+	return nil, perform(ctx, g, goautogui.Action{Kind: goautogui.ActionHotkey, Keys: keys})
+}
+
+type screenshotResult struct {
+	File string `json:"file"`
+	rect
+}
+
+func (r screenshotResult) String() string {
+	return r.File
+}
+
+func cmdScreenshot(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("screenshot", flag.ContinueOnError)
+	var region regionFlag
+	fs.Var(&region, "region", "capture x,y,width,height instead of the primary display")
+	display := fs.Int("display", -1, "capture the display with this index; 0 is the primary display")
+	out := fs.String("out", "", "file to save, .png, .jpg, .bmp or .gif (default screenshot-<time>.png)")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	r := image.Rectangle(region)
+	if *display >= 0 {
+		if !r.Empty() {
+			return nil, usagef("screenshot: give --region or --display, not both")
+		}
+		if r = goautogui.GetDisplayBounds(*display); r.Empty() {
+			return nil, usagef("screenshot: there is no display %d", *display)
+		}
+	}
+	if r.Empty() {
+		screen := goautogui.GetScreenDimensions()
+		r = image.Rect(0, 0, screen.X, screen.Y)
+	}
+	if *out == "" {
+		*out = time.Now().Format("screenshot-20060102-150405.png")
+	}
+	if _, err := goautogui.Screenshot(r, *out); err != nil {
+		return nil, err
+	}
+	return screenshotResult{*out, toRect(r)}, nil
+}
+
+type locateResult struct {
+	rect
+	CenterX int `json:"centerX"`
+	CenterY int `json:"centerY"`
+}
+
+func cmdLocate(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("locate", flag.ContinueOnError)
+	var region regionFlag
+	fs.Var(&region, "region", "search x,y,width,height instead of the primary display")
+	tolerance := fs.Uint("tolerance", 0, "how much each colour channel may differ, 0 to 255")
+	wait := fs.Duration("wait", 0, "keep looking for this long until the image appears")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if *tolerance > 255 {
+		return nil, usagef("locate: tolerance %d is outside 0 to 255", *tolerance)
+	}
+	needle, err := loadImage(pos[0])
+	if err != nil {
+		return nil, err
+	}
+	r := image.Rectangle(region)
+	if r.Empty() {
+		screen := goautogui.GetScreenDimensions()
+		r = image.Rect(0, 0, screen.X, screen.Y)
+	}
+
+	deadline := time.Now().Add(*wait)
+	for {
+		found, err := goautogui.LocateOnScreen(r, needle, uint8(*tolerance))
+		if err == nil {
+			c := found.Min.Add(found.Max).Div(2)
+			return locateResult{toRect(found), c.X, c.Y}, nil
+		}
+		if !errors.Is(err, goautogui.ErrImageNotFound) {
+			return nil, err
+		}
+		if *wait == 0 {
+			return nil, fmt.Errorf("%s: %w", pos[0], err)
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%s: %w: %w after %v", pos[0], goautogui.ErrWaitTimeout, goautogui.ErrImageNotFound, *wait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func loadImage(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %v", file, err)
+	}
+	return img, nil
+}
+
+type pixelResult struct {
+	point
+	R   uint8  `json:"r"`
+	G   uint8  `json:"g"`
+	B   uint8  `json:"b"`
+	Hex string `json:"hex"`
+}
+
+func (p pixelResult) String() string {
+	return fmt.Sprintf("%d %d %d %s", p.R, p.G, p.B, p.Hex)
+}
+
+func cmdPixel(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("pixel", flag.ContinueOnError)
+	pos, err := parse(fs, args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	n, err := ints(pos...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := goautogui.Pixel(n[0], n[1])
+	if err != nil {
+		return nil, err
+	}
+	return pixelResult{point{n[0], n[1]}, c.R, c.G, c.B, fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)}, nil
+}
+
+type display struct {
+	Index int `json:"index"`
+	rect
+	Primary bool `json:"primary"`
+}
+
+type displayList []display
+
+func (l displayList) String() string {
+	lines := make([]string, len(l))
+	for i, d := range l {
+		lines[i] = fmt.Sprintf("%d %v", d.Index, d.rect)
+		if d.Primary {
+			lines[i] += " primary"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func cmdDisplays(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("displays", flag.ContinueOnError)
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	list := displayList{}
+	for i, r := range goautogui.GetAllDisplayBounds() {
+		list = append(list, display{i, toRect(r), i == 0})
+	}
+	return list, nil
+}
+
+type windowInfo struct {
+	Hwnd  uintptr `json:"hwnd"`
+	Title string  `json:"title"`
+	Class string  `json:"class"`
+	PID   uint32  `json:"pid"`
+	rect
+	Minimized  bool `json:"minimized"`
+	Foreground bool `json:"foreground"`
+}
+
+func toWindowInfo(w goautogui.Window) windowInfo {
+	return windowInfo{uintptr(w.Hwnd), w.Title, w.Class, w.PID, toRect(w.Bounds), w.Minimized, w.Foreground}
+}
+
+func (w windowInfo) String() string {
+	return fmt.Sprintf("%#x\t%d\t%s", w.Hwnd, w.PID, w.Title)
+}
+
+type windowList []windowInfo
+
+func (l windowList) String() string {
+	lines := make([]string, len(l))
+	for i, w := range l {
+		lines[i] = w.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func cmdWindows(ctx context.Context, g globals, args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, usagef("windows: want list or activate")
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("windows list", flag.ContinueOnError)
+		title := fs.String("title", "", "only windows whose title contains this text, ignoring case")
+		if _, err := parse(fs, args[1:], 0, 0); err != nil {
+			return nil, err
+		}
+		list := windowList{}
+		for _, w := range goautogui.FindWindows(*title) {
+			list = append(list, toWindowInfo(w))
+		}
+		return list, nil
+
+	case "activate":
+		fs := flag.NewFlagSet("windows activate", flag.ContinueOnError)
+		pos, err := parse(fs, args[1:], 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		w, err := findWindow(pos[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := goautogui.ActivateWindow(w.Hwnd); err != nil {
+			return nil, err
+		}
+		w.Foreground, w.Minimized = true, false
+		return toWindowInfo(w), nil
+	}
+	return nil, usagef("windows: unknown subcommand %q", args[0])
+}
+
+// findWindow returns the window with the handle given as a number, or else the frontmost
+// window whose title contains s.
+func findWindow(s string) (goautogui.Window, error) {
+	if n, err := strconv.ParseUint(s, 0, 64); err == nil {
+		for _, w := range goautogui.ListWindows() {
+			if w.Hwnd == win32.HWND(n) {
+				return w, nil
+			}
+		}
+	}
+	found := goautogui.FindWindows(s)
+	if len(found) == 0 {
+		return goautogui.Window{}, fmt.Errorf("%w: %q", goautogui.ErrWindowNotFound, s)
+	}
+	return found[0], nil
+}
+
+type recordResult struct {
+	File     string  `json:"file"`
+	Events   int     `json:"events"`
+	Duration float64 `json:"duration"` // in seconds
+}
+
+func (r recordResult) String() string {
+	return fmt.Sprintf("recorded %d events in %.1fs to %s", r.Events, r.Duration, r.File)
+}
+
+func cmdRecord(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	out := fs.String("out", "recording.json", "file to save the recording to")
+	duration := fs.Duration("duration", 0, "stop after this long instead of at Ctrl+C")
+	thumbnails := fs.Int("thumbnails", 0, "save a thumbnail of this size around each click")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	var opts []goautogui.RecorderOption
+	if *thumbnails > 0 {
+		opts = append(opts, goautogui.WithThumbnails(*thumbnails))
+	}
+	rec := goautogui.NewRecorder(opts...)
+	if err := rec.Start(); err != nil {
+		return nil, err
+	}
+	if *duration > 0 {
+		fmt.Fprintf(os.Stderr, "Recording for %v; press Ctrl+C to stop early.\n", *duration)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	} else {
+		fmt.Fprintln(os.Stderr, "Recording; press Ctrl+C to stop.")
+	}
+	<-ctx.Done()
+	recording, err := rec.Stop()
+	if err != nil {
+		return nil, err
+	}
+	trimCtrlC(recording)
+	if err := recording.Save(*out); err != nil {
+		return nil, err
+	}
+	var length time.Duration
+	if n := len(recording.Events); n > 0 {
+		length = recording.Events[n-1].Time
+	}
+	return recordResult{*out, len(recording.Events), length.Seconds()}, nil
+}
+
+// trimCtrlC drops the key events of the Ctrl+C that stopped the recording.
+func trimCtrlC(r *goautogui.Recording) {
+	for n := len(r.Events); n > 0; n-- {
+		e := r.Events[n-1]
+		isKey := e.Kind == goautogui.EventKeyDown || e.Kind == goautogui.EventKeyUp
+		switch {
+		case isKey && (e.Key == goautogui.KEY_CONTROL || e.Key == goautogui.KEY_LCONTROL || e.Key == goautogui.KEY_RCONTROL || e.Key == 'C'):
+			r.Events = r.Events[:n-1]
+		default:
+			return
+		}
+	}
+}
+
+type playResult struct {
+	File  string              `json:"file"`
+	Steps int                 `json:"steps,omitempty"`
+	Log   []goautogui.StepLog `json:"log,omitempty"`
+}
+
+func (r playResult) String() string {
+	if r.Log == nil {
+		return "played " + r.File
+	}
+	return fmt.Sprintf("played %d steps of %s", r.Steps, r.File)
+}
+
+func cmdPlay(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("play", flag.ContinueOnError)
+	speed := fs.Float64("speed", 1, fmt.Sprintf("playback speed, %v to %v", goautogui.MinPlaybackSpeed, goautogui.MaxPlaybackSpeed))
+	smoothing := fs.Duration("smoothing", 0, "glide to each point over this duration")
+	retries := fs.Int("retries", 0, "retry failed steps this many times")
+	step := fs.Bool("step", false, "pause before each step")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if *speed < goautogui.MinPlaybackSpeed || *speed > goautogui.MaxPlaybackSpeed {
+		return nil, usagef("play: speed %g is outside %v to %v", *speed, goautogui.MinPlaybackSpeed, goautogui.MaxPlaybackSpeed)
+	}
+	opts := []goautogui.PlayerOption{goautogui.WithSpeed(*speed), goautogui.WithSmoothing(*smoothing), goautogui.WithRetries(*retries, 500*time.Millisecond)}
+	if *step {
+		opts = append(opts, goautogui.WithStepMode())
+	}
+	if g.failSafe {
+		opts = append(opts, goautogui.WithFailSafe())
+	}
+	file := pos[0]
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		recording, err := goautogui.LoadRecording(file)
+		if err != nil {
+			return nil, err
+		}
+		logs, err := goautogui.NewPlayer(opts...).Run(ctx, goautogui.ActionsFromRecording(recording))
+		if err != nil {
+			return nil, err
+		}
+		return playResult{File: file, Steps: len(logs), Log: logs}, nil
+	}
+
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := script.Parse(file, src)
+	if err != nil {
+		return nil, err
+	}
+	// Keep stdout for the result in JSON mode.
+	output := os.Stdout
+	if g.json {
+		output = os.Stderr
+	}
+	in := script.NewInterpreter(script.NewWindowsSink(opts...), script.WithOutput(output), script.WithBaseDir(filepath.Dir(file)))
+	if err := in.Run(ctx, prog); err != nil {
+		return nil, err
+	}
+	return playResult{File: file}, nil
+}
+
+func cmdServe(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", server.DefaultAddr, "address to listen on")
+	token := fs.String("token", os.Getenv("GOAUTOGUI_TOKEN"), "require this bearer token (default $GOAUTOGUI_TOKEN)")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long a request may take")
+	viewOnly := fs.Bool("view-only", false, "ignore mouse and keyboard events from the live view")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	var opts []goautogui.PlayerOption
+	if g.failSafe {
+		opts = append(opts, goautogui.WithFailSafe())
+	}
+	srvOpts := []server.Option{server.WithToken(*token), server.WithTimeout(*timeout)}
+	if *viewOnly {
+		srvOpts = append(srvOpts, server.WithViewOnly())
+	}
+	srv := server.New(server.NewWindowsBackend(opts...), srvOpts...)
+	fmt.Fprintf(os.Stderr, "Serving on http://%s (live view at /live); press Ctrl+C to stop.\n", *addr)
+	if err := srv.ListenAndServe(ctx, *addr); !errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func cmdVNC(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("vnc", flag.ContinueOnError)
+	addr := fs.String("addr", vnc.DefaultAddr, "address to listen on")
+	password := fs.String("password", os.Getenv("GOAUTOGUI_VNC_PASSWORD"), "require this password (default $GOAUTOGUI_VNC_PASSWORD)")
+	display := fs.Int("display", 0, "display to serve")
+	fps := fs.Float64("fps", 10, "captures per second while a viewer waits for changes")
+	viewOnly := fs.Bool("view-only", false, "ignore the mouse and keyboard of viewers")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	if *fps <= 0 {
+		return nil, usagef("vnc: --fps must be positive")
+	}
+	var opts []goautogui.PlayerOption
+	if g.failSafe {
+		opts = append(opts, goautogui.WithFailSafe())
+	}
+	vncOpts := []vnc.Option{vnc.WithPassword(*password), vnc.WithDisplay(*display), vnc.WithFPS(*fps)}
+	if *viewOnly {
+		vncOpts = append(vncOpts, vnc.WithViewOnly())
+	}
+	fmt.Fprintf(os.Stderr, "Serving VNC on %s; press Ctrl+C to stop.\n", *addr)
+	if err := vnc.New(server.NewWindowsBackend(opts...), vncOpts...).ListenAndServe(ctx, *addr); !errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func cmdMCP(ctx context.Context, g globals, args []string) (any, error) {
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	allow := fs.String("allow", "", "comma-separated tools to offer (default all): "+strings.Join(mcp.ToolNames(), ","))
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	var opts []mcp.Option
+	if *allow != "" {
+		names := strings.Split(*allow, ",")
+		for _, name := range names {
+			if !slices.Contains(mcp.ToolNames(), name) {
+				return nil, usagef("mcp: unknown tool %q", name)
+			}
+		}
+		opts = append(opts, mcp.WithAllowedTools(names...))
+	}
+	var playerOpts []goautogui.PlayerOption
+	if g.failSafe {
+		playerOpts = append(playerOpts, goautogui.WithFailSafe())
+	}
+	err := mcp.New(server.NewWindowsBackend(playerOpts...), opts...).Serve(ctx, os.Stdin, os.Stdout)
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return nil, err
+}
@@ -0,0 +1,266 @@
+// Command goautogui controls the mouse and keyboard and inspects the screen from the command
+// line, for shell scripts and programs written in other languages.
+//
+// Usage:
+//
+//	goautogui [--json] [--no-failsafe] <command> [arguments]
+//
+// Run goautogui help for the list of commands. Results are printed as text, or as a single
+// JSON value with --json; errors then go to stdout as {"error": ..., "code": ...} as well.
+//
+// Like pyautogui, commands that move the mouse or press keys stop with exit code 5 when the
+// mouse is in a corner of the primary display before they start, and play checks before
+// each step; --no-failsafe turns this off.
+//
+// Exit codes:
+//
+//	0  success
+//	1  failure
+//	2  invalid usage
+//	3  not found: an image, window or file to play
+//	4  timed out waiting for an image
+//	5  fail-safe triggered
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/mhmdibrahimm/goautogui/script"
+	goautogui "github.com/mhmdibrahimm/goautogui/windows"
+)
+
+const (
+	exitOK = iota
+	exitFailure
+	exitUsage
+	exitNotFound
+	exitTimeout
+	exitFailSafe
+)
+
+// globals are the options given before the command name.
+type globals struct {
+	json     bool
+	failSafe bool
+}
+
+type command struct {
+	name    string
+	args    string // argument synopsis
+	summary string
+	run     func(ctx context.Context, g globals, args []string) (any, error)
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because help refers to commands.
+	commands = []command{
+		{"position", "", "print the cursor position", cmdPosition},
+		{"move", "[--duration d] x y", "move the cursor", cmdMove},
+		{"click", "[--button b] [--clicks n] [--duration d] [x y]", "click, at the cursor if no point is given", cmdClick},
+		{"drag", "[--button b] [--from x,y] [--duration d] x y", "drag from the cursor, or --from, to x y", cmdDrag},
+		{"scroll", "[--at x,y] [--horizontal] notches", "scroll, positive notches up or right", cmdScroll},
+		{"type", "[--interval d] text...", "type text", cmdType},
+		{"press", "[--presses n] [--interval d] key", "press and release a key", cmdPress},
+		{"hotkey", "key... | key+key...", "press keys in order and release them in reverse", cmdHotkey},
+		{"screenshot", "[--region x,y,w,h | --display n] [--out file]", "save a screenshot", cmdScreenshot},
+		{"locate", "[--region x,y,w,h] [--tolerance n] [--wait d] image", "find an image on the screen", cmdLocate},
+		{"pixel", "x y", "print the colour of a screen pixel", cmdPixel},
+		{"displays", "", "list the displays", cmdDisplays},
+		{"windows", "list [--title text] | activate title|hwnd", "list or activate windows", cmdWindows},
+		{"record", "[--out file] [--duration d] [--thumbnails size]", "record mouse and keyboard input until Ctrl+C", cmdRecord},
+		{"play", "[--speed x] [--smoothing d] [--retries n] [--step] file", "play a recording (.json) or a script", cmdPlay},
+		{"serve", "[--addr host:port] [--token t] [--timeout d] [--view-only]", "serve the HTTP API until Ctrl+C", cmdServe},
+		{"vnc", "[--addr host:port] [--password p] [--display n] [--fps n] [--view-only]", "serve the display to VNC viewers until Ctrl+C", cmdVNC},
+		{"mcp", "[--allow tool,...]", "serve MCP tools to an AI agent on stdin and stdout", cmdMCP},
+		{"help", "", "print this help", nil},
+	}
+}
+
+// usageError is an error in the command line.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	g, rest := parseGlobals(args)
+	if len(rest) == 0 || rest[0] == "help" || rest[0] == "--help" || rest[0] == "-h" {
+		printHelp(stdout)
+		if len(rest) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == rest[0] {
+			cmd = &commands[i]
+		}
+	}
+	var result any
+	var err error
+	if cmd == nil || cmd.run == nil {
+		err = usagef("unknown command %q; run goautogui help", rest[0])
+	} else {
+		result, err = cmd.run(ctx, g, rest[1:])
+	}
+
+	if err != nil {
+		code := exitCode(err)
+		if g.json {
+			writeJSON(stdout, map[string]any{"error": err.Error(), "code": code})
+		} else {
+			fmt.Fprintln(stderr, "goautogui:", err)
+			if code == exitUsage && cmd != nil {
+				fmt.Fprintf(stderr, "usage: goautogui %s %s\n", cmd.name, cmd.args)
+			}
+		}
+		return code
+	}
+	switch {
+	case g.json && result == nil:
+		writeJSON(stdout, map[string]bool{"ok": true})
+	case g.json:
+		writeJSON(stdout, result)
+	case result != nil:
+		fmt.Fprintln(stdout, result)
+	}
+	return exitOK
+}
+
+// parseGlobals parses the global flags before the command name and returns them with the
+// command name and its arguments. Arguments after the command name belong to the command,
+// even if they look like global flags.
+func parseGlobals(args []string) (globals, []string) {
+	g := globals{failSafe: true}
+	for i, arg := range args {
+		switch arg {
+		case "--json", "-json":
+			g.json = true
+		case "--no-failsafe", "-no-failsafe":
+			g.failSafe = false
+		default:
+			return g, args[i:]
+		}
+	}
+	return g, nil
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintln(w, "usage: goautogui [--json] [--no-failsafe] <command> [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n  %-10s   %s\n", c.name, c.args, "", c.summary)
+	}
+	fmt.Fprintln(w, "\nexit codes: 0 ok, 1 failure, 2 usage, 3 not found, 4 timed out, 5 fail-safe")
+}
+
+func writeJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func exitCode(err error) int {
+	var usage usageError
+	switch {
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, goautogui.ErrFailSafe):
+		return exitFailSafe
+	case errors.Is(err, goautogui.ErrWaitTimeout), errors.Is(err, script.ErrTimeout):
+		return exitTimeout
+	case errors.Is(err, goautogui.ErrImageNotFound), errors.Is(err, goautogui.ErrWindowNotFound), errors.Is(err, fs.ErrNotExist):
+		return exitNotFound
+	}
+	return exitFailure
+}
+
+// parse parses the flags of fs, which may come before, after or between the positional
+// arguments, and checks the number of positional arguments is between min and max, or at
+// least min if max is negative. Negative numbers are positional arguments, not flags.
+func parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" || isNumber(arg) {
+			positional = append(positional, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if f := fs.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	if err := fs.Parse(flags); err != nil {
+		return nil, usageError{err.Error()}
+	}
+	if len(positional) < min || (max >= 0 && len(positional) > max) {
+		return nil, usagef("%s: wrong number of arguments", fs.Name())
+	}
+	return positional, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// ints parses each of args as an integer.
+func ints(args ...string) ([]int, error) {
+	n := make([]int, len(args))
+	for i, arg := range args {
+		var err error
+		if n[i], err = strconv.Atoi(arg); err != nil {
+			return nil, usagef("%q is not an integer", arg)
+		}
+	}
+	return n, nil
+}
+
+// intList parses n comma-separated integers, such as a point x,y.
+func intList(s string, n int) ([]int, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, usagef("%q: want %d comma-separated integers", s, n)
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return ints(parts...)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+
+	"github.com/mhmdibrahimm/goautogui/script"
+	goautogui "github.com/mhmdibrahimm/goautogui/windows"
+)
+
+func TestParseGlobals(t *testing.T) {
+	tests := []struct {
+		args         []string
+		wantJSON     bool
+		wantFailSafe bool
+		wantRest     []string
+	}{
+		{nil, false, true, nil},
+		{[]string{"--json", "position"}, true, true, []string{"position"}},
+		{[]string{"-no-failsafe", "-json", "click", "1", "2"}, true, false, []string{"click", "1", "2"}},
+		// Flags after the command name belong to the command
+		{[]string{"type", "--json"}, false, true, []string{"type", "--json"}},
+		{[]string{"--json", "type", "--", "--no-failsafe"}, true, true, []string{"type", "--", "--no-failsafe"}},
+		{[]string{"--json"}, true, true, nil},
+	}
+	for _, tt := range tests {
+		g, rest := parseGlobals(tt.args)
+		if g.json != tt.wantJSON || g.failSafe != tt.wantFailSafe || !slices.Equal(rest, tt.wantRest) {
+			t.Errorf("parseGlobals(%q) = %+v, %q; want json %v, failSafe %v, %q", tt.args, g, rest, tt.wantJSON, tt.wantFailSafe, tt.wantRest)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("boom"), exitFailure},
+		{usagef("bad"), exitUsage},
+		{fmt.Errorf("press: %w", usagef("unknown key")), exitUsage},
+		{fmt.Errorf("click: %w", goautogui.ErrFailSafe), exitFailSafe},
+		{goautogui.ErrWaitTimeout, exitTimeout},
+		{&script.Error{Msg: "image not visible", Err: script.ErrTimeout}, exitTimeout},
+		{fmt.Errorf("locate: %w", goautogui.ErrImageNotFound), exitNotFound},
+		{goautogui.ErrWindowNotFound, exitNotFound},
+		{fmt.Errorf("play: %w", fs.ErrNotExist), exitNotFound},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		min, max int
+		want     []string
+		wantN    int
+		wantBool bool
+		wantErr  bool
+	}{
+		{"flags before", []string{"--n", "3", "a", "b"}, 2, 2, []string{"a", "b"}, 3, false, false},
+		{"flags between and after", []string{"a", "-n=4", "b", "--on"}, 2, 2, []string{"a", "b"}, 4, true, false},
+		{"bool flag takes no value", []string{"--on", "a"}, 1, 1, []string{"a"}, 1, true, false},
+		{"negative numbers are positional", []string{"-5", "-2.5"}, 2, 2, []string{"-5", "-2.5"}, 1, false, false},
+		{"after --", []string{"--", "--n", "3"}, 0, -1, []string{"--n", "3"}, 1, false, false},
+		{"dash is positional", []string{"-"}, 1, 1, []string{"-"}, 1, false, false},
+		{"unlimited", []string{"a", "b", "c"}, 1, -1, []string{"a", "b", "c"}, 1, false, false},
+		{"too few", []string{"a"}, 2, 2, nil, 1, false, true},
+		{"too many", []string{"a", "b", "c"}, 0, 2, nil, 1, false, true},
+		{"unknown flag", []string{"--bogus", "a"}, 1, 1, nil, 1, false, true},
+		{"bad value", []string{"--n", "x", "a"}, 1, 1, nil, 1, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			n := fs.Int("n", 1, "")
+			on := fs.Bool("on", false, "")
+			got, err := parse(fs, tt.args, tt.min, tt.max)
+			if tt.wantErr {
+				var usage usageError
+				if !errors.As(err, &usage) {
+					t.Errorf("parse(%q) error = %v, want a usage error", tt.args, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse(%q): %v", tt.args, err)
+			}
+			if !slices.Equal(got, tt.want) || *n != tt.wantN || *on != tt.wantBool {
+				t.Errorf("parse(%q) = %q, n %d, on %v; want %q, n %d, on %v", tt.args, got, *n, *on, tt.want, tt.wantN, tt.wantBool)
+			}
+		})
+	}
+}
@@ -2,6 +2,12 @@ module github.com/mhmdibrahimm/goautogui
 
 go 1.23.5
 
-require github.com/zzl/go-win32api/v2 v2.2.0
+require (
+	github.com/zzl/go-win32api/v2 v2.2.0
+	golang.org/x/image v0.30.0
+)
 
-require golang.org/x/sys v0.35.0 // indirect
+require (
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+)
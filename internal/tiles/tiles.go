@@ -0,0 +1,59 @@
+// Package tiles finds the areas of the screen that changed between frames, for the live
+// view of the server and the VNC server.
+package tiles
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+)
+
+// ToRGBA returns img as an *image.RGBA with its origin at (0, 0).
+func ToRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// Changed returns the areas of cur that differ from prev, comparing them in square tiles
+// of the given size. Horizontally adjacent changed tiles are merged.
+func Changed(prev, cur *image.RGBA, size int) []image.Rectangle {
+	b := cur.Bounds()
+	var rects []image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y += size {
+		var run image.Rectangle
+		for x := b.Min.X; x < b.Max.X; x += size {
+			t := image.Rect(x, y, x+size, y+size).Intersect(b)
+			if !tileChanged(prev, cur, t) {
+				continue
+			}
+			if !run.Empty() && run.Max.X == t.Min.X {
+				run.Max.X = t.Max.X
+				continue
+			}
+			if !run.Empty() {
+				rects = append(rects, run)
+			}
+			run = t
+		}
+		if !run.Empty() {
+			rects = append(rects, run)
+		}
+	}
+	return rects
+}
+
+func tileChanged(prev, cur *image.RGBA, r image.Rectangle) bool {
+	n := r.Dx() * 4
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i, j := prev.PixOffset(r.Min.X, y), cur.PixOffset(r.Min.X, y)
+		if !bytes.Equal(prev.Pix[i:i+n], cur.Pix[j:j+n]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package tiles
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestToRGBA(t *testing.T) {
+	img := image.NewRGBA(image.Rect(-10, 5, 10, 15))
+	img.SetRGBA(-10, 5, color.RGBA{R: 0xFF, A: 0xFF})
+	got := ToRGBA(img)
+	if got.Rect != image.Rect(0, 0, 20, 10) || got.RGBAAt(0, 0) != (color.RGBA{R: 0xFF, A: 0xFF}) {
+		t.Errorf("ToRGBA() has bounds %v and %v at the origin", got.Rect, got.RGBAAt(0, 0))
+	}
+	if at0 := image.NewRGBA(image.Rect(0, 0, 4, 4)); ToRGBA(at0) != at0 {
+		t.Error("ToRGBA() copied an image already at the origin")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	prev := image.NewRGBA(image.Rect(0, 0, 100, 70))
+	tests := []struct {
+		name   string
+		change []image.Point
+		want   []image.Rectangle
+	}{
+		{"unchanged", nil, nil},
+		{"one pixel", []image.Point{{5, 5}}, []image.Rectangle{image.Rect(0, 0, 32, 32)}},
+		{"adjacent tiles merged", []image.Point{{5, 5}, {40, 10}}, []image.Rectangle{image.Rect(0, 0, 64, 32)}},
+		{"gap between tiles", []image.Point{{5, 5}, {70, 10}}, []image.Rectangle{image.Rect(0, 0, 32, 32), image.Rect(64, 0, 96, 32)}},
+		{"rows not merged", []image.Point{{5, 5}, {5, 40}}, []image.Rectangle{image.Rect(0, 0, 32, 32), image.Rect(0, 32, 32, 64)}},
+		{"partial tile at the edge", []image.Point{{99, 69}}, []image.Rectangle{image.Rect(96, 64, 100, 70)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := image.NewRGBA(prev.Rect)
+			for _, p := range tt.change {
+				cur.SetRGBA(p.X, p.Y, color.RGBA{R: 1})
+			}
+			if got := Changed(prev, cur, 32); !slices.Equal(got, tt.want) {
+				t.Errorf("Changed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
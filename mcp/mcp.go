@@ -0,0 +1,241 @@
+// Package mcp serves a server.Backend to AI agents as Model Context Protocol tools over
+// stdio: newline-delimited JSON-RPC 2.0 messages on stdin and stdout.
+//
+// The tools are listed by tools/list with a JSON schema for their arguments. If the backend
+// is a server.FailSafeBackend, such as a Windows backend created with goautogui.WithFailSafe,
+// input tools refuse to run while its fail-safe is triggered, so that a person can take
+// back control by throwing the mouse into a corner. WithAllowedTools restricts the tools an
+// agent may see and call.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+	"time"
+
+	"github.com/mhmdibrahimm/goautogui/server"
+)
+
+// protocolVersions are the MCP versions the server speaks, newest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAllowedTools only offers the named tools, e.g. the read-only "screenshot",
+// "mouse_position", "list_windows" and "locate_image". Calls to other tools are rejected.
+func WithAllowedTools(names ...string) Option {
+	return func(s *Server) {
+		s.allowed = names
+	}
+}
+
+// WithTimeout sets how long a tool call may take. The default is 60 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.timeout = timeout
+	}
+}
+
+// Server is an MCP server exposing the tools of a Backend.
+type Server struct {
+	backend server.Backend
+	allowed []string // nil allows every tool
+	timeout time.Duration
+}
+
+// New creates a Server that performs tool calls with backend.
+func New(backend server.Backend, opts ...Option) *Server {
+	s := &Server{backend: backend, timeout: 60 * time.Second}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ToolNames returns the names of every tool, allowed or not.
+func ToolNames() []string {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func (s *Server) allows(name string) bool {
+	return s.allowed == nil || slices.Contains(s.allowed, name)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Serve reads requests from r and writes responses to w, one message per line, until r
+// reaches EOF or ctx is cancelled. Requests are handled one at a time, in order.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+	go func() {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 64<<20) // locate_image sends images inline
+		for sc.Scan() {
+			select {
+			case lines <- append([]byte(nil), sc.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		errc <- sc.Err()
+	}()
+
+	send := func(resp response) error {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			return err
+		case line := <-lines:
+			if len(line) == 0 {
+				continue
+			}
+			if resp, ok := s.handle(ctx, line); ok {
+				if err := send(resp); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// handle handles one message, returning false for notifications, which get no response.
+func (s *Server) handle(ctx context.Context, line []byte) (response, bool) {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, err.Error()}}, true
+	}
+	if req.ID == nil {
+		return response{}, false
+	}
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{codeInvalidRequest, "not a JSON-RPC 2.0 request"}
+		return resp, true
+	}
+	result, err := s.dispatch(ctx, req)
+	var rpcErr *rpcError
+	switch {
+	case errors.As(err, &rpcErr):
+		resp.Error = rpcErr
+	case err != nil:
+		resp.Error = &rpcError{codeInvalidParams, err.Error()}
+	default:
+		resp.Result = result
+	}
+	return resp, true
+}
+
+func (s *Server) dispatch(ctx context.Context, req request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		version := protocolVersions[0]
+		if slices.Contains(protocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]bool{"listChanged": false}},
+			"serverInfo":      map[string]string{"name": "goautogui", "version": moduleVersion()},
+			"instructions": "Coordinates are screen pixels; take a screenshot first to see the screen. " +
+				"Input tools stop working while the mouse is in a corner of the primary display, until a person moves it away.",
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		list := []tool{}
+		for _, t := range tools {
+			if s.allows(t.Name) {
+				list = append(list, t)
+			}
+		}
+		return map[string]any{"tools": list}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(tools, func(t tool) bool { return t.Name == params.Name })
+		if i < 0 || !s.allows(params.Name) {
+			return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name)}
+		}
+		return s.call(ctx, tools[i], params.Arguments), nil
+	}
+	return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("unknown method %q", req.Method)}
+}
+
+// moduleVersion returns the version of this module in the running binary.
+func moduleVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == "github.com/mhmdibrahimm/goautogui" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/mhmdibrahimm/goautogui" {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
@@ -0,0 +1,236 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mhmdibrahimm/goautogui/server"
+)
+
+// client is a scripted MCP client: it writes requests to the server's stdin and reads its
+// stdout line by line.
+type client struct {
+	t         *testing.T
+	stdin     io.WriteCloser
+	responses *bufio.Scanner
+	nextID    int
+}
+
+func newClient(t *testing.T, srv *Server) *client {
+	t.Helper()
+	stdinR, stdin := io.Pipe()
+	stdout, stdoutW := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.Serve(ctx, stdinR, stdoutW)
+		stdoutW.Close()
+	}()
+	t.Cleanup(func() {
+		stdin.Close()
+		cancel()
+		<-done
+	})
+	responses := bufio.NewScanner(stdout)
+	responses.Buffer(nil, 16<<20)
+	return &client{t: t, stdin: stdin, responses: responses}
+}
+
+// call sends a request and returns its result, or the JSON-RPC error as an error.
+func (c *client) call(method string, params any) (json.RawMessage, error) {
+	c.t.Helper()
+	c.nextID++
+	req, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	if _, err := fmt.Fprintf(c.stdin, "%s\n", req); err != nil {
+		c.t.Fatal(err)
+	}
+	if !c.responses.Scan() {
+		c.t.Fatalf("%s: no response", method)
+	}
+	var resp struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(c.responses.Bytes(), &resp); err != nil {
+		c.t.Fatal(err)
+	}
+	if resp.ID != c.nextID {
+		c.t.Fatalf("%s: response id %d, want %d", method, resp.ID, c.nextID)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s: error %d: %s", method, resp.Error.Code, resp.Error.Message)
+	}
+	return resp.Result, nil
+}
+
+type toolResult struct {
+	Content []struct{ Type, Text, Data, MimeType string }
+	IsError bool
+}
+
+// tool calls a tool and returns its result.
+func (c *client) tool(name string, args any) toolResult {
+	c.t.Helper()
+	data, err := c.call("tools/call", map[string]any{"name": name, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var res toolResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		c.t.Fatal(err)
+	}
+	return res
+}
+
+// initialize performs the handshake.
+func (c *client) initialize() {
+	c.t.Helper()
+	data, err := c.call("initialize", map[string]any{"protocolVersion": "2025-03-26", "capabilities": map[string]any{}, "clientInfo": map[string]string{"name": "test", "version": "1"}})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"protocolVersion":"2025-03-26"`) {
+		c.t.Fatalf("initialize: %s", data)
+	}
+	fmt.Fprintln(c.stdin, `{"jsonrpc": "2.0", "method": "notifications/initialized"}`) // no response
+}
+
+func TestAllowedTools(t *testing.T) {
+	fake := server.NewFakeBackend(200, 100)
+	c := newClient(t, New(fake, WithAllowedTools("screenshot", "click", "double_click", "mouse_position", "list_windows")))
+	c.initialize()
+
+	// Only the allowed tools are listed, each with a schema
+	data, err := c.call("tools/list", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Tools []struct {
+			Name        string
+			InputSchema map[string]any
+		}
+	}
+	if err := json.Unmarshal(data, &list); err != nil || len(list.Tools) != 5 || list.Tools[0].InputSchema["type"] != "object" {
+		t.Fatalf("tools/list: %s", data)
+	}
+	if _, err := c.call("tools/call", map[string]any{"name": "type_text", "arguments": map[string]string{"text": "rm -rf"}}); err == nil {
+		t.Error("type_text is not allowed but was called")
+	}
+	if calls := fake.CallLog(); len(calls) != 0 {
+		t.Errorf("calls %q, want none", calls)
+	}
+}
+
+func TestTools(t *testing.T) {
+	fake := server.NewFakeBackend(200, 100)
+	fake.Cursor = image.Pt(100, 50)
+	fake.WindowList = []server.Window{{Hwnd: 0x10, Title: "Notepad"}}
+	c := newClient(t, New(fake))
+	c.initialize()
+
+	if res := c.tool("double_click", map[string]int{"x": 20, "y": 30}); res.IsError {
+		t.Errorf("double_click: %v", res)
+	}
+	if res := c.tool("click", map[string]any{"x": 20}); !res.IsError {
+		t.Errorf("click without y: got %v, want an error result", res)
+	}
+	res := c.tool("screenshot", map[string]any{"scale": 0.5})
+	if res.IsError || res.Content[0].Type != "image" || res.Content[0].MimeType != "image/png" {
+		t.Fatalf("screenshot: %v", res)
+	}
+	png64, _ := base64.StdEncoding.DecodeString(res.Content[0].Data)
+	if shot, err := png.Decode(bytes.NewReader(png64)); err != nil || shot.Bounds().Dx() != 100 {
+		t.Errorf("screenshot is not a 100 pixel wide PNG: %v", err)
+	}
+	if res := c.tool("list_windows", nil); !strings.Contains(res.Content[0].Text, `"title":"Notepad"`) {
+		t.Errorf("list_windows: %v", res)
+	}
+	if res := c.tool("mouse_position", nil); res.Content[0].Text != `{"x":20,"y":30}` {
+		t.Errorf("mouse_position: %v", res)
+	}
+	if calls := fake.CallLog(); !slices.Equal(calls, []string{"click 20,30 left x2"}) {
+		t.Errorf("calls %q, want one double click", calls)
+	}
+	if _, err := c.call("resources/list", nil); err == nil || !strings.Contains(err.Error(), "-32601") {
+		t.Errorf("resources/list: got %v, want method not found", err)
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	// Errors of the backend are reported to the agent in the result
+	fake := server.NewFakeBackend(200, 100)
+	fake.Errors["click"] = errors.New("no input desktop")
+	c := newClient(t, New(fake))
+	c.initialize()
+
+	res := c.tool("click", map[string]int{"x": 50, "y": 50})
+	if !res.IsError || res.Content[0].Text != "no input desktop" {
+		t.Errorf("click: got %v, want the backend error", res)
+	}
+}
+
+func TestFailSafe(t *testing.T) {
+	fake := server.NewFakeBackend(200, 100)
+	fake.FailSafe = true
+	c := newClient(t, New(fake))
+	c.initialize()
+
+	// Every input tool is refused while the fail-safe is triggered
+	input := map[string]any{
+		"move_mouse":      map[string]int{"x": 1, "y": 1},
+		"click":           map[string]int{"x": 1, "y": 1},
+		"double_click":    map[string]int{"x": 1, "y": 1},
+		"drag":            map[string]int{"from_x": 1, "from_y": 1, "to_x": 2, "to_y": 2},
+		"scroll":          map[string]int{"notches": 1},
+		"type_text":       map[string]string{"text": "a"},
+		"press_key":       map[string]string{"key": "enter"},
+		"hotkey":          map[string][]string{"keys": {"ctrl", "s"}},
+		"activate_window": map[string]int{"hwnd": 0x10},
+	}
+	for name, args := range input {
+		if res := c.tool(name, args); !res.IsError || res.Content[0].Text != ErrFailSafe.Error() {
+			t.Errorf("%s: got %v, want the fail-safe error", name, res)
+		}
+	}
+	if calls := fake.CallLog(); len(calls) != 0 {
+		t.Errorf("calls %q while the fail-safe is triggered, want none", calls)
+	}
+	// Read-only tools still work, so the agent can see what happened
+	for _, name := range []string{"screenshot", "mouse_position", "list_windows"} {
+		if res := c.tool(name, nil); res.IsError {
+			t.Errorf("%s: %v", name, res)
+		}
+	}
+
+	// Once the mouse is moved away, input works again
+	fake.FailSafe = false
+	if res := c.tool("click", map[string]int{"x": 1, "y": 1}); res.IsError {
+		t.Errorf("click after the fail-safe: %v", res)
+	}
+}
+
+func TestToolsMarkedInput(t *testing.T) {
+	// Tools that are not read-only are input tools, subject to the fail-safe
+	for _, tool := range tools {
+		if tool.input == tool.Annotations.ReadOnlyHint {
+			t.Errorf("tool %s: input %v, read-only %v", tool.Name, tool.input, tool.Annotations.ReadOnlyHint)
+		}
+	}
+}
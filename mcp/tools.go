@@ -0,0 +1,567 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/mhmdibrahimm/goautogui/server"
+	xdraw "golang.org/x/image/draw"
+)
+
+// ErrFailSafe is the error of input tools called while the backend's fail-safe is
+// triggered.
+var ErrFailSafe = errors.New("fail-safe triggered: the mouse is in a corner of the screen; a person must move it away before input tools work again")
+
+type annotations struct {
+	ReadOnlyHint bool `json:"readOnlyHint"`
+}
+
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	Annotations annotations     `json:"annotations"`
+
+	input bool // moves the mouse or presses keys, so is subject to the fail-safe
+	run   func(s *Server, ctx context.Context, args json.RawMessage) ([]content, error)
+}
+
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+type callResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// schema returns the JSON schema of an object with the given properties.
+func schema(properties string, required ...string) json.RawMessage {
+	req, _ := json.Marshal(append([]string{}, required...))
+	return json.RawMessage(fmt.Sprintf(`{"type": "object", "properties": {%s}, "required": %s, "additionalProperties": false}`, properties, req))
+}
+
+const (
+	pointProps = `"x": {"type": "integer", "description": "Screen x coordinate in pixels"},
+		"y": {"type": "integer", "description": "Screen y coordinate in pixels"}`
+	buttonProp = `"button": {"type": "string", "enum": ["left", "right", "middle", "x1", "x2"], "default": "left"}`
+	regionProp = `"region": {"type": "object", "description": "Part of the screen; the primary display if omitted",
+		"properties": {"x": {"type": "integer"}, "y": {"type": "integer"}, "width": {"type": "integer", "minimum": 1}, "height": {"type": "integer", "minimum": 1}},
+		"required": ["x", "y", "width", "height"], "additionalProperties": false}`
+	durationProp = `"duration_ms": {"type": "integer", "minimum": 0, "description": "How long the movement takes"}`
+)
+
+var tools = []tool{
+	{
+		Name:        "screenshot",
+		Description: "Capture the screen, or a region of it, as a PNG image.",
+		InputSchema: schema(regionProp + `,
+			"scale": {"type": "number", "exclusiveMinimum": 0, "maximum": 1, "default": 1, "description": "Shrink the image by this factor"}`),
+		Annotations: annotations{ReadOnlyHint: true},
+		run:         (*Server).screenshot,
+	},
+	{
+		Name:        "mouse_position",
+		Description: "Return the cursor position as JSON {\"x\", \"y\"}.",
+		InputSchema: schema(""),
+		Annotations: annotations{ReadOnlyHint: true},
+		run:         (*Server).mousePosition,
+	},
+	{
+		Name:        "move_mouse",
+		Description: "Move the cursor to a point.",
+		InputSchema: schema(pointProps+", "+durationProp, "x", "y"),
+		input:       true,
+		run:         (*Server).moveMouse,
+	},
+	{
+		Name:        "click",
+		Description: "Click a mouse button at a point.",
+		InputSchema: schema(pointProps+", "+buttonProp+`,
+			"clicks": {"type": "integer", "minimum": 1, "maximum": 3, "default": 1}`, "x", "y"),
+		input: true,
+		run:   (*Server).click,
+	},
+	{
+		Name:        "double_click",
+		Description: "Double-click a mouse button at a point.",
+		InputSchema: schema(pointProps+", "+buttonProp, "x", "y"),
+		input:       true,
+		run:         (*Server).doubleClick,
+	},
+	{
+		Name:        "drag",
+		Description: "Press a mouse button at one point, move to another and release it.",
+		InputSchema: schema(`"from_x": {"type": "integer"}, "from_y": {"type": "integer"},
+			"to_x": {"type": "integer"}, "to_y": {"type": "integer"}, `+buttonProp+", "+durationProp,
+			"from_x", "from_y", "to_x", "to_y"),
+		input: true,
+		run:   (*Server).drag,
+	},
+	{
+		Name:        "scroll",
+		Description: "Scroll the mouse wheel at a point, or at the cursor if none is given.",
+		InputSchema: schema(pointProps+`,
+			"notches": {"type": "number", "description": "Wheel notches; positive scrolls up, or right if horizontal"},
+			"horizontal": {"type": "boolean", "default": false}`, "notches"),
+		input: true,
+		run:   (*Server).scroll,
+	},
+	{
+		Name:        "type_text",
+		Description: "Type text with the keyboard.",
+		InputSchema: schema(`"text": {"type": "string"},
+			"interval_ms": {"type": "integer", "minimum": 0, "description": "Pause between characters"}`, "text"),
+		input: true,
+		run:   (*Server).typeText,
+	},
+	{
+		Name:        "press_key",
+		Description: "Press and release a key, such as enter, tab, esc, f5 or a single character.",
+		InputSchema: schema(`"key": {"type": "string"},
+			"presses": {"type": "integer", "minimum": 1, "default": 1}`, "key"),
+		input: true,
+		run:   (*Server).pressKey,
+	},
+	{
+		Name:        "hotkey",
+		Description: "Press keys in order and release them in reverse, such as [\"ctrl\", \"s\"].",
+		InputSchema: schema(`"keys": {"type": "array", "items": {"type": "string"}, "minItems": 1}`, "keys"),
+		input:       true,
+		run:         (*Server).hotkey,
+	},
+	{
+		Name:        "list_windows",
+		Description: "List the visible windows, front to back, as JSON.",
+		InputSchema: schema(`"title": {"type": "string", "description": "Only windows whose title contains this text, ignoring case"}`),
+		Annotations: annotations{ReadOnlyHint: true},
+		run:         (*Server).listWindows,
+	},
+	{
+		Name:        "activate_window",
+		Description: "Bring a window, given by the hwnd from list_windows, to the foreground.",
+		InputSchema: schema(`"hwnd": {"type": "integer"}`, "hwnd"),
+		input:       true,
+		run:         (*Server).activateWindow,
+	},
+	{
+		Name:        "locate_image",
+		Description: "Find an image on the screen. Returns JSON with found, and if found, x, y, width, height, center_x and center_y.",
+		InputSchema: schema(`"image": {"type": "string", "contentEncoding": "base64", "description": "PNG or JPEG image, base64-encoded"}, `+regionProp+`,
+			"tolerance": {"type": "integer", "minimum": 0, "maximum": 255, "default": 0, "description": "How much each colour channel may differ"}`, "image"),
+		Annotations: annotations{ReadOnlyHint: true},
+		run:         (*Server).locateImage,
+	},
+}
+
+// call runs a tool. Errors are reported in the result, for the agent to see.
+func (s *Server) call(ctx context.Context, t tool, args json.RawMessage) callResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var c []content
+	err := s.checkFailSafe(t)
+	if err == nil {
+		c, err = t.run(s, ctx, args)
+	}
+	if err != nil {
+		return callResult{Content: []content{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+	return callResult{Content: c}
+}
+
+// checkFailSafe returns ErrFailSafe for an input tool if the backend's fail-safe is
+// triggered.
+func (s *Server) checkFailSafe(t tool) error {
+	if b, ok := s.backend.(server.FailSafeBackend); ok && t.input && b.FailSafeTriggered() {
+		return ErrFailSafe
+	}
+	return nil
+}
+
+// decode decodes the arguments of a tool call into v.
+func decode(args json.RawMessage, v any) error {
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+func errMissing(names string) error {
+	return fmt.Errorf("missing %s", names)
+}
+
+func text(v any) ([]content, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return []content{{Type: "text", Text: string(data)}}, nil
+}
+
+func done(format string, args ...any) ([]content, error) {
+	return []content{{Type: "text", Text: fmt.Sprintf(format, args...)}}, nil
+}
+
+func button(name string) (string, error) {
+	switch name {
+	case "":
+		return "left", nil
+	case "left", "right", "middle", "x1", "x2":
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown mouse button %q", name)
+}
+
+type region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r *region) rect() (image.Rectangle, error) {
+	if r == nil {
+		return image.Rectangle{}, nil
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		return image.Rectangle{}, fmt.Errorf("region width and height must be positive")
+	}
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height), nil
+}
+
+func (s *Server) screenshot(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		Region *region  `json:"region"`
+		Scale  *float64 `json:"scale"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	r, err := args.Region.rect()
+	if err != nil {
+		return nil, err
+	}
+	scale := 1.0
+	if args.Scale != nil {
+		if scale = *args.Scale; scale <= 0 || scale > 1 {
+			return nil, fmt.Errorf("scale %g is outside (0, 1]", scale)
+		}
+	}
+	img, err := s.backend.Screenshot(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Empty() {
+		r = image.Rectangle{Max: img.Bounds().Size()}
+		if displays, err := s.backend.Displays(); err == nil && len(displays) > 0 {
+			r = displays[0]
+		}
+	}
+	if scale < 1 {
+		size := img.Bounds().Size()
+		small := image.NewRGBA(image.Rect(0, 0, max(1, int(float64(size.X)*scale)), max(1, int(float64(size.Y)*scale))))
+		xdraw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+		img = small
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	desc := fmt.Sprintf("Screen region x=%d y=%d width=%d height=%d.", r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	if scale < 1 {
+		desc += fmt.Sprintf(" The image is scaled by %g: divide image coordinates by %g and add x and y to get screen coordinates.", scale, scale)
+	} else if r.Min != (image.Point{}) {
+		desc += " Add x and y to image coordinates to get screen coordinates."
+	}
+	return []content{
+		{Type: "image", Data: base64.StdEncoding.EncodeToString(buf.Bytes()), MimeType: "image/png"},
+		{Type: "text", Text: desc},
+	}, nil
+}
+
+func (s *Server) mousePosition(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct{}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	p, err := s.backend.Position()
+	if err != nil {
+		return nil, err
+	}
+	return text(map[string]int{"x": p.X, "y": p.Y})
+}
+
+func (s *Server) moveMouse(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		X          *int `json:"x"`
+		Y          *int `json:"y"`
+		DurationMs int  `json:"duration_ms"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.X == nil || args.Y == nil {
+		return nil, errMissing("x and y")
+	}
+	p := image.Pt(*args.X, *args.Y)
+	if err := s.backend.Move(ctx, p, time.Duration(args.DurationMs)*time.Millisecond); err != nil {
+		return nil, err
+	}
+	return done("Moved the mouse to %d,%d.", p.X, p.Y)
+}
+
+func (s *Server) click(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		X      *int   `json:"x"`
+		Y      *int   `json:"y"`
+		Button string `json:"button"`
+		Clicks int    `json:"clicks"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.X == nil || args.Y == nil {
+		return nil, errMissing("x and y")
+	}
+	return s.clickAt(ctx, image.Pt(*args.X, *args.Y), args.Button, max(1, args.Clicks))
+}
+
+func (s *Server) doubleClick(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		X      *int   `json:"x"`
+		Y      *int   `json:"y"`
+		Button string `json:"button"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.X == nil || args.Y == nil {
+		return nil, errMissing("x and y")
+	}
+	return s.clickAt(ctx, image.Pt(*args.X, *args.Y), args.Button, 2)
+}
+
+func (s *Server) clickAt(ctx context.Context, p image.Point, buttonName string, clicks int) ([]content, error) {
+	b, err := button(buttonName)
+	if err != nil {
+		return nil, err
+	}
+	if clicks > 3 {
+		return nil, fmt.Errorf("clicks %d is more than 3", clicks)
+	}
+	if err := s.backend.Click(ctx, p, b, clicks); err != nil {
+		return nil, err
+	}
+	return done("Clicked %s %d time(s) at %d,%d.", b, clicks, p.X, p.Y)
+}
+
+func (s *Server) drag(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		FromX      *int   `json:"from_x"`
+		FromY      *int   `json:"from_y"`
+		ToX        *int   `json:"to_x"`
+		ToY        *int   `json:"to_y"`
+		Button     string `json:"button"`
+		DurationMs int    `json:"duration_ms"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.FromX == nil || args.FromY == nil || args.ToX == nil || args.ToY == nil {
+		return nil, errMissing("from_x, from_y, to_x and to_y")
+	}
+	b, err := button(args.Button)
+	if err != nil {
+		return nil, err
+	}
+	from, to := image.Pt(*args.FromX, *args.FromY), image.Pt(*args.ToX, *args.ToY)
+	if err := s.backend.Drag(ctx, from, to, time.Duration(args.DurationMs)*time.Millisecond, b); err != nil {
+		return nil, err
+	}
+	return done("Dragged from %d,%d to %d,%d.", from.X, from.Y, to.X, to.Y)
+}
+
+func (s *Server) scroll(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		X          *int     `json:"x"`
+		Y          *int     `json:"y"`
+		Notches    *float64 `json:"notches"`
+		Horizontal bool     `json:"horizontal"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Notches == nil {
+		return nil, errMissing("notches")
+	}
+	if (args.X == nil) != (args.Y == nil) {
+		return nil, fmt.Errorf("give both x and y, or neither")
+	}
+	p, err := s.backend.Position()
+	if err != nil {
+		return nil, err
+	}
+	if args.X != nil {
+		p = image.Pt(*args.X, *args.Y)
+	}
+	if err := s.backend.Scroll(ctx, p, *args.Notches, args.Horizontal); err != nil {
+		return nil, err
+	}
+	return done("Scrolled %g notches at %d,%d.", *args.Notches, p.X, p.Y)
+}
+
+func (s *Server) typeText(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		Text       *string `json:"text"`
+		IntervalMs int     `json:"interval_ms"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Text == nil {
+		return nil, errMissing("text")
+	}
+	if err := s.backend.Type(ctx, *args.Text, time.Duration(args.IntervalMs)*time.Millisecond); err != nil {
+		return nil, err
+	}
+	return done("Typed %d characters.", len([]rune(*args.Text)))
+}
+
+func (s *Server) pressKey(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		Key     *string `json:"key"`
+		Presses int     `json:"presses"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Key == nil {
+		return nil, errMissing("key")
+	}
+	if err := s.backend.Press(ctx, *args.Key, max(1, args.Presses)); err != nil {
+		return nil, err
+	}
+	return done("Pressed %s.", *args.Key)
+}
+
+func (s *Server) hotkey(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		Keys []string `json:"keys"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if len(args.Keys) == 0 {
+		return nil, errMissing("keys")
+	}
+	if err := s.backend.Hotkey(ctx, args.Keys); err != nil {
+		return nil, err
+	}
+	return done("Pressed %s.", strings.Join(args.Keys, "+"))
+}
+
+func (s *Server) listWindows(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		Title string `json:"title"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	windows, err := s.backend.Windows()
+	if err != nil {
+		return nil, err
+	}
+	type window struct {
+		Hwnd       uint64 `json:"hwnd"`
+		Title      string `json:"title"`
+		X          int    `json:"x"`
+		Y          int    `json:"y"`
+		Width      int    `json:"width"`
+		Height     int    `json:"height"`
+		Minimized  bool   `json:"minimized"`
+		Foreground bool   `json:"foreground"`
+	}
+	list := []window{}
+	title := strings.ToLower(args.Title)
+	for _, w := range windows {
+		if strings.Contains(strings.ToLower(w.Title), title) {
+			b := w.Bounds
+			list = append(list, window{w.Hwnd, w.Title, b.Min.X, b.Min.Y, b.Dx(), b.Dy(), w.Minimized, w.Foreground})
+		}
+	}
+	return text(list)
+}
+
+func (s *Server) activateWindow(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		Hwnd *uint64 `json:"hwnd"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Hwnd == nil {
+		return nil, errMissing("hwnd")
+	}
+	if err := s.backend.ActivateWindow(*args.Hwnd); err != nil {
+		return nil, err
+	}
+	return done("Activated window %d.", *args.Hwnd)
+}
+
+func (s *Server) locateImage(ctx context.Context, raw json.RawMessage) ([]content, error) {
+	var args struct {
+		Image     *string `json:"image"`
+		Region    *region `json:"region"`
+		Tolerance int     `json:"tolerance"`
+	}
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Image == nil {
+		return nil, errMissing("image")
+	}
+	if args.Tolerance < 0 || args.Tolerance > 255 {
+		return nil, fmt.Errorf("tolerance %d is outside 0 to 255", args.Tolerance)
+	}
+	r, err := args.Region.rect()
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(*args.Image)
+	if err != nil {
+		return nil, fmt.Errorf("image is not base64: %v", err)
+	}
+	needle, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode the image: %v", err)
+	}
+	found, ok, err := s.backend.Locate(needle, r, uint8(args.Tolerance))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return text(map[string]bool{"found": false})
+	}
+	c := found.Min.Add(found.Max).Div(2)
+	return text(map[string]any{
+		"found": true, "x": found.Min.X, "y": found.Min.Y, "width": found.Dx(), "height": found.Dy(),
+		"center_x": c.X, "center_y": c.Y,
+	})
+}
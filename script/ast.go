@@ -0,0 +1,276 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Pos is a position in a script.
+type Pos struct {
+	Filename string // empty if the script did not come from a file
+	Line     int    // 1-based
+	Col      int    // 1-based, in characters
+}
+
+func (p Pos) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Col)
+}
+
+// Error is an error at a position in a script, found while parsing, checking or running it.
+type Error struct {
+	Pos Pos
+	Msg string
+	Err error // underlying error of a failed command, if any
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is the list of errors Parse found, in the order of their positions.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Program is a parsed script.
+type Program struct {
+	Stmts []Stmt
+}
+
+// Stmt is a statement: a command, an assignment or a block.
+type Stmt interface {
+	Pos() Pos
+}
+
+// Expr is an expression.
+type Expr interface {
+	Pos() Pos
+}
+
+// Expressions
+
+type (
+	// NumberLit is a number such as 100 or 1.5.
+	NumberLit struct {
+		At    Pos
+		Value float64
+	}
+
+	// DurationLit is a duration such as 500ms or 2s.
+	DurationLit struct {
+		At    Pos
+		Value time.Duration
+	}
+
+	// StringLit is a quoted string, or a bare word where a file name is expected.
+	StringLit struct {
+		At    Pos
+		Value string
+	}
+
+	// VarRef is a reference to a variable, $name.
+	VarRef struct {
+		At   Pos
+		Name string
+	}
+
+	// UnaryExpr is a negation, -X.
+	UnaryExpr struct {
+		At Pos
+		Op string
+		X  Expr
+	}
+
+	// BinaryExpr is X Op Y, where Op is an arithmetic or comparison operator.
+	BinaryExpr struct {
+		At   Pos
+		Op   string
+		X, Y Expr
+	}
+)
+
+func (e *NumberLit) Pos() Pos   { return e.At }
+func (e *DurationLit) Pos() Pos { return e.At }
+func (e *StringLit) Pos() Pos   { return e.At }
+func (e *VarRef) Pos() Pos      { return e.At }
+func (e *UnaryExpr) Pos() Pos   { return e.At }
+func (e *BinaryExpr) Pos() Pos  { return e.At }
+
+// Point is a screen position, X,Y.
+type Point struct {
+	X, Y Expr
+}
+
+// Region is a screen region, X,Y,Width,Height.
+type Region struct {
+	X, Y, Width, Height Expr
+}
+
+// Statements
+
+type (
+	// SetStmt is set $name = Value.
+	SetStmt struct {
+		At    Pos
+		Name  string
+		Value Expr
+	}
+
+	// PrintStmt is print Value.
+	PrintStmt struct {
+		At    Pos
+		Value Expr
+	}
+
+	// MoveStmt is move X,Y [over Duration].
+	MoveStmt struct {
+		At   Pos
+		To   Point
+		Over Expr // nil to jump
+	}
+
+	// ClickStmt is click X,Y [Button] [double|triple|times N].
+	ClickStmt struct {
+		At     Pos
+		Target Point
+		Button string // left, right, middle, x1 or x2
+		Clicks Expr   // nil for a single click
+	}
+
+	// DragStmt is drag X,Y -> X,Y [over Duration] [Button].
+	DragStmt struct {
+		At       Pos
+		From, To Point
+		Over     Expr // nil to jump
+		Button   string
+	}
+
+	// ScrollStmt is scroll|hscroll Amount [at X,Y].
+	ScrollStmt struct {
+		At         Pos
+		Amount     Expr
+		Where      *Point // nil for the cursor position
+		Horizontal bool
+	}
+
+	// TypeStmt is type Text.
+	TypeStmt struct {
+		At   Pos
+		Text Expr
+	}
+
+	// PressStmt is press Key [times N].
+	PressStmt struct {
+		At    Pos
+		Key   string
+		Times Expr // nil for a single press
+	}
+
+	// HotkeyStmt is hotkey Key+Key+....
+	HotkeyStmt struct {
+		At   Pos
+		Keys []string
+	}
+
+	// WaitStmt is wait Duration.
+	WaitStmt struct {
+		At       Pos
+		Duration Expr
+	}
+
+	// WaitImageStmt is wait image File [in Region] [tolerance N] [timeout Duration].
+	WaitImageStmt struct {
+		At    Pos
+		Image ImageMatch
+	}
+
+	// ScreenshotStmt is screenshot File [in Region].
+	ScreenshotStmt struct {
+		At     Pos
+		File   Expr
+		Region *Region // nil for the primary display
+	}
+
+	// RepeatStmt is repeat Count [as $name] ... end.
+	RepeatStmt struct {
+		At    Pos
+		Count Expr
+		Var   string // set to the 1-based iteration number, if not empty
+		Body  []Stmt
+	}
+
+	// WhileStmt is while Cond ... end.
+	WhileStmt struct {
+		At   Pos
+		Cond Cond
+		Body []Stmt
+	}
+
+	// IfStmt is if Cond ... [else ...] end.
+	IfStmt struct {
+		At   Pos
+		Cond Cond
+		Then []Stmt
+		Else []Stmt
+	}
+)
+
+func (s *SetStmt) Pos() Pos        { return s.At }
+func (s *PrintStmt) Pos() Pos      { return s.At }
+func (s *MoveStmt) Pos() Pos       { return s.At }
+func (s *ClickStmt) Pos() Pos      { return s.At }
+func (s *DragStmt) Pos() Pos       { return s.At }
+func (s *ScrollStmt) Pos() Pos     { return s.At }
+func (s *TypeStmt) Pos() Pos       { return s.At }
+func (s *PressStmt) Pos() Pos      { return s.At }
+func (s *HotkeyStmt) Pos() Pos     { return s.At }
+func (s *WaitStmt) Pos() Pos       { return s.At }
+func (s *WaitImageStmt) Pos() Pos  { return s.At }
+func (s *ScreenshotStmt) Pos() Pos { return s.At }
+func (s *RepeatStmt) Pos() Pos     { return s.At }
+func (s *WhileStmt) Pos() Pos      { return s.At }
+func (s *IfStmt) Pos() Pos         { return s.At }
+
+// ImageMatch describes an image to look for on the screen.
+type ImageMatch struct {
+	File      Expr
+	Region    *Region // nil for the primary display
+	Tolerance Expr    // nil for an exact match
+	Timeout   Expr    // wait image only; nil for the default of 10s
+}
+
+// Cond is the condition of an if or while statement: an *ImageCond or a comparison *BinaryExpr.
+type Cond interface {
+	Pos() Pos
+}
+
+// ImageCond is [not] image visible File [in Region] [tolerance N].
+type ImageCond struct {
+	At    Pos
+	Not   bool
+	Image ImageMatch
+}
+
+func (c *ImageCond) Pos() Pos { return c.At }
@@ -0,0 +1,202 @@
+package script
+
+import "fmt"
+
+// valueType is the type of a value, as far as it is known before running the script.
+type valueType int
+
+const (
+	typeUnknown valueType = iota
+	typeNumber
+	typeDuration
+	typeString
+)
+
+func (t valueType) String() string {
+	return [...]string{"a value", "a number", "a duration", "a string"}[t]
+}
+
+// builtinVars are the variables every script starts with.
+var builtinVars = []string{"image_x", "image_y"}
+
+// checker finds the errors in a parsed program that do not depend on the screen: variables
+// used before they are set and values of the wrong type.
+type checker struct {
+	vars map[string]valueType
+	errs ErrorList
+}
+
+func check(prog *Program) ErrorList {
+	c := &checker{vars: map[string]valueType{}}
+	for _, name := range builtinVars {
+		c.vars[name] = typeNumber
+	}
+	c.stmts(prog.Stmts)
+	return c.errs
+}
+
+func (c *checker) errorf(pos Pos, format string, args ...any) {
+	c.errs = append(c.errs, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) stmts(stmts []Stmt) {
+	for _, s := range stmts {
+		c.stmt(s)
+	}
+}
+
+func (c *checker) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *SetStmt:
+		t := c.expr(s.Value)
+		if old, ok := c.vars[s.Name]; ok && old != t {
+			t = typeUnknown
+		}
+		c.vars[s.Name] = t
+	case *PrintStmt:
+		c.expr(s.Value)
+	case *MoveStmt:
+		c.point(s.To)
+		c.want(s.Over, typeDuration)
+	case *ClickStmt:
+		c.point(s.Target)
+		c.want(s.Clicks, typeNumber)
+	case *DragStmt:
+		c.point(s.From)
+		c.point(s.To)
+		c.want(s.Over, typeDuration)
+	case *ScrollStmt:
+		c.want(s.Amount, typeNumber)
+		if s.Where != nil {
+			c.point(*s.Where)
+		}
+	case *TypeStmt:
+		c.expr(s.Text)
+	case *PressStmt:
+		c.want(s.Times, typeNumber)
+	case *WaitStmt:
+		if c.expr(s.Duration) == typeNumber {
+			c.errorf(s.Duration.Pos(), "wait needs a duration with a unit, such as 2s or 500ms")
+		} else {
+			c.want(s.Duration, typeDuration)
+		}
+	case *WaitImageStmt:
+		c.image(s.Image)
+	case *ScreenshotStmt:
+		c.want(s.File, typeString)
+		c.region(s.Region)
+	case *RepeatStmt:
+		c.want(s.Count, typeNumber)
+		if s.Var != "" {
+			c.vars[s.Var] = typeNumber
+		}
+		c.stmts(s.Body)
+	case *WhileStmt:
+		c.cond(s.Cond)
+		c.stmts(s.Body)
+	case *IfStmt:
+		c.cond(s.Cond)
+		c.stmts(s.Then)
+		c.stmts(s.Else)
+	}
+}
+
+func (c *checker) cond(cond Cond) {
+	switch cond := cond.(type) {
+	case *ImageCond:
+		c.image(cond.Image)
+	case *BinaryExpr:
+		x, y := c.expr(cond.X), c.expr(cond.Y)
+		if x != typeUnknown && y != typeUnknown && x != y {
+			c.errorf(cond.Pos(), "cannot compare %v with %v", x, y)
+		}
+	}
+}
+
+func (c *checker) image(m ImageMatch) {
+	c.want(m.File, typeString)
+	c.region(m.Region)
+	c.want(m.Tolerance, typeNumber)
+	c.want(m.Timeout, typeDuration)
+}
+
+func (c *checker) point(p Point) {
+	c.want(p.X, typeNumber)
+	c.want(p.Y, typeNumber)
+}
+
+func (c *checker) region(r *Region) {
+	if r == nil {
+		return
+	}
+	c.want(r.X, typeNumber)
+	c.want(r.Y, typeNumber)
+	c.want(r.Width, typeNumber)
+	c.want(r.Height, typeNumber)
+}
+
+// want checks that e, if present, can be of type t.
+func (c *checker) want(e Expr, t valueType) {
+	if e == nil {
+		return
+	}
+	if got := c.expr(e); got != typeUnknown && got != t {
+		c.errorf(e.Pos(), "expected %v, found %v", t, got)
+	}
+}
+
+// expr checks e and returns its type.
+func (c *checker) expr(e Expr) valueType {
+	switch e := e.(type) {
+	case *NumberLit:
+		return typeNumber
+	case *DurationLit:
+		return typeDuration
+	case *StringLit:
+		return typeString
+	case *VarRef:
+		t, ok := c.vars[e.Name]
+		if !ok {
+			c.errorf(e.At, "variable $%s is used before it is set", e.Name)
+		}
+		return t
+	case *UnaryExpr:
+		t := c.expr(e.X)
+		if t == typeString {
+			c.errorf(e.At, "cannot negate a string")
+		}
+		return t
+	case *BinaryExpr:
+		x, y := c.expr(e.X), c.expr(e.Y)
+		if x == typeUnknown || y == typeUnknown {
+			return typeUnknown
+		}
+		t, err := binaryType(e.Op, x, y)
+		if err != nil {
+			c.errorf(e.At, "%v", err)
+		}
+		return t
+	}
+	return typeUnknown
+}
+
+// binaryType returns the type of x op y, following the rules of the interpreter.
+func binaryType(op string, x, y valueType) (valueType, error) {
+	switch {
+	case op == "+" && (x == typeString || y == typeString):
+		return typeString, nil
+	case (op == "+" || op == "-") && x == y && x != typeString:
+		return x, nil
+	case op == "*" && x == typeNumber && y == typeNumber:
+		return typeNumber, nil
+	case op == "*" && (x == typeDuration && y == typeNumber || x == typeNumber && y == typeDuration):
+		return typeDuration, nil
+	case op == "/" && x == typeNumber && y == typeNumber:
+		return typeNumber, nil
+	case op == "/" && x == typeDuration && y == typeNumber:
+		return typeDuration, nil
+	case op == "/" && x == typeDuration && y == typeDuration:
+		return typeNumber, nil
+	}
+	return typeUnknown, fmt.Errorf("cannot use %s between %v and %v", op, x, y)
+}
@@ -0,0 +1,41 @@
+// Package script implements a small line-based language for automation scripts, for people
+// who would rather not write Go. Parse checks a script and reports every error with its
+// line and column; an Interpreter runs it against a Sink, which is WindowsSink for the real
+// mouse, keyboard and screen, or FakeSink to test scripts without them.
+//
+// A script has one command per line. # starts a comment.
+//
+//	set $x = 100                      # variables hold numbers, durations or strings
+//	move $x,200 over 500ms            # glide to a point; without over, jump
+//	click 100,200                     # left click; add right, middle, x1 or x2,
+//	click $x+50,200 right double      # and double, triple or times N
+//	drag 10,10 -> 300,300 over 1s     # press, move and release; a button may follow
+//	scroll -3 at 500,400              # notches, positive up; hscroll scrolls sideways
+//	type "hello\n"                    # strings take Go escapes
+//	press enter times 2               # a key name or single character
+//	hotkey ctrl+shift+s               # keys pressed in order, released in reverse
+//	wait 2s
+//	wait image save.png timeout 5s    # in x,y,width,height and tolerance N may follow
+//	screenshot "after save.png"       # the primary display, or in x,y,width,height
+//	print "done " + $x
+//
+// Blocks end with end:
+//
+//	repeat 3 as $i                    # $i counts from 1
+//	    click 100, 100 + $i * 20
+//	end
+//	if image visible error.png        # also: if not image visible, if $x >= 10
+//	    click $image_x,$image_y       # the centre of the last image found
+//	else
+//	    press esc
+//	end
+//	while not image visible done.png
+//	    wait 1s
+//	end
+//
+// Numbers and durations support + - * / and parentheses; + joins strings. Bare words
+// such as save.png are file names where one is expected; other text must be quoted.
+// Key names are those of pyautogui: enter, tab, esc, space, backspace, delete, up, down,
+// left, right, home, end, pageup, pagedown, shift, ctrl, alt, win, f1 to f24, num0 to
+// num9 and so on.
+package script
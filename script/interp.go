@@ -0,0 +1,635 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ErrTimeout is wrapped by the *Error of a wait image command whose image did not appear
+// before its timeout.
+var ErrTimeout = errors.New("timed out")
+
+// Option configures an Interpreter.
+type Option func(*Interpreter)
+
+// WithOutput sets where print writes. The default is os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(in *Interpreter) {
+		in.out = w
+	}
+}
+
+// WithBaseDir resolves relative image and screenshot file names against dir, usually the
+// directory of the script file. By default they are relative to the working directory.
+func WithBaseDir(dir string) Option {
+	return func(in *Interpreter) {
+		in.baseDir = dir
+	}
+}
+
+// WithPollInterval sets how often wait image looks at the screen. The default is 250ms.
+func WithPollInterval(interval time.Duration) Option {
+	return func(in *Interpreter) {
+		in.poll = interval
+	}
+}
+
+// WithTrace calls fn before each statement runs, e.g. to log progress.
+func WithTrace(fn func(Stmt)) Option {
+	return func(in *Interpreter) {
+		in.trace = fn
+	}
+}
+
+// Interpreter runs parsed scripts against a Sink.
+type Interpreter struct {
+	sink    Sink
+	out     io.Writer
+	baseDir string
+	poll    time.Duration
+	trace   func(Stmt)
+	vars    map[string]any // float64, time.Duration or string
+}
+
+// NewInterpreter creates an Interpreter that performs scripts with sink.
+func NewInterpreter(sink Sink, opts ...Option) *Interpreter {
+	in := &Interpreter{sink: sink, out: os.Stdout, poll: 250 * time.Millisecond}
+	for _, opt := range opts {
+		opt(in)
+	}
+	return in
+}
+
+// defaultImageTimeout is how long wait image waits without a timeout.
+const defaultImageTimeout = 10 * time.Second
+
+// Run runs prog from the start with fresh variables. It stops at the first statement that
+// fails, returning an *Error with its position, or when ctx is cancelled.
+func (in *Interpreter) Run(ctx context.Context, prog *Program) error {
+	in.vars = map[string]any{}
+	for _, name := range builtinVars {
+		in.vars[name] = 0.0
+	}
+	return in.stmts(ctx, prog.Stmts)
+}
+
+// errorf returns an *Error at pos wrapping err, if any.
+func errorf(pos Pos, err error, format string, args ...any) *Error {
+	msg := fmt.Sprintf(format, args...)
+	if err != nil {
+		msg += ": " + err.Error()
+	}
+	return &Error{Pos: pos, Msg: msg, Err: err}
+}
+
+func (in *Interpreter) stmts(ctx context.Context, stmts []Stmt) error {
+	for _, s := range stmts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if in.trace != nil {
+			in.trace(s)
+		}
+		if err := in.stmt(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *Interpreter) stmt(ctx context.Context, s Stmt) error {
+	switch s := s.(type) {
+	case *SetStmt:
+		v, err := in.eval(s.Value)
+		if err != nil {
+			return err
+		}
+		in.vars[s.Name] = v
+		return nil
+
+	case *PrintStmt:
+		v, err := in.eval(s.Value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(in.out, format(v))
+		return nil
+
+	case *MoveStmt:
+		p, err := in.point(s.To)
+		if err != nil {
+			return err
+		}
+		over, err := in.optDuration(s.Over, 0)
+		if err != nil {
+			return err
+		}
+		if err := in.sink.Move(ctx, p, over); err != nil {
+			return errorf(s.At, err, "move failed")
+		}
+		return nil
+
+	case *ClickStmt:
+		p, err := in.point(s.Target)
+		if err != nil {
+			return err
+		}
+		clicks, err := in.count(s.Clicks, 1)
+		if err != nil {
+			return err
+		}
+		if err := in.sink.Click(p, buttonOrLeft(s.Button), clicks); err != nil {
+			return errorf(s.At, err, "click failed")
+		}
+		return nil
+
+	case *DragStmt:
+		from, err := in.point(s.From)
+		if err != nil {
+			return err
+		}
+		to, err := in.point(s.To)
+		if err != nil {
+			return err
+		}
+		over, err := in.optDuration(s.Over, 0)
+		if err != nil {
+			return err
+		}
+		if err := in.sink.Drag(ctx, from, to, over, buttonOrLeft(s.Button)); err != nil {
+			return errorf(s.At, err, "drag failed")
+		}
+		return nil
+
+	case *ScrollStmt:
+		amount, err := in.number(s.Amount)
+		if err != nil {
+			return err
+		}
+		var p image.Point
+		if s.Where != nil {
+			p, err = in.point(*s.Where)
+		} else {
+			p, err = in.sink.Position()
+		}
+		if err != nil {
+			return errorf(s.At, err, "scroll failed")
+		}
+		if err := in.sink.Scroll(p, amount, s.Horizontal); err != nil {
+			return errorf(s.At, err, "scroll failed")
+		}
+		return nil
+
+	case *TypeStmt:
+		v, err := in.eval(s.Text)
+		if err != nil {
+			return err
+		}
+		if err := in.sink.Type(ctx, format(v)); err != nil {
+			return errorf(s.At, err, "type failed")
+		}
+		return nil
+
+	case *PressStmt:
+		times, err := in.count(s.Times, 1)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < times; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := in.sink.Press(ctx, s.Key); err != nil {
+				return errorf(s.At, err, "press %s failed", s.Key)
+			}
+		}
+		return nil
+
+	case *HotkeyStmt:
+		if err := in.sink.Hotkey(ctx, s.Keys); err != nil {
+			return errorf(s.At, err, "hotkey failed")
+		}
+		return nil
+
+	case *WaitStmt:
+		d, err := in.duration(s.Duration)
+		if err != nil {
+			return err
+		}
+		return in.sink.Sleep(ctx, d)
+
+	case *WaitImageStmt:
+		return in.waitImage(ctx, s)
+
+	case *ScreenshotStmt:
+		file, err := in.file(s.File)
+		if err != nil {
+			return err
+		}
+		region, err := in.region(s.Region)
+		if err != nil {
+			return err
+		}
+		if err := in.sink.Screenshot(file, region); err != nil {
+			return errorf(s.At, err, "screenshot failed")
+		}
+		return nil
+
+	case *RepeatStmt:
+		count, err := in.count(s.Count, 0)
+		if err != nil {
+			return err
+		}
+		for i := 1; i <= count; i++ {
+			// Checked here too, as an empty body never reaches stmts' check
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if s.Var != "" {
+				in.vars[s.Var] = float64(i)
+			}
+			if err := in.stmts(ctx, s.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *WhileStmt:
+		for {
+			ok, err := in.cond(s.Cond)
+			if err != nil || !ok {
+				return err
+			}
+			if err := in.stmts(ctx, s.Body); err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+	case *IfStmt:
+		ok, err := in.cond(s.Cond)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return in.stmts(ctx, s.Then)
+		}
+		return in.stmts(ctx, s.Else)
+	}
+	return errorf(s.Pos(), nil, "unsupported statement %T", s)
+}
+
+// waitImage polls the screen until the image appears or the timeout expires.
+func (in *Interpreter) waitImage(ctx context.Context, s *WaitImageStmt) error {
+	timeout, err := in.optDuration(s.Image.Timeout, defaultImageTimeout)
+	if err != nil {
+		return err
+	}
+	deadline := in.sink.Now().Add(timeout)
+	for {
+		found, err := in.findImage(s.Image)
+		if err != nil || found {
+			return err
+		}
+		if !in.sink.Now().Before(deadline) {
+			file, _ := in.file(s.Image.File)
+			return &Error{Pos: s.At, Msg: fmt.Sprintf("image %s not visible after %v", file, timeout), Err: ErrTimeout}
+		}
+		if err := in.sink.Sleep(ctx, in.poll); err != nil {
+			return err
+		}
+	}
+}
+
+// findImage looks for an image once, setting $image_x and $image_y to the centre of the
+// image if it is found.
+func (in *Interpreter) findImage(m ImageMatch) (bool, error) {
+	file, err := in.file(m.File)
+	if err != nil {
+		return false, err
+	}
+	region, err := in.region(m.Region)
+	if err != nil {
+		return false, err
+	}
+	tolerance := 0
+	if m.Tolerance != nil {
+		if tolerance, err = in.integer(m.Tolerance); err != nil {
+			return false, err
+		}
+		if tolerance < 0 || tolerance > 255 {
+			return false, errorf(m.Tolerance.Pos(), nil, "tolerance %d is outside 0 to 255", tolerance)
+		}
+	}
+	r, found, err := in.sink.FindImage(file, region, uint8(tolerance))
+	if err != nil {
+		return false, errorf(m.File.Pos(), err, "cannot look for %s", file)
+	}
+	if found {
+		c := r.Min.Add(r.Max).Div(2)
+		in.vars["image_x"], in.vars["image_y"] = float64(c.X), float64(c.Y)
+	}
+	return found, nil
+}
+
+func (in *Interpreter) cond(c Cond) (bool, error) {
+	switch c := c.(type) {
+	case *ImageCond:
+		found, err := in.findImage(c.Image)
+		return found != c.Not, err
+	case *BinaryExpr:
+		x, err := in.eval(c.X)
+		if err != nil {
+			return false, err
+		}
+		y, err := in.eval(c.Y)
+		if err != nil {
+			return false, err
+		}
+		return compare(c, x, y)
+	}
+	return false, errorf(c.Pos(), nil, "unsupported condition %T", c)
+}
+
+// compare evaluates a comparison of two values of the same type.
+func compare(c *BinaryExpr, x, y any) (bool, error) {
+	var cmp int
+	switch x := x.(type) {
+	case float64:
+		if y, ok := y.(float64); ok {
+			cmp = compareOrdered(x, y)
+		} else {
+			return false, errorf(c.At, nil, "cannot compare %v with %v", typeOf(x), typeOf(y))
+		}
+	case time.Duration:
+		if y, ok := y.(time.Duration); ok {
+			cmp = compareOrdered(x, y)
+		} else {
+			return false, errorf(c.At, nil, "cannot compare %v with %v", typeOf(x), typeOf(y))
+		}
+	case string:
+		if y, ok := y.(string); ok {
+			cmp = compareOrdered(x, y)
+		} else {
+			return false, errorf(c.At, nil, "cannot compare %v with %v", typeOf(x), typeOf(y))
+		}
+	}
+	switch c.Op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case ">":
+		return cmp > 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func compareOrdered[T float64 | time.Duration | string](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// eval evaluates an expression to a float64, time.Duration or string.
+func (in *Interpreter) eval(e Expr) (any, error) {
+	switch e := e.(type) {
+	case *NumberLit:
+		return e.Value, nil
+	case *DurationLit:
+		return e.Value, nil
+	case *StringLit:
+		return e.Value, nil
+	case *VarRef:
+		v, ok := in.vars[e.Name]
+		if !ok {
+			return nil, errorf(e.At, nil, "variable $%s is not set", e.Name)
+		}
+		return v, nil
+	case *UnaryExpr:
+		v, err := in.eval(e.X)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case float64:
+			return -v, nil
+		case time.Duration:
+			return -v, nil
+		}
+		return nil, errorf(e.At, nil, "cannot negate %v", typeOf(v))
+	case *BinaryExpr:
+		x, err := in.eval(e.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := in.eval(e.Y)
+		if err != nil {
+			return nil, err
+		}
+		return binary(e, x, y)
+	}
+	return nil, errorf(e.Pos(), nil, "unsupported expression %T", e)
+}
+
+// binary evaluates an arithmetic expression, following binaryType.
+func binary(e *BinaryExpr, x, y any) (any, error) {
+	if _, err := binaryType(e.Op, typeOf(x), typeOf(y)); err != nil {
+		return nil, errorf(e.At, nil, "%v", err)
+	}
+	if e.Op == "+" {
+		if xs, ok := x.(string); ok {
+			return xs + format(y), nil
+		}
+		if ys, ok := y.(string); ok {
+			return format(x) + ys, nil
+		}
+	}
+	if e.Op == "/" {
+		if y == 0.0 || y == time.Duration(0) {
+			return nil, errorf(e.At, nil, "division by zero")
+		}
+	}
+	switch x := x.(type) {
+	case float64:
+		switch y := y.(type) {
+		case float64:
+			switch e.Op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			case "*":
+				return x * y, nil
+			}
+			return x / y, nil
+		case time.Duration: // number * duration
+			return time.Duration(x * float64(y)), nil
+		}
+	case time.Duration:
+		switch y := y.(type) {
+		case time.Duration:
+			switch e.Op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			}
+			return float64(x) / float64(y), nil // duration / duration
+		case float64:
+			if e.Op == "*" {
+				return time.Duration(float64(x) * y), nil
+			}
+			return time.Duration(float64(x) / y), nil
+		}
+	}
+	return nil, errorf(e.At, nil, "cannot use %s between %v and %v", e.Op, typeOf(x), typeOf(y))
+}
+
+// typeOf returns the type of a value.
+func typeOf(v any) valueType {
+	switch v.(type) {
+	case float64:
+		return typeNumber
+	case time.Duration:
+		return typeDuration
+	case string:
+		return typeString
+	}
+	return typeUnknown
+}
+
+// format converts a value to text for print and type.
+func format(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+func (in *Interpreter) number(e Expr) (float64, error) {
+	v, err := in.eval(e)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, errorf(e.Pos(), nil, "expected a number, found %v", typeOf(v))
+	}
+	return n, nil
+}
+
+// integer evaluates a number and rounds it to the nearest integer.
+func (in *Interpreter) integer(e Expr) (int, error) {
+	n, err := in.number(e)
+	return int(math.Round(n)), err
+}
+
+// count evaluates a non-negative count, or returns def if e is nil.
+func (in *Interpreter) count(e Expr, def int) (int, error) {
+	if e == nil {
+		return def, nil
+	}
+	n, err := in.integer(e)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errorf(e.Pos(), nil, "count %d is negative", n)
+	}
+	return n, nil
+}
+
+func (in *Interpreter) duration(e Expr) (time.Duration, error) {
+	v, err := in.eval(e)
+	if err != nil {
+		return 0, err
+	}
+	d, ok := v.(time.Duration)
+	if !ok {
+		return 0, errorf(e.Pos(), nil, "expected a duration, found %v", typeOf(v))
+	}
+	return d, nil
+}
+
+// optDuration evaluates a duration, or returns def if e is nil.
+func (in *Interpreter) optDuration(e Expr, def time.Duration) (time.Duration, error) {
+	if e == nil {
+		return def, nil
+	}
+	return in.duration(e)
+}
+
+// file evaluates a file name, resolving it against the base directory.
+func (in *Interpreter) file(e Expr) (string, error) {
+	v, err := in.eval(e)
+	if err != nil {
+		return "", err
+	}
+	name, ok := v.(string)
+	if !ok {
+		return "", errorf(e.Pos(), nil, "expected a file name, found %v", typeOf(v))
+	}
+	if in.baseDir != "" && !filepath.IsAbs(name) {
+		name = filepath.Join(in.baseDir, name)
+	}
+	return name, nil
+}
+
+func (in *Interpreter) point(p Point) (image.Point, error) {
+	x, err := in.integer(p.X)
+	if err != nil {
+		return image.Point{}, err
+	}
+	y, err := in.integer(p.Y)
+	return image.Pt(x, y), err
+}
+
+// region evaluates a region, or returns the empty rectangle if r is nil.
+func (in *Interpreter) region(r *Region) (image.Rectangle, error) {
+	if r == nil {
+		return image.Rectangle{}, nil
+	}
+	var v [4]int
+	for i, e := range []Expr{r.X, r.Y, r.Width, r.Height} {
+		n, err := in.integer(e)
+		if err != nil {
+			return image.Rectangle{}, err
+		}
+		v[i] = n
+	}
+	if v[2] <= 0 || v[3] <= 0 {
+		return image.Rectangle{}, errorf(r.Width.Pos(), nil, "region %dx%d is empty", v[2], v[3])
+	}
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), nil
+}
+
+// buttonOrLeft returns button, or left if it is empty.
+func buttonOrLeft(button string) string {
+	if button == "" {
+		return "left"
+	}
+	return button
+}
@@ -0,0 +1,220 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"image"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// run parses and runs src against sink, returning what it printed.
+func run(t *testing.T, sink *FakeSink, src string) (string, error) {
+	t.Helper()
+	prog, err := Parse("test.gs", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var out strings.Builder
+	err = NewInterpreter(sink, WithOutput(&out)).Run(context.Background(), prog)
+	return out.String(), err
+}
+
+// calls returns the calls of sink whose operation is one of ops.
+func calls(sink *FakeSink, ops ...string) []string {
+	var out []string
+	for _, c := range sink.Calls {
+		op, _, _ := strings.Cut(c, " ")
+		if slices.Contains(ops, op) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"10 / 4", "2.5"},
+		{"10 - 2 - 3", "5"},
+		{"-3 + 5", "2"},
+		{"2 * 1s + 500ms", "2.5s"},
+		{"3s / 2", "1.5s"},
+		{"1s / 250ms", "4"},
+		{"\"x=\" + 1 + 2", "x=12"},
+		{"\"n\" + 2 * 3", "n6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			out, err := run(t, NewFakeSink(), "print "+tt.expr+"\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSuffix(out, "\n"); got != tt.want {
+				t.Errorf("print %s = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	_, err := run(t, NewFakeSink(), "set $x = 0\nprint 1 / $x\n")
+	var e *Error
+	if !errors.As(err, &e) || e.Pos.Line != 2 || e.Msg != "division by zero" {
+		t.Errorf("Run error %v, want division by zero on line 2", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	src := `set $x = 100
+repeat 2 as $i
+    click $x * $i, 200 right
+end
+wait image save.png timeout 5s
+if image visible save.png
+    click $image_x,$image_y
+end
+hotkey ctrl+s
+`
+	// save.png shows up after 2s
+	sink := NewFakeSink()
+	sink.Images["save.png"] = FakeImage{Bounds: image.Rect(10, 10, 30, 20), From: 2 * time.Second}
+	if _, err := run(t, sink, src); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"click 100,200 right x1", "click 200,200 right x1", "click 20,15 left x1", "hotkey [ctrl s]"}
+	if got := calls(sink, "click", "hotkey"); !slices.Equal(got, want) {
+		t.Errorf("calls %q, want %q", got, want)
+	}
+	if sink.Elapsed != 2*time.Second {
+		t.Errorf("elapsed %v, want 2s", sink.Elapsed)
+	}
+}
+
+func TestWaitImageTimeout(t *testing.T) {
+	sink := NewFakeSink()
+	sink.Images["save.png"] = FakeImage{Bounds: image.Rect(10, 10, 30, 20), From: 10 * time.Second}
+	_, err := run(t, sink, "click 1,1\nwait image save.png timeout 3s\nclick 2,2\n")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Run error %v, want ErrTimeout", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Pos.Line != 2 {
+		t.Errorf("Run error %v, want it on line 2", err)
+	}
+	if sink.Elapsed != 3*time.Second {
+		t.Errorf("elapsed %v, want 3s", sink.Elapsed)
+	}
+	if got := calls(sink, "click"); len(got) != 1 {
+		t.Errorf("calls %q, want the script to stop at the wait", got)
+	}
+}
+
+func TestWaitImageRegion(t *testing.T) {
+	// The image is on the screen but outside the region, so the wait times out
+	sink := NewFakeSink()
+	sink.Images["save.png"] = FakeImage{Bounds: image.Rect(500, 500, 520, 510)}
+	_, err := run(t, sink, "wait image save.png in 0,0,100,100 timeout 1s\n")
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Run error %v, want ErrTimeout", err)
+	}
+}
+
+func TestWhileAndElse(t *testing.T) {
+	src := `while not image visible done.png
+    wait 1s
+end
+if image visible error.png
+    press esc
+else
+    press enter
+end
+`
+	sink := NewFakeSink()
+	sink.Images["done.png"] = FakeImage{Bounds: image.Rect(0, 0, 10, 10), From: 3 * time.Second}
+	if _, err := run(t, sink, src); err != nil {
+		t.Fatal(err)
+	}
+	if sink.Elapsed != 3*time.Second {
+		t.Errorf("elapsed %v, want 3s", sink.Elapsed)
+	}
+	if got, want := calls(sink, "press"), []string{"press enter"}; !slices.Equal(got, want) {
+		t.Errorf("calls %q, want %q", got, want)
+	}
+}
+
+func TestSinkError(t *testing.T) {
+	sink := NewFakeSink()
+	failed := errors.New("no input desktop")
+	sink.Errors["click"] = failed
+	_, err := run(t, sink, "move 5,5\nclick 1,1\n")
+	var e *Error
+	if !errors.Is(err, failed) || !errors.As(err, &e) || e.Pos.Line != 2 {
+		t.Errorf("Run error %v, want the click failure on line 2", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	prog, err := Parse("test.gs", []byte("click 1,1\nclick 2,2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink := NewFakeSink()
+	if err := NewInterpreter(sink).Run(ctx, prog); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error %v, want context.Canceled", err)
+	}
+	if len(sink.Calls) != 0 {
+		t.Errorf("calls %q after cancelling, want none", sink.Calls)
+	}
+}
+
+// cancellingSink is a FakeSink that cancels the run after a number of presses.
+type cancellingSink struct {
+	*FakeSink
+	after  int
+	cancel context.CancelFunc
+}
+
+func (s *cancellingSink) Press(ctx context.Context, key string) error {
+	err := s.FakeSink.Press(ctx, key)
+	if len(s.Calls) == s.after {
+		s.cancel()
+	}
+	return err
+}
+
+func TestCancelPress(t *testing.T) {
+	prog, err := Parse("test.gs", []byte("press a times 99999999999\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sink := &cancellingSink{FakeSink: NewFakeSink(), after: 3, cancel: cancel}
+	if err := NewInterpreter(sink).Run(ctx, prog); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error %v, want context.Canceled", err)
+	}
+	if len(sink.Calls) != 3 {
+		t.Errorf("%d presses, want 3 before cancelling", len(sink.Calls))
+	}
+}
+
+func TestCancelEmptyRepeat(t *testing.T) {
+	prog, err := Parse("test.gs", []byte("repeat 99999999999\nend\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := NewInterpreter(NewFakeSink()).Run(ctx, prog); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run error %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,38 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// keyNames are the key names scripts can use with press and hotkey, besides single
+// characters. They are the names accepted by the windows package's KeyByName.
+var keyNames = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`
+		enter return tab space backspace esc escape delete del insert ins
+		home end pageup pgup pagedown pgdn up down left right
+		shift shiftleft shiftright ctrl control ctrlleft ctrlright alt altleft altright
+		win winleft winright apps capslock numlock scrolllock printscreen prtsc pause
+		add subtract multiply divide decimal separator
+		volumemute volumedown volumeup nexttrack prevtrack stop playpause
+		browserback browserforward browserrefresh browserhome`) {
+		keyNames[name] = true
+	}
+	for i := 1; i <= 24; i++ {
+		keyNames[fmt.Sprintf("f%d", i)] = true
+	}
+	for i := 0; i <= 9; i++ {
+		keyNames[fmt.Sprintf("num%d", i)] = true
+	}
+}
+
+// isKeyName reports whether name is a key name or a single printable character.
+func isKeyName(name string) bool {
+	if r := []rune(name); len(r) == 1 {
+		return unicode.IsPrint(r[0]) && !unicode.IsSpace(r[0])
+	}
+	return keyNames[strings.ToLower(name)]
+}
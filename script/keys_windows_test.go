@@ -0,0 +1,28 @@
+//go:build windows
+
+package script
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	goautogui "github.com/mhmdibrahimm/goautogui/windows"
+)
+
+// The script package checks key names on any platform, so it keeps its own copy of the
+// windows package's key names. They must not drift apart.
+func TestKeyNamesMatchWindows(t *testing.T) {
+	got := slices.Sorted(maps.Keys(keyNames))
+	want := goautogui.KeyNames()
+	for _, name := range got {
+		if !slices.Contains(want, name) {
+			t.Errorf("script accepts key %q, which the windows package does not have", name)
+		}
+	}
+	for _, name := range want {
+		if !keyNames[name] {
+			t.Errorf("script does not accept key %q of the windows package", name)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	tokEOF      tokenKind = iota
+	tokNewline            // end of a line
+	tokNumber             // 100, 1.5
+	tokDuration           // 500ms, 2s, 1.5m
+	tokString             // "quoted text"
+	tokWord               // bare word: command, keyword, key name or file name
+	tokVar                // $name
+	tokComma              // ,
+	tokArrow              // ->
+	tokAssign             // =
+	tokOp                 // + - * / == != < > <= >=
+	tokLParen             // (
+	tokRParen             // )
+)
+
+func (k tokenKind) String() string {
+	return [...]string{"end of file", "end of line", "number", "duration", "string", "word",
+		"variable", "','", "'->'", "'='", "operator", "'('", "')'"}[k]
+}
+
+// token is a lexical token.
+type token struct {
+	kind tokenKind
+	pos  Pos
+	text string        // source text; the operator for tokOp, the name without $ for tokVar
+	num  float64       // tokNumber
+	dur  time.Duration // tokDuration
+	str  string        // tokString, unquoted
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF, tokNewline:
+		return t.kind.String()
+	case tokVar:
+		return "$" + t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexer splits a script into tokens.
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+	errs ErrorList
+}
+
+// lex returns the tokens of src, which always end with tokEOF, and any lexical errors.
+func lex(filename, src string) ([]token, ErrorList) {
+	l := &lexer{src: src, line: 1, col: 1}
+	var toks []token
+	for {
+		t, ok := l.next()
+		if !ok {
+			continue
+		}
+		t.pos.Filename = filename
+		toks = append(toks, t)
+		if t.kind == tokEOF {
+			break
+		}
+	}
+	for _, e := range l.errs {
+		e.Pos.Filename = filename
+	}
+	return toks, l.errs
+}
+
+func (l *lexer) peek() rune {
+	r, _ := utf8.DecodeRuneInString(l.src[l.off:])
+	return r
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.off:])
+	l.off += size
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func (l *lexer) errorf(pos Pos, format string, args ...any) {
+	l.errs = append(l.errs, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// isWordStart reports whether r can start a bare word. A leading '/' is division, so
+// absolute paths have to be quoted.
+func isWordStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '.'
+}
+
+// isWordChar reports whether r can continue a bare word. Words can hold the characters of
+// key combinations such as ctrl+s and of file names such as images/save-button.png.
+func isWordChar(r rune) bool {
+	return isWordStart(r) || unicode.IsDigit(r) || strings.ContainsRune("+-:~/\\", r)
+}
+
+// next returns the next token. It reports false when it skipped invalid input.
+func (l *lexer) next() (token, bool) {
+	// Skip spaces and comments
+	for l.off < len(l.src) {
+		r := l.peek()
+		if r == '#' {
+			for l.off < len(l.src) && l.peek() != '\n' {
+				l.advance()
+			}
+		} else if r != '\n' && unicode.IsSpace(r) {
+			l.advance()
+		} else {
+			break
+		}
+	}
+
+	pos := Pos{Line: l.line, Col: l.col}
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}, true
+	}
+	start := l.off
+	r := l.advance()
+	switch {
+	case r == '\n':
+		return token{kind: tokNewline, pos: pos, text: "\n"}, true
+	case r == ',':
+		return token{kind: tokComma, pos: pos, text: ","}, true
+	case r == '(':
+		return token{kind: tokLParen, pos: pos, text: "("}, true
+	case r == ')':
+		return token{kind: tokRParen, pos: pos, text: ")"}, true
+	case r == '-' && l.peek() == '>':
+		l.advance()
+		return token{kind: tokArrow, pos: pos, text: "->"}, true
+	case r == '=' || r == '!' || r == '<' || r == '>':
+		if l.peek() == '=' {
+			l.advance()
+			return token{kind: tokOp, pos: pos, text: l.src[start:l.off]}, true
+		}
+		switch r {
+		case '=':
+			return token{kind: tokAssign, pos: pos, text: "="}, true
+		case '!':
+			l.errorf(pos, "unexpected '!'; did you mean '!='?")
+			return token{}, false
+		}
+		return token{kind: tokOp, pos: pos, text: string(r)}, true
+	case r == '+' || r == '-' || r == '*' || r == '/':
+		return token{kind: tokOp, pos: pos, text: string(r)}, true
+	case r == '"':
+		return l.lexString(pos, start)
+	case r == '$':
+		for l.off < len(l.src) && (unicode.IsLetter(l.peek()) || unicode.IsDigit(l.peek()) || l.peek() == '_') {
+			l.advance()
+		}
+		if l.off == start+1 {
+			l.errorf(pos, "expected a variable name after '$'")
+			return token{}, false
+		}
+		return token{kind: tokVar, pos: pos, text: l.src[start+1 : l.off]}, true
+	case unicode.IsDigit(r):
+		return l.lexNumber(pos, start)
+	case isWordStart(r):
+		for l.off < len(l.src) && isWordChar(l.peek()) {
+			l.advance()
+		}
+		return token{kind: tokWord, pos: pos, text: l.src[start:l.off]}, true
+	}
+	l.errorf(pos, "unexpected character %q", r)
+	return token{}, false
+}
+
+// lexString lexes a double-quoted string with Go escapes, whose opening quote has been read.
+func (l *lexer) lexString(pos Pos, start int) (token, bool) {
+	for {
+		if l.off >= len(l.src) || l.peek() == '\n' {
+			l.errorf(pos, "string not terminated")
+			return token{}, false
+		}
+		r := l.advance()
+		if r == '\\' && l.off < len(l.src) && l.peek() != '\n' {
+			l.advance()
+		} else if r == '"' {
+			break
+		}
+	}
+	text := l.src[start:l.off]
+	s, err := strconv.Unquote(text)
+	if err != nil {
+		l.errorf(pos, "invalid string %s", text)
+		return token{}, false
+	}
+	return token{kind: tokString, pos: pos, text: text, str: s}, true
+}
+
+// lexNumber lexes a number, or a duration if a unit follows it, whose first digit has been read.
+func (l *lexer) lexNumber(pos Pos, start int) (token, bool) {
+	for l.off < len(l.src) && (unicode.IsDigit(l.peek()) || l.peek() == '.') {
+		l.advance()
+	}
+	num := l.src[start:l.off]
+	unitStart := l.off
+	for l.off < len(l.src) && unicode.IsLetter(l.peek()) {
+		l.advance()
+	}
+	text := l.src[start:l.off]
+	if unit := l.src[unitStart:l.off]; unit != "" {
+		if unit != "ms" && unit != "s" && unit != "m" && unit != "h" {
+			l.errorf(pos, "invalid duration %s: the unit must be ms, s, m or h", text)
+			return token{}, false
+		}
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			l.errorf(pos, "invalid duration %s", text)
+			return token{}, false
+		}
+		return token{kind: tokDuration, pos: pos, text: text, dur: d}, true
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		l.errorf(pos, "invalid number %s", num)
+		return token{}, false
+	}
+	return token{kind: tokNumber, pos: pos, text: text, num: n}, true
+}
@@ -0,0 +1,477 @@
+package script
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Parse parses and checks a script. filename is only used in error positions and may be
+// empty. If the script has errors, Parse returns an ErrorList with at most one error per
+// line, in order.
+func Parse(filename string, src []byte) (*Program, error) {
+	toks, errs := lex(filename, string(src))
+	p := &parser{toks: toks, errs: errs}
+	stmts, _ := p.parseBlock(nil)
+	prog := &Program{Stmts: stmts}
+	p.errs = append(p.errs, check(prog)...)
+	if len(p.errs) > 0 {
+		return nil, p.errorList()
+	}
+	return prog, nil
+}
+
+// parser builds the syntax tree from the tokens of a script.
+type parser struct {
+	toks []token
+	i    int
+	errs ErrorList
+}
+
+// bailout is raised by fail to abandon the current statement.
+type bailout struct{}
+
+func (p *parser) tok() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// fail records an error at pos and abandons the current statement.
+func (p *parser) fail(pos Pos, format string, args ...any) {
+	p.errs = append(p.errs, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+	panic(bailout{})
+}
+
+// errorList sorts the errors and keeps the first of each line, since later errors on a
+// line are usually caused by the first.
+func (p *parser) errorList() ErrorList {
+	slices.SortStableFunc(p.errs, func(a, b *Error) int {
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line - b.Pos.Line
+		}
+		return a.Pos.Col - b.Pos.Col
+	})
+	return slices.CompactFunc(p.errs, func(a, b *Error) bool { return a.Pos.Line == b.Pos.Line })
+}
+
+// isWord reports whether the current token is the bare word w, ignoring case.
+func (p *parser) isWord(w string) bool {
+	t := p.tok()
+	return t.kind == tokWord && strings.EqualFold(t.text, w)
+}
+
+// expectWord consumes the bare word w.
+func (p *parser) expectWord(w string) {
+	if !p.isWord(w) {
+		p.fail(p.tok().pos, "expected %q, found %v", w, p.tok())
+	}
+	p.next()
+}
+
+// expect consumes a token of the given kind.
+func (p *parser) expect(kind tokenKind, context string) token {
+	t := p.tok()
+	if t.kind != kind {
+		p.fail(t.pos, "expected %v %s, found %v", kind, context, t)
+	}
+	return p.next()
+}
+
+// endLine consumes the end of a statement.
+func (p *parser) endLine(cmd token) {
+	t := p.tok()
+	if t.kind == tokEOF {
+		return
+	}
+	if t.kind != tokNewline {
+		p.fail(t.pos, "unexpected %v after %s", t, cmd.text)
+	}
+	p.next()
+}
+
+// skipLine skips to the start of the next line after an error.
+func (p *parser) skipLine() {
+	for t := p.next(); t.kind != tokNewline && t.kind != tokEOF; t = p.next() {
+	}
+}
+
+// parseBlock parses statements until one of the words in end, which it consumes and
+// returns, or the end of the file.
+func (p *parser) parseBlock(end []string) ([]Stmt, token) {
+	var stmts []Stmt
+	for {
+		t := p.tok()
+		switch {
+		case t.kind == tokNewline:
+			p.next()
+			continue
+		case t.kind == tokEOF:
+			return stmts, t
+		case t.kind == tokWord && slices.ContainsFunc(end, func(w string) bool { return strings.EqualFold(w, t.text) }):
+			p.next()
+			return stmts, t
+		}
+		if s := p.parseStmt(); s != nil {
+			stmts = append(stmts, s)
+		}
+	}
+}
+
+// parseStmt parses one statement, returning nil if it has errors.
+func (p *parser) parseStmt() (stmt Stmt) {
+	start := p.i
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			// Skip the rest of the line, unless the error was at its end
+			if p.i == start || p.toks[p.i-1].kind != tokNewline {
+				p.skipLine()
+			}
+			stmt = nil
+		}
+	}()
+
+	cmd := p.next()
+	if cmd.kind != tokWord {
+		p.fail(cmd.pos, "expected a command, found %v", cmd)
+	}
+	switch strings.ToLower(cmd.text) {
+	case "set":
+		name := p.expect(tokVar, "to set")
+		p.expect(tokAssign, "after the variable")
+		s := &SetStmt{At: cmd.pos, Name: name.text, Value: p.parseExpr()}
+		p.endLine(cmd)
+		return s
+
+	case "print":
+		s := &PrintStmt{At: cmd.pos, Value: p.parseExpr()}
+		p.endLine(cmd)
+		return s
+
+	case "move":
+		s := &MoveStmt{At: cmd.pos, To: p.parsePoint()}
+		if p.isWord("over") {
+			p.next()
+			s.Over = p.parseExpr()
+		}
+		p.endLine(cmd)
+		return s
+
+	case "click":
+		s := &ClickStmt{At: cmd.pos, Target: p.parsePoint()}
+		for p.tok().kind == tokWord {
+			t := p.tok()
+			switch word := strings.ToLower(t.text); {
+			case isButton(word):
+				s.Button = word
+				p.next()
+			case word == "double" || word == "triple":
+				s.Clicks = &NumberLit{At: t.pos, Value: map[string]float64{"double": 2, "triple": 3}[word]}
+				p.next()
+			case word == "times":
+				p.next()
+				s.Clicks = p.parseExpr()
+			default:
+				p.fail(t.pos, "unexpected %v after click; expected a button, double, triple or times", t)
+			}
+		}
+		p.endLine(cmd)
+		return s
+
+	case "drag":
+		s := &DragStmt{At: cmd.pos, From: p.parsePoint()}
+		p.expect(tokArrow, "between the drag points")
+		s.To = p.parsePoint()
+		for p.tok().kind == tokWord {
+			t := p.tok()
+			switch word := strings.ToLower(t.text); {
+			case isButton(word):
+				s.Button = word
+				p.next()
+			case word == "over":
+				p.next()
+				s.Over = p.parseExpr()
+			default:
+				p.fail(t.pos, "unexpected %v after drag; expected over or a button", t)
+			}
+		}
+		p.endLine(cmd)
+		return s
+
+	case "scroll", "hscroll":
+		s := &ScrollStmt{At: cmd.pos, Amount: p.parseExpr(), Horizontal: strings.EqualFold(cmd.text, "hscroll")}
+		if p.isWord("at") {
+			p.next()
+			pt := p.parsePoint()
+			s.Where = &pt
+		}
+		p.endLine(cmd)
+		return s
+
+	case "type":
+		s := &TypeStmt{At: cmd.pos, Text: p.parseExpr()}
+		p.endLine(cmd)
+		return s
+
+	case "press":
+		s := &PressStmt{At: cmd.pos, Key: p.parseKey()}
+		if p.isWord("times") {
+			p.next()
+			s.Times = p.parseExpr()
+		}
+		p.endLine(cmd)
+		return s
+
+	case "hotkey":
+		t := p.next()
+		if t.kind != tokWord && t.kind != tokNumber {
+			p.fail(t.pos, "expected keys such as ctrl+s, found %v", t)
+		}
+		s := &HotkeyStmt{At: cmd.pos}
+		col := t.pos.Col
+		for _, key := range strings.Split(t.text, "+") {
+			if !isKeyName(key) {
+				pos := t.pos
+				pos.Col = col
+				p.fail(pos, "unknown key %q", key)
+			}
+			s.Keys = append(s.Keys, strings.ToLower(key))
+			col += len([]rune(key)) + 1
+		}
+		p.endLine(cmd)
+		return s
+
+	case "wait":
+		if p.isWord("image") {
+			p.next()
+			s := &WaitImageStmt{At: cmd.pos, Image: p.parseImageMatch(true)}
+			p.endLine(cmd)
+			return s
+		}
+		s := &WaitStmt{At: cmd.pos, Duration: p.parseExpr()}
+		p.endLine(cmd)
+		return s
+
+	case "screenshot":
+		s := &ScreenshotStmt{At: cmd.pos, File: p.parseFile()}
+		if p.isWord("in") {
+			p.next()
+			r := p.parseRegion()
+			s.Region = &r
+		}
+		p.endLine(cmd)
+		return s
+
+	case "repeat":
+		s := &RepeatStmt{At: cmd.pos, Count: p.parseExpr()}
+		if p.isWord("as") {
+			p.next()
+			s.Var = p.expect(tokVar, "after as").text
+		}
+		p.endLine(cmd)
+		s.Body = p.parseBody(cmd)
+		return s
+
+	case "while":
+		s := &WhileStmt{At: cmd.pos, Cond: p.parseCond()}
+		p.endLine(cmd)
+		s.Body = p.parseBody(cmd)
+		return s
+
+	case "if":
+		s := &IfStmt{At: cmd.pos, Cond: p.parseCond()}
+		p.endLine(cmd)
+		var end token
+		s.Then, end = p.parseBlock([]string{"else", "end"})
+		if end.kind == tokEOF {
+			p.fail(cmd.pos, "if without end")
+		}
+		if strings.EqualFold(end.text, "else") {
+			p.endLine(end)
+			s.Else = p.parseBody(cmd)
+			return s
+		}
+		p.endLine(end)
+		return s
+
+	case "end", "else":
+		p.fail(cmd.pos, "%s without a matching if, repeat or while", cmd.text)
+	}
+	p.fail(cmd.pos, "unknown command %q", cmd.text)
+	return nil
+}
+
+// parseBody parses the statements of a block up to its end.
+func (p *parser) parseBody(cmd token) []Stmt {
+	body, end := p.parseBlock([]string{"end"})
+	if end.kind == tokEOF {
+		p.fail(cmd.pos, "%s without end", cmd.text)
+	}
+	p.endLine(end)
+	return body
+}
+
+// parseCond parses the condition of an if or while.
+func (p *parser) parseCond() Cond {
+	pos := p.tok().pos
+	if p.isWord("not") || p.isWord("image") {
+		c := &ImageCond{At: pos, Not: p.isWord("not")}
+		if c.Not {
+			p.next()
+		}
+		p.expectWord("image")
+		p.expectWord("visible")
+		c.Image = p.parseImageMatch(false)
+		return c
+	}
+	x := p.parseExpr()
+	op := p.tok()
+	switch op.text {
+	case "==", "!=", "<", ">", "<=", ">=":
+	default:
+		p.fail(op.pos, "expected a comparison such as == or <, found %v", op)
+	}
+	p.next()
+	return &BinaryExpr{At: x.Pos(), Op: op.text, X: x, Y: p.parseExpr()}
+}
+
+// parseImageMatch parses File [in Region] [tolerance N] [timeout Duration].
+func (p *parser) parseImageMatch(timeout bool) ImageMatch {
+	m := ImageMatch{File: p.parseFile()}
+	for p.tok().kind == tokWord {
+		t := p.tok()
+		switch strings.ToLower(t.text) {
+		case "in":
+			p.next()
+			r := p.parseRegion()
+			m.Region = &r
+		case "tolerance":
+			p.next()
+			m.Tolerance = p.parseExpr()
+		case "timeout":
+			if !timeout {
+				p.fail(t.pos, "timeout is only allowed on wait image")
+			}
+			p.next()
+			m.Timeout = p.parseExpr()
+		default:
+			return m
+		}
+	}
+	return m
+}
+
+// parseKey parses a key name.
+func (p *parser) parseKey() string {
+	t := p.next()
+	var key string
+	switch t.kind {
+	case tokWord, tokNumber:
+		key = t.text
+	case tokString:
+		key = t.str
+	default:
+		p.fail(t.pos, "expected a key such as enter or a, found %v", t)
+	}
+	if !isKeyName(key) {
+		p.fail(t.pos, "unknown key %q", key)
+	}
+	if len([]rune(key)) > 1 {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// parseFile parses a file name: a bare word such as save.png or an expression.
+func (p *parser) parseFile() Expr {
+	if t := p.tok(); t.kind == tokWord {
+		p.next()
+		return &StringLit{At: t.pos, Value: t.text}
+	}
+	return p.parseExpr()
+}
+
+// parsePoint parses X,Y.
+func (p *parser) parsePoint() Point {
+	x := p.parseExpr()
+	p.expect(tokComma, "between x and y")
+	return Point{X: x, Y: p.parseExpr()}
+}
+
+// parseRegion parses X,Y,Width,Height.
+func (p *parser) parseRegion() Region {
+	var r Region
+	r.X = p.parseExpr()
+	p.expect(tokComma, "in the region x,y,width,height")
+	r.Y = p.parseExpr()
+	p.expect(tokComma, "in the region x,y,width,height")
+	r.Width = p.parseExpr()
+	p.expect(tokComma, "in the region x,y,width,height")
+	r.Height = p.parseExpr()
+	return r
+}
+
+// parseExpr parses a sum or difference of terms.
+func (p *parser) parseExpr() Expr {
+	x := p.parseTerm()
+	for t := p.tok(); t.kind == tokOp && (t.text == "+" || t.text == "-"); t = p.tok() {
+		p.next()
+		x = &BinaryExpr{At: t.pos, Op: t.text, X: x, Y: p.parseTerm()}
+	}
+	return x
+}
+
+// parseTerm parses a product or quotient of factors.
+func (p *parser) parseTerm() Expr {
+	x := p.parseFactor()
+	for t := p.tok(); t.kind == tokOp && (t.text == "*" || t.text == "/"); t = p.tok() {
+		p.next()
+		x = &BinaryExpr{At: t.pos, Op: t.text, X: x, Y: p.parseFactor()}
+	}
+	return x
+}
+
+// parseFactor parses a literal, a variable, a negation or a parenthesised expression.
+func (p *parser) parseFactor() Expr {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &NumberLit{At: t.pos, Value: t.num}
+	case tokDuration:
+		return &DurationLit{At: t.pos, Value: t.dur}
+	case tokString:
+		return &StringLit{At: t.pos, Value: t.str}
+	case tokVar:
+		return &VarRef{At: t.pos, Name: t.text}
+	case tokOp:
+		if t.text == "-" {
+			return &UnaryExpr{At: t.pos, Op: "-", X: p.parseFactor()}
+		}
+	case tokLParen:
+		x := p.parseExpr()
+		p.expect(tokRParen, "to close '('")
+		return x
+	case tokWord:
+		p.fail(t.pos, "unexpected word %q; put text in quotes and variables after $", t.text)
+	}
+	p.fail(t.pos, "expected a value, found %v", t)
+	return nil
+}
+
+// isButton reports whether w is a mouse button name.
+func isButton(w string) bool {
+	switch w {
+	case "left", "right", "middle", "x1", "x2":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package script
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"wait without unit", "click 10,20\nwait 5\n", "bad.gs:2:6: wait needs a duration with a unit, such as 2s or 500ms"},
+		{"unknown command", "jump 10,20\n", `bad.gs:1:1: unknown command "jump"`},
+		{"missing end", "repeat 2\n    click 1,1\n", "bad.gs:1:1: repeat without end"},
+		{"unset variable", "click $x,1\n", "bad.gs:1:7: variable $x is used before it is set"},
+		{"unknown key", "press notakey\n", `bad.gs:1:7: unknown key "notakey"`},
+		{"string as number", "click \"a\",1\n", "bad.gs:1:7: expected a number, found a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("bad.gs", []byte(tt.src))
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want an error", tt.src)
+			}
+			var list ErrorList
+			if !errors.As(err, &list) || len(list) == 0 {
+				t.Fatalf("Parse error %T, want an ErrorList", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Parse error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReportsEveryError(t *testing.T) {
+	_, err := Parse("bad.gs", []byte("wait 5\nclick 1,1\nwait 3\n"))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("Parse error %v, want an ErrorList", err)
+	}
+	if len(list) != 2 || list[0].Pos.Line != 1 || list[1].Pos.Line != 3 {
+		t.Errorf("Parse errors %v, want one on line 1 and one on line 3", list)
+	}
+}
@@ -0,0 +1,149 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"time"
+)
+
+// Sink performs the input and screen operations of a script. The windows implementation is
+// WindowsSink; FakeSink records the operations instead, for testing scripts and the
+// interpreter without touching the real mouse and keyboard.
+type Sink interface {
+	// Position returns the cursor position.
+	Position() (image.Point, error)
+	// Move moves the cursor to p, gliding over the duration if it is not 0.
+	Move(ctx context.Context, p image.Point, over time.Duration) error
+	// Click clicks button clicks times at p. button is left, right, middle, x1 or x2.
+	Click(p image.Point, button string, clicks int) error
+	// Drag drags with button from one point to another, over the duration if it is not 0.
+	Drag(ctx context.Context, from, to image.Point, over time.Duration, button string) error
+	// Scroll scrolls notches at p, vertically or horizontally.
+	Scroll(p image.Point, notches float64, horizontal bool) error
+	// Type types text.
+	Type(ctx context.Context, text string) error
+	// Press presses and releases a key, given by name or as a single character.
+	Press(ctx context.Context, key string) error
+	// Hotkey presses keys in order and releases them in reverse.
+	Hotkey(ctx context.Context, keys []string) error
+	// Sleep pauses for d.
+	Sleep(ctx context.Context, d time.Duration) error
+	// Now returns the current time, which wait image timeouts are measured with.
+	Now() time.Time
+	// FindImage looks for the image in file in the region of the screen, the primary
+	// display if region is empty, allowing each channel to differ by up to tolerance.
+	FindImage(file string, region image.Rectangle, tolerance uint8) (image.Rectangle, bool, error)
+	// Screenshot saves the region of the screen, the primary display if region is empty, to file.
+	Screenshot(file string, region image.Rectangle) error
+}
+
+// FakeImage is an image FakeSink finds on its pretend screen.
+type FakeImage struct {
+	Bounds image.Rectangle
+	From   time.Duration // visible once Elapsed reaches From
+	Until  time.Duration // visible while Elapsed is less than Until, or forever if 0
+}
+
+// FakeSink is a Sink that records the operations it is asked to perform. Time only passes
+// when the script sleeps, so scripts with waits run instantly.
+type FakeSink struct {
+	Calls   []string             // the operations performed, such as "click 100,200 left x1"
+	Cursor  image.Point          // moved by move, click, drag and scroll
+	Elapsed time.Duration        // total time slept
+	Images  map[string]FakeImage // by file name, as the interpreter passes it
+	Errors  map[string]error     // makes the operations with these names, such as "click", fail
+}
+
+// NewFakeSink creates a FakeSink with no images.
+func NewFakeSink() *FakeSink {
+	return &FakeSink{Images: map[string]FakeImage{}, Errors: map[string]error{}}
+}
+
+// record appends a call and returns the error configured for op.
+func (f *FakeSink) record(op string, format string, args ...any) error {
+	call := op
+	if format != "" {
+		call += " " + fmt.Sprintf(format, args...)
+	}
+	f.Calls = append(f.Calls, call)
+	return f.Errors[op]
+}
+
+func fakePoint(p image.Point) string {
+	return fmt.Sprintf("%d,%d", p.X, p.Y)
+}
+
+func (f *FakeSink) Position() (image.Point, error) {
+	return f.Cursor, nil
+}
+
+func (f *FakeSink) Move(ctx context.Context, p image.Point, over time.Duration) error {
+	f.Cursor = p
+	f.Elapsed += over
+	return f.record("move", "%s over %v", fakePoint(p), over)
+}
+
+func (f *FakeSink) Click(p image.Point, button string, clicks int) error {
+	f.Cursor = p
+	return f.record("click", "%s %s x%d", fakePoint(p), button, clicks)
+}
+
+func (f *FakeSink) Drag(ctx context.Context, from, to image.Point, over time.Duration, button string) error {
+	f.Cursor = to
+	f.Elapsed += over
+	return f.record("drag", "%s -> %s over %v %s", fakePoint(from), fakePoint(to), over, button)
+}
+
+func (f *FakeSink) Scroll(p image.Point, notches float64, horizontal bool) error {
+	f.Cursor = p
+	op := "scroll"
+	if horizontal {
+		op = "hscroll"
+	}
+	return f.record(op, "%g at %s", notches, fakePoint(p))
+}
+
+func (f *FakeSink) Type(ctx context.Context, text string) error {
+	return f.record("type", "%q", text)
+}
+
+func (f *FakeSink) Press(ctx context.Context, key string) error {
+	return f.record("press", "%s", key)
+}
+
+func (f *FakeSink) Hotkey(ctx context.Context, keys []string) error {
+	return f.record("hotkey", "%v", keys)
+}
+
+func (f *FakeSink) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.Elapsed += d
+	return f.record("sleep", "%v", d)
+}
+
+// Now returns the zero time plus Elapsed.
+func (f *FakeSink) Now() time.Time {
+	return time.Time{}.Add(f.Elapsed)
+}
+
+func (f *FakeSink) FindImage(file string, region image.Rectangle, tolerance uint8) (image.Rectangle, bool, error) {
+	img, ok := f.Images[file]
+	visible := ok && f.Elapsed >= img.From && (img.Until == 0 || f.Elapsed < img.Until)
+	if visible && !region.Empty() && !img.Bounds.In(region) {
+		visible = false
+	}
+	if err := f.record("find", "%s %v", file, visible); err != nil {
+		return image.Rectangle{}, false, err
+	}
+	if !visible {
+		return image.Rectangle{}, false, nil
+	}
+	return img.Bounds, true, nil
+}
+
+func (f *FakeSink) Screenshot(file string, region image.Rectangle) error {
+	return f.record("screenshot", "%s %v", file, region)
+}
@@ -0,0 +1,170 @@
+//go:build windows
+
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"sync"
+	"time"
+
+	goautogui "github.com/mhmdibrahimm/goautogui/windows"
+)
+
+// WindowsSink is the Sink that performs scripts with the windows package, running each
+// operation as an action of a goautogui.Player.
+type WindowsSink struct {
+	player *goautogui.Player
+
+	mu     sync.Mutex
+	images map[string]image.Image // decoded image files, by name
+}
+
+// NewWindowsSink creates a WindowsSink whose player is configured with opts, e.g.
+// goautogui.WithSpeed or goautogui.WithSmoothing.
+func NewWindowsSink(opts ...goautogui.PlayerOption) *WindowsSink {
+	return &WindowsSink{player: goautogui.NewPlayer(opts...), images: map[string]image.Image{}}
+}
+
+var sinkButtons = map[string]goautogui.MouseButton{
+	"left":   goautogui.MouseLeftButton,
+	"right":  goautogui.MouseRightButton,
+	"middle": goautogui.MouseMiddleButton,
+	"x1":     goautogui.MouseX1Button,
+	"x2":     goautogui.MouseX2Button,
+}
+
+// run runs a single action, returning the error of the action itself rather than a
+// goautogui.StepError.
+func (s *WindowsSink) run(ctx context.Context, a goautogui.Action) error {
+	_, err := s.player.Run(ctx, []goautogui.Action{a})
+	var stepErr *goautogui.StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Err
+	}
+	return err
+}
+
+func button(name string) (goautogui.MouseButton, error) {
+	b, ok := sinkButtons[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown mouse button %q", name)
+	}
+	return b, nil
+}
+
+func key(name string) (goautogui.KeyboardKeys, error) {
+	k, ok := goautogui.KeyByName(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown key %q", name)
+	}
+	return k, nil
+}
+
+func (s *WindowsSink) Position() (image.Point, error) {
+	p := goautogui.Position()
+	return image.Pt(p.X, p.Y), nil
+}
+
+func (s *WindowsSink) Move(ctx context.Context, p image.Point, over time.Duration) error {
+	return s.run(ctx, goautogui.Action{Kind: goautogui.ActionMove, Point: p, Duration: over})
+}
+
+func (s *WindowsSink) Click(p image.Point, buttonName string, clicks int) error {
+	b, err := button(buttonName)
+	if err != nil {
+		return err
+	}
+	return s.run(context.Background(), goautogui.Action{Kind: goautogui.ActionClick, Point: p, Button: b, Clicks: clicks})
+}
+
+func (s *WindowsSink) Drag(ctx context.Context, from, to image.Point, over time.Duration, buttonName string) error {
+	b, err := button(buttonName)
+	if err != nil {
+		return err
+	}
+	return s.run(ctx, goautogui.Action{Kind: goautogui.ActionDrag, Path: []image.Point{from, to}, Button: b, Duration: over})
+}
+
+func (s *WindowsSink) Scroll(p image.Point, notches float64, horizontal bool) error {
+	return s.run(context.Background(), goautogui.Action{Kind: goautogui.ActionScroll, Point: p, Amount: notches, Horizontal: horizontal})
+}
+
+func (s *WindowsSink) Type(ctx context.Context, text string) error {
+	return s.run(ctx, goautogui.Action{Kind: goautogui.ActionType, Text: text})
+}
+
+func (s *WindowsSink) Press(ctx context.Context, name string) error {
+	k, err := key(name)
+	if err != nil {
+		return err
+	}
+	return s.run(ctx, goautogui.Action{Kind: goautogui.ActionKey, Key: k})
+}
+
+func (s *WindowsSink) Hotkey(ctx context.Context, names []string) error {
+	keys := make([]goautogui.KeyboardKeys, len(names))
+	for i, name := range names {
+		k, err := key(name)
+		if err != nil {
+			return err
+		}
+		keys[i] = k
+	}
+	return s.run(ctx, goautogui.Action{Kind: goautogui.ActionHotkey, Keys: keys})
+}
+
+// Sleep pauses for d divided by the player's speed.
+func (s *WindowsSink) Sleep(ctx context.Context, d time.Duration) error {
+	return s.run(ctx, goautogui.Action{Kind: goautogui.ActionWait, Duration: d})
+}
+
+func (s *WindowsSink) Now() time.Time {
+	return time.Now()
+}
+
+func (s *WindowsSink) FindImage(file string, region image.Rectangle, tolerance uint8) (image.Rectangle, bool, error) {
+	needle, err := s.image(file)
+	if err != nil {
+		return image.Rectangle{}, false, err
+	}
+	if region.Empty() {
+		screen := goautogui.GetScreenDimensions()
+		region = image.Rect(0, 0, screen.X, screen.Y)
+	}
+	r, err := goautogui.LocateOnScreen(region, needle, tolerance)
+	if errors.Is(err, goautogui.ErrImageNotFound) {
+		return image.Rectangle{}, false, nil
+	}
+	return r, err == nil, err
+}
+
+// image returns the decoded image in file, loading it on first use.
+func (s *WindowsSink) image(file string) (image.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if img, ok := s.images[file]; ok {
+		return img, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %v", file, err)
+	}
+	s.images[file] = img
+	return img, nil
+}
+
+func (s *WindowsSink) Screenshot(file string, region image.Rectangle) error {
+	_, err := goautogui.Screenshot(region, file)
+	return err
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is wrapped by Backend errors for a window that does not exist. The server
+// answers them with 404 Not Found.
+var ErrNotFound = errors.New("not found")
+
+// Window describes a top-level window.
+type Window struct {
+	Hwnd       uint64
+	Title      string
+	Class      string
+	PID        uint32
+	Bounds     image.Rectangle
+	Minimized  bool
+	Foreground bool
+}
+
+// Backend performs the requests of a Server. The windows implementation is WindowsBackend;
+// FakeBackend pretends instead, for testing clients and the server without touching the
+// real mouse and keyboard.
+//
+// The server calls the input methods, from Move to ActivateWindow, one at a time in the
+// order the requests arrived; the others may be called concurrently with them.
+type Backend interface {
+	// Position returns the cursor position.
+	Position() (image.Point, error)
+	// Move moves the cursor to p, gliding over the duration if it is not 0.
+	Move(ctx context.Context, p image.Point, over time.Duration) error
+	// Click clicks button clicks times at p. button is left, right, middle, x1 or x2.
+	Click(ctx context.Context, p image.Point, button string, clicks int) error
+	// Drag drags with button from one point to another, over the duration if it is not 0.
+	Drag(ctx context.Context, from, to image.Point, over time.Duration, button string) error
+	// Scroll scrolls notches at p, vertically or horizontally.
+	Scroll(ctx context.Context, p image.Point, notches float64, horizontal bool) error
+	// Type types text, pausing for interval between characters.
+	Type(ctx context.Context, text string, interval time.Duration) error
+	// Press presses and releases a key presses times. The key is given by name, as in
+	// pyautogui, or as a single character.
+	Press(ctx context.Context, key string, presses int) error
+	// Hotkey presses keys in order and releases them in reverse.
+	Hotkey(ctx context.Context, keys []string) error
+	// ActivateWindow brings the window to the foreground, restoring it if it is minimized.
+	ActivateWindow(hwnd uint64) error
+
+	// Displays returns the bounds of every display; the first is the primary display.
+	Displays() ([]image.Rectangle, error)
+	// Screenshot captures the region of the screen, the primary display if region is empty.
+	Screenshot(region image.Rectangle) (image.Image, error)
+	// Pixel returns the colour of the screen pixel at p.
+	Pixel(p image.Point) (color.RGBA, error)
+	// Locate looks for needle in the region of the screen, the primary display if region is
+	// empty, allowing each channel to differ by up to tolerance.
+	Locate(needle image.Image, region image.Rectangle, tolerance uint8) (image.Rectangle, bool, error)
+	// Windows returns the visible top-level windows with a title, front to back.
+	Windows() ([]Window, error)
+}
+
+// FailSafeBackend is a Backend with a fail-safe, such as pyautogui's, that lets a person
+// take back control by throwing the mouse into a corner.
+type FailSafeBackend interface {
+	Backend
+	// FailSafeTriggered reports whether the fail-safe is enabled and triggered, so input
+	// must be refused.
+	FailSafeTriggered() bool
+}
+
+// FakeBackend is a LiveBackend and FailSafeBackend with a pretend screen and windows that
+// records the input it is asked to perform.
+type FakeBackend struct {
+	mu          sync.Mutex
+	Calls       []string         // the input performed, such as "click 100,200 left x1"
+	Cursor      image.Point      // moved by move, click, drag and scroll
+	Screen      *image.RGBA      // the primary display, and the only one; see UpdateScreen
+	WindowList  []Window         // returned by Windows
+	Delay       time.Duration    // how long each input method takes
+	Errors      map[string]error // makes the methods with these names, such as "click", fail
+	Overlapped  bool             // set if two input methods ever ran at the same time
+	FailSafe    bool             // reported by FailSafeTriggered
+	inputActive int
+}
+
+// NewFakeBackend creates a FakeBackend with a black screen of the given size.
+func NewFakeBackend(width, height int) *FakeBackend {
+	screen := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(screen, screen.Bounds(), image.Black, image.Point{}, draw.Src)
+	return &FakeBackend{Screen: screen, Errors: map[string]error{}}
+}
+
+// input records an input call after Delay, returning the error configured for op.
+func (f *FakeBackend) input(ctx context.Context, op string, format string, args ...any) error {
+	f.mu.Lock()
+	f.inputActive++
+	if f.inputActive > 1 {
+		f.Overlapped = true
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inputActive--
+		f.mu.Unlock()
+	}()
+
+	if f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	call := op
+	if format != "" {
+		call += " " + fmt.Sprintf(format, args...)
+	}
+	f.Calls = append(f.Calls, call)
+	return f.Errors[op]
+}
+
+// FailSafeTriggered returns FailSafe. The FakeBackend does not refuse input itself.
+func (f *FakeBackend) FailSafeTriggered() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.FailSafe
+}
+
+// CallLog returns a copy of Calls, which is safe while requests are being served.
+func (f *FakeBackend) CallLog() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.Calls...)
+}
+
+func (f *FakeBackend) moveCursor(p image.Point) {
+	f.mu.Lock()
+	f.Cursor = p
+	f.mu.Unlock()
+}
+
+func fakePoint(p image.Point) string {
+	return fmt.Sprintf("%d,%d", p.X, p.Y)
+}
+
+func (f *FakeBackend) Position() (image.Point, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Cursor, nil
+}
+
+func (f *FakeBackend) Move(ctx context.Context, p image.Point, over time.Duration) error {
+	f.moveCursor(p)
+	return f.input(ctx, "move", "%s over %v", fakePoint(p), over)
+}
+
+func (f *FakeBackend) Click(ctx context.Context, p image.Point, button string, clicks int) error {
+	f.moveCursor(p)
+	return f.input(ctx, "click", "%s %s x%d", fakePoint(p), button, clicks)
+}
+
+func (f *FakeBackend) Drag(ctx context.Context, from, to image.Point, over time.Duration, button string) error {
+	f.moveCursor(to)
+	return f.input(ctx, "drag", "%s -> %s over %v %s", fakePoint(from), fakePoint(to), over, button)
+}
+
+func (f *FakeBackend) Scroll(ctx context.Context, p image.Point, notches float64, horizontal bool) error {
+	f.moveCursor(p)
+	op := "scroll"
+	if horizontal {
+		op = "hscroll"
+	}
+	return f.input(ctx, op, "%g at %s", notches, fakePoint(p))
+}
+
+func (f *FakeBackend) Type(ctx context.Context, text string, interval time.Duration) error {
+	return f.input(ctx, "type", "%q", text)
+}
+
+func (f *FakeBackend) Press(ctx context.Context, key string, presses int) error {
+	return f.input(ctx, "press", "%s x%d", key, presses)
+}
+
+func (f *FakeBackend) Hotkey(ctx context.Context, keys []string) error {
+	return f.input(ctx, "hotkey", "%v", keys)
+}
+
+func (f *FakeBackend) ActivateWindow(hwnd uint64) error {
+	f.mu.Lock()
+	i := slices.IndexFunc(f.WindowList, func(w Window) bool { return w.Hwnd == hwnd })
+	if i >= 0 {
+		for j := range f.WindowList {
+			f.WindowList[j].Foreground = j == i
+		}
+		f.WindowList[i].Minimized = false
+	}
+	f.mu.Unlock()
+	if i < 0 {
+		return fmt.Errorf("window %#x: %w", hwnd, ErrNotFound)
+	}
+	return f.input(context.Background(), "activate", "%#x", hwnd)
+}
+
+// UpdateScreen calls fn with Screen, which it may draw on or replace, while no capture is
+// reading it. Use it to change the screen while requests may be served, such as during a
+// live view.
+func (f *FakeBackend) UpdateScreen(fn func(screen *image.RGBA) *image.RGBA) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Screen = fn(f.Screen)
+}
+
+func (f *FakeBackend) Displays() ([]image.Rectangle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return []image.Rectangle{f.Screen.Bounds()}, nil
+}
+
+func (f *FakeBackend) Screenshot(region image.Rectangle) (image.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if region.Empty() {
+		region = f.Screen.Bounds()
+	}
+	if !region.In(f.Screen.Bounds()) {
+		return nil, fmt.Errorf("capture region %v is off-screen", region)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(img, img.Bounds(), f.Screen, region.Min, draw.Src)
+	return img, nil
+}
+
+func (f *FakeBackend) Pixel(p image.Point) (color.RGBA, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !p.In(f.Screen.Bounds()) {
+		return color.RGBA{}, fmt.Errorf("pixel %v is off-screen", p)
+	}
+	return f.Screen.RGBAAt(p.X, p.Y), nil
+}
+
+// Locate searches the pretend screen pixel by pixel, which is only fast enough for the
+// small screens of tests.
+func (f *FakeBackend) Locate(needle image.Image, region image.Rectangle, tolerance uint8) (image.Rectangle, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if region.Empty() {
+		region = f.Screen.Bounds()
+	}
+	region = region.Intersect(f.Screen.Bounds())
+	size := needle.Bounds().Size()
+	for y := region.Min.Y; y+size.Y <= region.Max.Y; y++ {
+		for x := region.Min.X; x+size.X <= region.Max.X; x++ {
+			if f.matchAt(needle, image.Pt(x, y), tolerance) {
+				return image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x, y).Add(size)}, true, nil
+			}
+		}
+	}
+	return image.Rectangle{}, false, nil
+}
+
+func (f *FakeBackend) matchAt(needle image.Image, at image.Point, tolerance uint8) bool {
+	b := needle.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := color.RGBAModel.Convert(needle.At(x, y)).(color.RGBA)
+			got := f.Screen.RGBAAt(at.X+x-b.Min.X, at.Y+y-b.Min.Y)
+			if !near(got.R, want.R, tolerance) || !near(got.G, want.G, tolerance) || !near(got.B, want.B, tolerance) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func near(a, b, tolerance uint8) bool {
+	if a > b {
+		a, b = b, a
+	}
+	return b-a <= tolerance
+}
+
+func (f *FakeBackend) Windows() ([]Window, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Window(nil), f.WindowList...), nil
+}
+
+// CaptureDisplay returns a copy of Screen for display 0.
+func (f *FakeBackend) CaptureDisplay(index int) (image.Image, error) {
+	if index != 0 {
+		return nil, fmt.Errorf("there is no display %d", index)
+	}
+	return f.Screenshot(image.Rectangle{})
+}
+
+func (f *FakeBackend) RawMove(p image.Point) error {
+	f.moveCursor(p)
+	return f.input(context.Background(), "rawmove", "%s", fakePoint(p))
+}
+
+func (f *FakeBackend) RawButton(p image.Point, button string, down bool) error {
+	f.moveCursor(p)
+	return f.input(context.Background(), "rawbutton", "%s %s %s", fakePoint(p), button, upDown(down))
+}
+
+func (f *FakeBackend) RawWheel(p image.Point, notches float64, horizontal bool) error {
+	f.moveCursor(p)
+	return f.input(context.Background(), "rawwheel", "%g at %s horizontal=%v", notches, fakePoint(p), horizontal)
+}
+
+func (f *FakeBackend) RawKey(key string, down bool) error {
+	return f.input(context.Background(), "rawkey", "%s %s", key, upDown(down))
+}
+
+func upDown(down bool) string {
+	if down {
+		return "down"
+	}
+	return "up"
+}
@@ -0,0 +1,219 @@
+//go:build windows
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	goautogui "github.com/mhmdibrahimm/goautogui/windows"
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// WindowsBackend is the LiveBackend and FailSafeBackend that performs requests with the windows package, running
+// each input request as actions of a goautogui.Player.
+type WindowsBackend struct {
+	player *goautogui.Player
+}
+
+// NewWindowsBackend creates a WindowsBackend whose player is configured with opts, e.g.
+// goautogui.WithFailSafe or goautogui.WithSmoothing. With goautogui.WithFailSafe, the raw
+// input of the live view is refused too while the cursor is in a corner, except releases.
+func NewWindowsBackend(opts ...goautogui.PlayerOption) *WindowsBackend {
+	return &WindowsBackend{player: goautogui.NewPlayer(opts...)}
+}
+
+var backendButtons = map[string]goautogui.MouseButton{
+	"left":   goautogui.MouseLeftButton,
+	"right":  goautogui.MouseRightButton,
+	"middle": goautogui.MouseMiddleButton,
+	"x1":     goautogui.MouseX1Button,
+	"x2":     goautogui.MouseX2Button,
+}
+
+// run runs actions, returning the error of the failed action rather than a
+// goautogui.StepError.
+func (b *WindowsBackend) run(ctx context.Context, actions ...goautogui.Action) error {
+	_, err := b.player.Run(ctx, actions)
+	var stepErr *goautogui.StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Err
+	}
+	return err
+}
+
+func key(name string) (goautogui.KeyboardKeys, error) {
+	k, ok := goautogui.KeyByName(name)
+	if !ok {
+		return 0, badRequest("unknown key %q", name)
+	}
+	return k, nil
+}
+
+func (b *WindowsBackend) Position() (image.Point, error) {
+	p := goautogui.Position()
+	return image.Pt(p.X, p.Y), nil
+}
+
+func (b *WindowsBackend) Move(ctx context.Context, p image.Point, over time.Duration) error {
+	return b.run(ctx, goautogui.Action{Kind: goautogui.ActionMove, Point: p, Duration: over})
+}
+
+func (b *WindowsBackend) Click(ctx context.Context, p image.Point, button string, clicks int) error {
+	return b.run(ctx, goautogui.Action{Kind: goautogui.ActionClick, Point: p, Button: backendButtons[button], Clicks: clicks})
+}
+
+func (b *WindowsBackend) Drag(ctx context.Context, from, to image.Point, over time.Duration, button string) error {
+	return b.run(ctx, goautogui.Action{Kind: goautogui.ActionDrag, Path: []image.Point{from, to}, Button: backendButtons[button], Duration: over})
+}
+
+func (b *WindowsBackend) Scroll(ctx context.Context, p image.Point, notches float64, horizontal bool) error {
+	return b.run(ctx, goautogui.Action{Kind: goautogui.ActionScroll, Point: p, Amount: notches, Horizontal: horizontal})
+}
+
+func (b *WindowsBackend) Type(ctx context.Context, text string, interval time.Duration) error {
+	return b.run(ctx, goautogui.Action{Kind: goautogui.ActionType, Text: text, Duration: interval})
+}
+
+func (b *WindowsBackend) Press(ctx context.Context, name string, presses int) error {
+	k, err := key(name)
+	if err != nil {
+		return err
+	}
+	actions := make([]goautogui.Action, presses)
+	for i := range actions {
+		actions[i] = goautogui.Action{Kind: goautogui.ActionKey, Key: k}
+	}
+	return b.run(ctx, actions...)
+}
+
+func (b *WindowsBackend) Hotkey(ctx context.Context, names []string) error {
+	keys := make([]goautogui.KeyboardKeys, len(names))
+	for i, name := range names {
+		k, err := key(name)
+		if err != nil {
+			return err
+		}
+		keys[i] = k
+	}
+	return b.run(ctx, goautogui.Action{Kind: goautogui.ActionHotkey, Keys: keys})
+}
+
+func (b *WindowsBackend) ActivateWindow(hwnd uint64) error {
+	err := goautogui.ActivateWindow(win32.HWND(hwnd))
+	if errors.Is(err, goautogui.ErrInvalidWindow) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
+
+func (b *WindowsBackend) Displays() ([]image.Rectangle, error) {
+	return goautogui.GetAllDisplayBounds(), nil
+}
+
+func (b *WindowsBackend) Screenshot(region image.Rectangle) (image.Image, error) {
+	if region.Empty() {
+		return goautogui.CapturePrimaryDisplay()
+	}
+	return goautogui.CaptureRect(region)
+}
+
+func (b *WindowsBackend) Pixel(p image.Point) (color.RGBA, error) {
+	return goautogui.Pixel(p.X, p.Y)
+}
+
+func (b *WindowsBackend) Locate(needle image.Image, region image.Rectangle, tolerance uint8) (image.Rectangle, bool, error) {
+	if region.Empty() {
+		screen := goautogui.GetScreenDimensions()
+		region = image.Rect(0, 0, screen.X, screen.Y)
+	}
+	r, err := goautogui.LocateOnScreen(region, needle, tolerance)
+	if errors.Is(err, goautogui.ErrImageNotFound) {
+		return image.Rectangle{}, false, nil
+	}
+	return r, err == nil, err
+}
+
+func (b *WindowsBackend) Windows() ([]Window, error) {
+	var list []Window
+	for _, w := range goautogui.ListWindows() {
+		list = append(list, Window{uint64(w.Hwnd), w.Title, w.Class, w.PID, w.Bounds, w.Minimized, w.Foreground})
+	}
+	return list, nil
+}
+
+func (b *WindowsBackend) CaptureDisplay(index int) (image.Image, error) {
+	return goautogui.CaptureDisplay(index)
+}
+
+// FailSafeTriggered reports whether the player has the fail-safe and the cursor is in a
+// corner.
+func (b *WindowsBackend) FailSafeTriggered() bool {
+	return b.player.FailSafe() && goautogui.FailSafeTriggered()
+}
+
+// rawFailSafe returns goautogui.ErrFailSafe if the fail-safe is triggered. Releases are
+// never refused, so that held keys and buttons can be let go.
+func (b *WindowsBackend) rawFailSafe() error {
+	if b.FailSafeTriggered() {
+		return goautogui.ErrFailSafe
+	}
+	return nil
+}
+
+func (b *WindowsBackend) RawMove(p image.Point) error {
+	if err := b.rawFailSafe(); err != nil {
+		return err
+	}
+	goautogui.SetCursorPosition(p.X, p.Y)
+	return nil
+}
+
+func (b *WindowsBackend) RawButton(p image.Point, button string, down bool) error {
+	mb, ok := backendButtons[button]
+	if !ok {
+		return badRequest("unknown mouse button %q", button)
+	}
+	var err error
+	if down {
+		if err := b.rawFailSafe(); err != nil {
+			return err
+		}
+		_, err = goautogui.MouseDown(mb, p.X, p.Y)
+	} else {
+		_, err = goautogui.MouseUp(mb, p.X, p.Y)
+	}
+	return err
+}
+
+func (b *WindowsBackend) RawWheel(p image.Point, notches float64, horizontal bool) error {
+	if err := b.rawFailSafe(); err != nil {
+		return err
+	}
+	delta := int(math.Round(notches * float64(win32.WHEEL_DELTA)))
+	if horizontal {
+		goautogui.HorizontalScrollRaw(p.X, p.Y, delta)
+	} else {
+		goautogui.ScrollRaw(p.X, p.Y, delta)
+	}
+	return nil
+}
+
+func (b *WindowsBackend) RawKey(name string, down bool) error {
+	k, err := key(name)
+	if err != nil {
+		return err
+	}
+	if down {
+		if err := b.rawFailSafe(); err != nil {
+			return err
+		}
+		return goautogui.VKeyDown(k)
+	}
+	return goautogui.VKeyUp(k)
+}
@@ -0,0 +1,367 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mhmdibrahimm/goautogui/internal/tiles"
+)
+
+// LiveBackend is a Backend that can also capture whole displays and inject raw input, as
+// the live view needs. The server offers the live view if its backend implements it.
+type LiveBackend interface {
+	Backend
+	// CaptureDisplay captures the display at index, 0 being the primary display.
+	CaptureDisplay(index int) (image.Image, error)
+	// RawMove moves the cursor to p at once.
+	RawMove(p image.Point) error
+	// RawButton presses or releases button at p. button is left, right, middle, x1 or x2.
+	RawButton(p image.Point, button string, down bool) error
+	// RawWheel scrolls notches at p, vertically or horizontally.
+	RawWheel(p image.Point, notches float64, horizontal bool) error
+	// RawKey presses or releases a key, given by name or as a single character.
+	RawKey(key string, down bool) error
+}
+
+// WithViewOnly makes the live view ignore mouse and keyboard events from viewers.
+func WithViewOnly() Option {
+	return func(s *Server) {
+		s.viewOnly = true
+	}
+}
+
+//go:embed live.html
+var liveHTML []byte
+
+// liveTile is the size of the tiles the live view compares between frames.
+const liveTile = 64
+
+func (s *Server) liveViewer(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.backend.(LiveBackend); !ok {
+		writeJSON(w, http.StatusNotImplemented, errorBody{"the backend has no live view"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(liveHTML)
+}
+
+// liveSession is a WebSocket connection of the live view.
+type liveSession struct {
+	s       *Server
+	backend LiveBackend
+	ws      *wsConn
+	format  string
+	quality int
+
+	mu      sync.Mutex
+	display int
+	refresh bool        // send the whole display with the next frame
+	origin  image.Point // of the display, to which event coordinates are relative
+	keys    map[string]bool
+	buttons map[string]bool
+}
+
+type liveEvent struct {
+	Type       string  `json:"type"`
+	X          int     `json:"x"`
+	Y          int     `json:"y"`
+	Button     string  `json:"button"`
+	Notches    float64 `json:"notches"`
+	Horizontal bool    `json:"horizontal"`
+	Key        string  `json:"key"`
+	Index      int     `json:"index"`
+}
+
+// liveWebSocket streams a display as tiles of JPEG or PNG images, and injects the mouse and
+// keyboard events the viewer sends back.
+//
+// Query parameters: display (default 0), fps (default 5, up to 30), format (jpeg or png)
+// and quality (of JPEG, 1 to 100, default 75).
+//
+// The server first sends {"type": "display", ...} with the index, position and size of the
+// display, and again whenever they change; then a binary message per changed area of
+// the display: its x, y, width and height as big-endian uint16s, followed by the image.
+// Errors are sent as {"type": "error", "error": "..."}.
+//
+// The viewer sends JSON events with display coordinates: {"type": "move", "x", "y"};
+// "down" and "up" with a button; "wheel" with notches and horizontal; "keydown" and
+// "keyup" with a key name; {"type": "display", "index": 1} to switch displays; and
+// {"type": "refresh"} for a full frame. Keys and buttons still held when the viewer
+// disconnects are released.
+func (s *Server) liveWebSocket(w http.ResponseWriter, r *http.Request) {
+	backend, ok := s.backend.(LiveBackend)
+	if !ok {
+		writeJSON(w, http.StatusNotImplemented, errorBody{"the backend has no live view"})
+		return
+	}
+	l := &liveSession{s: s, backend: backend, format: "jpeg", quality: 75, keys: map[string]bool{}, buttons: map[string]bool{}}
+	fps := 5.0
+	q := r.URL.Query()
+	var err error
+	if v := q.Get("display"); v != "" {
+		l.display, err = strconv.Atoi(v)
+	}
+	if v := q.Get("fps"); v != "" && err == nil {
+		// Written so that NaN is rejected too
+		if fps, err = strconv.ParseFloat(v, 64); err == nil && !(fps > 0 && fps <= 30) {
+			err = fmt.Errorf("fps %g is outside (0, 30]", fps)
+		}
+	}
+	if v := q.Get("format"); v != "" && err == nil {
+		if l.format = v; v != "jpeg" && v != "png" {
+			err = fmt.Errorf("unknown format %q", v)
+		}
+	}
+	if v := q.Get("quality"); v != "" && err == nil {
+		if l.quality, err = strconv.Atoi(v); err == nil && (l.quality < 1 || l.quality > 100) {
+			err = fmt.Errorf("quality %d is outside 1 to 100", l.quality)
+		}
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{err.Error()})
+		return
+	}
+
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	l.ws = ws
+	ctx, cancel := context.WithCancel(context.Background())
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		l.stream(ctx, time.Duration(float64(time.Second)/fps))
+	}()
+	err = l.receive(ctx)
+	cancel()
+	<-streamDone
+	l.releaseHeld()
+	if errors.Is(err, errWSClosed) {
+		ws.conn.Close()
+	} else {
+		ws.close(wsCloseNormal, "")
+	}
+}
+
+func (l *liveSession) sendJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return l.ws.writeFrame(wsText, data)
+}
+
+func (l *liveSession) sendError(err error) error {
+	return l.sendJSON(map[string]string{"type": "error", "error": err.Error()})
+}
+
+// stream captures the display every interval and sends the areas that changed.
+func (l *liveSession) stream(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var prev *image.RGBA
+	shown := -1
+	var lastErr string
+	for {
+		l.mu.Lock()
+		display, refresh := l.display, l.refresh
+		l.refresh = false
+		l.mu.Unlock()
+		if display != shown || refresh {
+			prev = nil
+		}
+
+		img, err := l.backend.CaptureDisplay(display)
+		switch {
+		case err != nil:
+			if err.Error() != lastErr {
+				lastErr = err.Error()
+				if l.sendError(err) != nil {
+					return
+				}
+			}
+		default:
+			lastErr = ""
+			cur := tiles.ToRGBA(img)
+			var rects []image.Rectangle
+			if prev == nil || prev.Bounds() != cur.Bounds() {
+				if l.sendDisplay(display, cur.Bounds().Size()) != nil {
+					return
+				}
+				shown, rects = display, []image.Rectangle{cur.Bounds()}
+			} else {
+				rects = tiles.Changed(prev, cur, liveTile)
+			}
+			for _, r := range rects {
+				if l.sendArea(cur, r) != nil {
+					return
+				}
+			}
+			prev = cur
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *liveSession) sendDisplay(index int, size image.Point) error {
+	origin := image.Point{}
+	count := 1
+	if displays, err := l.backend.Displays(); err == nil && index < len(displays) {
+		origin, count = displays[index].Min, len(displays)
+	}
+	l.mu.Lock()
+	l.origin = origin
+	l.mu.Unlock()
+	return l.sendJSON(map[string]any{
+		"type": "display", "index": index, "displays": count,
+		"x": origin.X, "y": origin.Y, "width": size.X, "height": size.Y,
+		"format": l.format, "viewOnly": l.s.viewOnly,
+	})
+}
+
+// sendArea sends the area r of img as a binary message.
+func (l *liveSession) sendArea(img *image.RGBA, r image.Rectangle) error {
+	var buf bytes.Buffer
+	for _, v := range []int{r.Min.X, r.Min.Y, r.Dx(), r.Dy()} {
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(v)))
+	}
+	var err error
+	if l.format == "png" {
+		err = png.Encode(&buf, img.SubImage(r))
+	} else {
+		err = jpeg.Encode(&buf, img.SubImage(r), &jpeg.Options{Quality: l.quality})
+	}
+	if err != nil {
+		return err
+	}
+	return l.ws.writeFrame(wsBinary, buf.Bytes())
+}
+
+// receive handles the events of the viewer until it disconnects.
+func (l *liveSession) receive(ctx context.Context) error {
+	for {
+		op, msg, err := l.ws.readMessage()
+		if err != nil {
+			return err
+		}
+		if op != wsText {
+			continue
+		}
+		var ev liveEvent
+		if err := json.Unmarshal(msg, &ev); err != nil {
+			err = fmt.Errorf("invalid event: %v", err)
+		} else {
+			err = l.handle(ctx, ev)
+		}
+		if err != nil {
+			if err := l.sendError(err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (l *liveSession) handle(ctx context.Context, ev liveEvent) error {
+	switch ev.Type {
+	case "display":
+		if ev.Index < 0 {
+			return fmt.Errorf("there is no display %d", ev.Index)
+		}
+		l.mu.Lock()
+		l.display = ev.Index
+		l.mu.Unlock()
+		return nil
+	case "refresh":
+		l.mu.Lock()
+		l.refresh = true
+		l.mu.Unlock()
+		return nil
+	case "move", "down", "up", "wheel", "keydown", "keyup":
+	default:
+		return fmt.Errorf("unknown event type %q", ev.Type)
+	}
+
+	if l.s.viewOnly {
+		return fmt.Errorf("the live view is view-only")
+	}
+	if ev.Type == "down" || ev.Type == "up" {
+		b, err := button(ev.Button)
+		if err != nil {
+			return err
+		}
+		ev.Button = b
+	}
+	// Take turns with queued input requests, so events are not mixed into their keystrokes.
+	ctx, cancel := context.WithTimeout(ctx, l.s.timeout)
+	defer cancel()
+	_, err := l.s.call(ctx, nil, true, func(context.Context, *http.Request) (any, error) {
+		return nil, l.inject(ev)
+	})
+	return err
+}
+
+func (l *liveSession) inject(ev liveEvent) error {
+	l.mu.Lock()
+	p := l.origin.Add(image.Pt(ev.X, ev.Y))
+	l.mu.Unlock()
+	switch ev.Type {
+	case "move":
+		return l.backend.RawMove(p)
+	case "down", "up":
+		down := ev.Type == "down"
+		if err := l.backend.RawButton(p, ev.Button, down); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		l.buttons[ev.Button] = down
+		l.mu.Unlock()
+	case "wheel":
+		return l.backend.RawWheel(p, ev.Notches, ev.Horizontal)
+	case "keydown", "keyup":
+		down := ev.Type == "keydown"
+		if err := l.backend.RawKey(ev.Key, down); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		l.keys[ev.Key] = down
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// releaseHeld releases the keys and buttons the viewer pressed and did not release.
+func (l *liveSession) releaseHeld() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, down := range l.keys {
+		if down {
+			l.backend.RawKey(key, false)
+		}
+	}
+	if len(l.buttons) > 0 {
+		p, _ := l.backend.Position()
+		for b, down := range l.buttons {
+			if down {
+				l.backend.RawButton(p, b, false)
+			}
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal WebSocket client for the live view: the handshake, masked text
+// frames and unfragmented reads.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func dialLive(t *testing.T, ts *httptest.Server, query string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	fmt.Fprintf(conn, "GET /live/ws?%s HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n", query, ts.Listener.Addr())
+	rd := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(rd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: status %d, accept %q", resp.StatusCode, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	return &wsClient{t: t, conn: conn, rd: rd}
+}
+
+// send sends a short text message.
+func (c *wsClient) send(msg string) {
+	c.t.Helper()
+	frame := []byte{0x81, 0x80 | byte(len(msg)), 1, 2, 3, 4}
+	for i := range len(msg) {
+		frame = append(frame, msg[i]^frame[2+i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read returns the opcode and payload of the next message.
+func (c *wsClient) read() (byte, []byte) {
+	c.t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.rd, head[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	n := int(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.rd, ext[:])
+		n = int(ext[0])<<8 | int(ext[1])
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.rd, ext[:])
+		n = int(ext[4])<<24 | int(ext[5])<<16 | int(ext[6])<<8 | int(ext[7])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rd, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+// readTile skips text messages and returns the area and PNG image of the next tile.
+func (c *wsClient) readTile() (image.Rectangle, image.Image) {
+	c.t.Helper()
+	for {
+		op, msg := c.read()
+		if op != wsBinary {
+			continue
+		}
+		u := func(i int) int { return int(msg[i])<<8 | int(msg[i+1]) }
+		img, err := png.Decode(bytes.NewReader(msg[8:]))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		return image.Rect(u(0), u(2), u(0)+u(4), u(2)+u(6)), img
+	}
+}
+
+// waitCalls waits for fake to have n calls and returns them.
+func waitCalls(fake *FakeBackend, n int) []string {
+	for deadline := time.Now().Add(2 * time.Second); len(fake.CallLog()) < n && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fake.CallLog()
+}
+
+func TestLiveView(t *testing.T) {
+	ts, fake := newTestServer(t)
+
+	// The viewer page is served
+	data := call(t, ts, "GET", "/live", "", http.StatusOK)
+	if !bytes.Contains(data, []byte("<html")) {
+		t.Errorf("viewer page is not HTML")
+	}
+
+	// The display is announced, then sent whole
+	c := dialLive(t, ts, "token=secret&fps=20&format=png")
+	op, msg := c.read()
+	var display struct {
+		Type, Format  string
+		Width, Height int
+	}
+	if err := json.Unmarshal(msg, &display); err != nil || op != wsText || display.Type != "display" || display.Width != 200 || display.Height != 100 || display.Format != "png" {
+		t.Fatalf("first message %s, %v; want the 200x100 display", msg, err)
+	}
+	if r, img := c.readTile(); r != image.Rect(0, 0, 200, 100) || img.Bounds().Size() != r.Size() {
+		t.Fatalf("first tile %v, want the whole display", r)
+	}
+
+	// Only the tile that changed is sent next
+	green := color.RGBA{G: 0xFF, A: 0xFF}
+	fake.UpdateScreen(func(screen *image.RGBA) *image.RGBA {
+		draw.Draw(screen, image.Rect(70, 10, 72, 12), image.NewUniform(green), image.Point{}, draw.Src)
+		return screen
+	})
+	r, img := c.readTile()
+	if r != image.Rect(64, 0, 128, 64) {
+		t.Fatalf("changed tile %v, want (64,0)-(128,64)", r)
+	}
+	if got := color.RGBAModel.Convert(img.At(img.Bounds().Min.X+6, img.Bounds().Min.Y+10)); got != green {
+		t.Errorf("changed tile has %v at (70,10), want green", got)
+	}
+
+	// Events are injected; held keys and buttons are released on disconnect
+	for _, ev := range []string{
+		`{"type":"move","x":10,"y":20}`,
+		`{"type":"down","button":"right","x":10,"y":20}`,
+		`{"type":"keydown","key":"shift"}`,
+		`{"type":"wheel","notches":-2,"x":5,"y":5}`,
+	} {
+		c.send(ev)
+	}
+	want := []string{"rawmove 10,20", "rawbutton 10,20 right down", "rawkey shift down", "rawwheel -2 at 5,5 horizontal=false"}
+	if got := waitCalls(fake, len(want)); !slices.Equal(got, want) {
+		t.Fatalf("injected %q, want %q", got, want)
+	}
+	c.conn.Write([]byte{0x88, 0x82, 0, 0, 0, 0, 0x03, 0xE8})
+	want = append(want, "rawkey shift up", "rawbutton 5,5 right up")
+	if got := waitCalls(fake, len(want)); !slices.Equal(got, want) {
+		t.Errorf("after disconnecting %q, want %q", got, want)
+	}
+}
+
+func TestLiveViewParams(t *testing.T) {
+	ts, _ := newTestServer(t)
+	for _, query := range []string{"fps=NaN", "fps=0", "fps=-1", "fps=31", "fps=Inf", "format=gif", "quality=0", "quality=101", "display=x"} {
+		call(t, ts, "GET", "/live/ws?"+query, "", http.StatusBadRequest)
+	}
+}
+
+// newLiveSession returns a session of a live view of fake, without a connection, showing a
+// display at origin.
+func newLiveSession(fake *FakeBackend, origin image.Point, opts ...Option) *liveSession {
+	return &liveSession{s: New(fake, opts...), backend: fake, origin: origin, keys: map[string]bool{}, buttons: map[string]bool{}}
+}
+
+func TestLiveEvents(t *testing.T) {
+	fake := NewFakeBackend(200, 100)
+	l := newLiveSession(fake, image.Pt(-100, 0))
+	ctx := context.Background()
+	events := []struct {
+		ev      liveEvent
+		wantErr string
+	}{
+		// Coordinates are relative to the display
+		{liveEvent{Type: "move", X: 110, Y: 20}, ""},
+		// The button defaults to left
+		{liveEvent{Type: "down", X: 110, Y: 20}, ""},
+		{liveEvent{Type: "up", X: 110, Y: 20, Button: "thumb"}, `unknown mouse button "thumb"`},
+		{liveEvent{Type: "wheel", X: 0, Y: 0, Notches: 1, Horizontal: true}, ""},
+		{liveEvent{Type: "keydown", Key: "a"}, ""},
+		{liveEvent{Type: "teleport"}, `unknown event type "teleport"`},
+		{liveEvent{Type: "display", Index: -1}, "there is no display -1"},
+	}
+	for _, e := range events {
+		err := l.handle(ctx, e.ev)
+		if e.wantErr == "" && err != nil || e.wantErr != "" && (err == nil || err.Error() != e.wantErr) {
+			t.Errorf("handle(%+v) error = %v, want %q", e.ev, err, e.wantErr)
+		}
+	}
+	want := []string{"rawmove 10,20", "rawbutton 10,20 left down", "rawwheel 1 at -100,0 horizontal=true", "rawkey a down"}
+	if got := fake.CallLog(); !slices.Equal(got, want) {
+		t.Errorf("injected %q, want %q", got, want)
+	}
+	if !l.buttons["left"] || !l.keys["a"] {
+		t.Errorf("held buttons %v and keys %v, want left and a", l.buttons, l.keys)
+	}
+
+	// Display switches and refreshes are picked up by the stream
+	if err := l.handle(ctx, liveEvent{Type: "display", Index: 1}); err != nil || l.display != 1 {
+		t.Errorf("display 1: error %v, display %d", err, l.display)
+	}
+	if err := l.handle(ctx, liveEvent{Type: "refresh"}); err != nil || !l.refresh {
+		t.Errorf("refresh: error %v, refresh %v", err, l.refresh)
+	}
+}
+
+func TestLiveViewOnly(t *testing.T) {
+	fake := NewFakeBackend(200, 100)
+	l := newLiveSession(fake, image.Point{}, WithViewOnly())
+	for _, typ := range []string{"move", "down", "up", "wheel", "keydown", "keyup"} {
+		if err := l.handle(context.Background(), liveEvent{Type: typ, Key: "a"}); err == nil || !strings.Contains(err.Error(), "view-only") {
+			t.Errorf("%s: error %v, want view-only", typ, err)
+		}
+	}
+	if calls := fake.CallLog(); len(calls) != 0 {
+		t.Errorf("injected %q in a view-only live view", calls)
+	}
+	// Viewers may still choose what to watch
+	if err := l.handle(context.Background(), liveEvent{Type: "refresh"}); err != nil {
+		t.Errorf("refresh: %v", err)
+	}
+}
+
+func TestReleaseHeld(t *testing.T) {
+	fake := NewFakeBackend(200, 100)
+	l := newLiveSession(fake, image.Point{})
+	for _, ev := range []liveEvent{
+		{Type: "keydown", Key: "shift"},
+		{Type: "keydown", Key: "a"},
+		{Type: "keyup", Key: "a"},
+		{Type: "down", Button: "right", X: 5, Y: 6},
+		{Type: "keydown", Key: "ctrl"},
+	} {
+		if err := l.inject(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A key whose release failed is still held
+	fake.Errors["rawkey"] = errors.New("no input desktop")
+	if err := l.inject(liveEvent{Type: "keyup", Key: "ctrl"}); err == nil {
+		t.Fatal("failed release succeeded")
+	}
+	delete(fake.Errors, "rawkey")
+	fake.Calls = nil
+
+	l.releaseHeld()
+	got := fake.CallLog()
+	slices.Sort(got)
+	want := []string{"rawbutton 5,6 right up", "rawkey ctrl up", "rawkey shift up"}
+	if !slices.Equal(got, want) {
+		t.Errorf("released %q, want %q", got, want)
+	}
+}
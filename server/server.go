@@ -0,0 +1,648 @@
+// Package server exposes a Backend over a small HTTP/JSON API, so that tests running on
+// another machine can drive the mouse, keyboard and screen of a Windows machine.
+//
+// Requests and responses are JSON, except screenshots, which are returned as PNG, and
+// the image to locate, which is posted as a PNG or JPEG body. Durations are strings such as
+// "500ms". Points default to the cursor position where they are optional.
+//
+//	GET  /ping                     {"ok": true}
+//	GET  /mouse/position           {"x": 10, "y": 20}
+//	POST /mouse/move               {"x": 10, "y": 20, "duration": "250ms"}
+//	POST /mouse/click              {"x": 10, "y": 20, "button": "right", "clicks": 2}
+//	POST /mouse/drag               {"from": {"x": 0, "y": 0}, "x": 10, "y": 20, "duration": "1s"}
+//	POST /mouse/scroll             {"notches": -3, "horizontal": false, "x": 10, "y": 20}
+//	POST /keyboard/type            {"text": "hello", "interval": "20ms"}
+//	POST /keyboard/press           {"key": "enter", "presses": 2}
+//	POST /keyboard/hotkey          {"keys": ["ctrl", "s"]}
+//	GET  /screen/displays          [{"x": 0, "y": 0, "width": 1920, "height": 1080}, ...]
+//	GET  /screen/screenshot        PNG; ?region=x,y,width,height
+//	GET  /screen/pixel?x=10&y=20   {"x": 10, "y": 20, "r": 255, "g": 0, "b": 0, "hex": "#ff0000"}
+//	POST /screen/locate            PNG body; ?region=x,y,width,height&tolerance=10
+//	GET  /windows                  [{"hwnd": 1234, "title": ...}, ...]; ?title=text
+//	POST /windows/{hwnd}/activate  {"ok": true}
+//	GET  /live                     HTML viewer of the live view
+//	GET  /live/ws                  WebSocket of the live view
+//
+// Input requests, from /mouse/move to /windows/{hwnd}/activate, are queued and performed
+// one at a time, so that the keystrokes of concurrent requests do not interleave. Time
+// spent in the queue counts towards the request timeout.
+//
+// The live view streams a display to a browser and injects the mouse and keyboard events
+// it sends back, to watch and take over a stuck automation. It is only available if the
+// backend is a LiveBackend; WithViewOnly turns off its input.
+//
+// Request bodies must be sent with the Content-Type application/json, or image/png or
+// image/jpeg for /screen/locate, and requests other than GET are refused if their Origin
+// header names another site, so web pages open in a browser cannot drive the machine.
+// Without a token, requests are also refused unless their Host is localhost or a loopback
+// address, so a page cannot reach the server through a DNS name rebound to 127.0.0.1.
+//
+// Errors are returned as {"error": "..."} with status 400 for a bad request, 401 for a
+// missing or wrong token, 403 for a cross-origin request or another host, 404 when an image or window is
+// not found, 415 for a body of the wrong Content-Type, 504 when the request timed out,
+// and 500 otherwise.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAddr is the address ListenAndServe listens on if none is given.
+const DefaultAddr = "127.0.0.1:8765"
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithToken requires requests to carry the header "Authorization: Bearer <token>", or, for
+// browsers, which cannot set headers on WebSocket requests, the query parameter token.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithTimeout sets how long a request may take, including the time it waits in the input
+// queue. The default is 30 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.timeout = timeout
+	}
+}
+
+// Server is an http.Handler that serves the API of a Backend.
+type Server struct {
+	backend  Backend
+	token    string
+	timeout  time.Duration
+	queue    chan struct{} // holds a token while an input request runs
+	mux      *http.ServeMux
+	viewOnly bool
+}
+
+// New creates a Server that performs requests with backend.
+func New(backend Backend, opts ...Option) *Server {
+	s := &Server{backend: backend, timeout: 30 * time.Second, queue: make(chan struct{}, 1), mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.route("GET /ping", false, s.ping)
+	s.route("GET /mouse/position", false, s.position)
+	s.route("POST /mouse/move", true, s.move)
+	s.route("POST /mouse/click", true, s.click)
+	s.route("POST /mouse/drag", true, s.drag)
+	s.route("POST /mouse/scroll", true, s.scroll)
+	s.route("POST /keyboard/type", true, s.typeText)
+	s.route("POST /keyboard/press", true, s.press)
+	s.route("POST /keyboard/hotkey", true, s.hotkey)
+	s.route("GET /screen/displays", false, s.displays)
+	s.route("GET /screen/screenshot", false, s.screenshot)
+	s.route("GET /screen/pixel", false, s.pixel)
+	s.route("POST /screen/locate", false, s.locate)
+	s.route("GET /windows", false, s.windows)
+	s.route("POST /windows/{hwnd}/activate", true, s.activate)
+	s.mux.HandleFunc("GET /live", s.liveViewer)
+	s.mux.HandleFunc("GET /live/ws", s.liveWebSocket)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token == "" && !isLoopbackHost(r.Host) {
+		writeJSON(w, http.StatusForbidden, errorBody{"a token is required for hosts other than localhost"})
+		return
+	}
+	if s.token != "" {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			got, ok = r.URL.Query().Get("token"), r.URL.Query().Has("token")
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, errorBody{"missing or wrong token"})
+			return
+		}
+	}
+	// Any web page can make the browser send a simple POST to a loopback server, so refuse
+	// requests that change state on behalf of other sites
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+		writeJSON(w, http.StatusForbidden, errorBody{"cross-origin request"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// sameOrigin reports whether r was not made by a page of another site: browsers set the
+// Origin header on cross-origin requests, other clients usually do not set it at all.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// ListenAndServe serves on addr, DefaultAddr if it is empty, until ctx is cancelled. It
+// refuses to listen on an address other than loopback without a token.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	if s.token == "" && !isLoopback(addr) {
+		return fmt.Errorf("refusing to listen on %s without a token", addr)
+	}
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		srv.Shutdown(shutdown)
+		return ctx.Err()
+	}
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && isLoopbackHost(host)
+}
+
+// isLoopbackHost reports whether host, as in a Host header with or without a port, is
+// localhost or a loopback IP address.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handlerFunc handles a request, returning the value to send as JSON, or an image to send
+// as PNG.
+type handlerFunc func(ctx context.Context, r *http.Request) (any, error)
+
+// route registers fn for pattern. Queued handlers wait for the input queue.
+func (s *Server) route(pattern string, queued bool, fn handlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+		defer cancel()
+		result, err := s.call(ctx, r, queued, fn)
+		switch {
+		case err != nil:
+			writeJSON(w, errorStatus(err), errorBody{err.Error()})
+		case result == nil:
+			writeJSON(w, http.StatusOK, okBody{true})
+		default:
+			if img, ok := result.(image.Image); ok {
+				w.Header().Set("Content-Type", "image/png")
+				png.Encode(w, img)
+				return
+			}
+			writeJSON(w, http.StatusOK, result)
+		}
+	})
+}
+
+func (s *Server) call(ctx context.Context, r *http.Request, queued bool, fn handlerFunc) (any, error) {
+	if queued {
+		select {
+		case s.queue <- struct{}{}:
+			defer func() { <-s.queue }()
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for queued input: %w", ctx.Err())
+		}
+	}
+	return fn(ctx, r)
+}
+
+type okBody struct {
+	OK bool `json:"ok"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// requestError is an error in a request.
+type requestError struct{ msg string }
+
+func (e requestError) Error() string { return e.msg }
+
+func badRequest(format string, args ...any) error {
+	return requestError{fmt.Sprintf(format, args...)}
+}
+
+func errorStatus(err error) int {
+	var reqErr requestError
+	switch {
+	case errors.As(err, &reqErr):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// maxJSONBody is the largest JSON request body accepted; typed text is the only long field.
+const maxJSONBody = 1 << 20
+
+// errMediaType is wrapped by the errors of requests whose body is not of the media type
+// the route takes. The server answers them with 415 Unsupported Media Type.
+var errMediaType = errors.New("unsupported media type")
+
+// checkMediaType checks that the Content-Type of r is one of types. Requiring it also keeps
+// web pages from posting to the server: browsers only send other types after a CORS
+// preflight, which the server does not answer.
+func checkMediaType(r *http.Request, types ...string) error {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(types, mt) {
+		return fmt.Errorf("%w: the request body must be %s", errMediaType, strings.Join(types, " or "))
+	}
+	return nil
+}
+
+// decode decodes the JSON body of r into v. The body must be sent as application/json.
+func decode(r *http.Request, v any) error {
+	if err := checkMediaType(r, "application/json"); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxJSONBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// duration is a time.Duration written in JSON as a string such as "500ms".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"500ms\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return fmt.Errorf("negative duration %s", s)
+	}
+	*d = duration(v)
+	return nil
+}
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// optionalPoint is a point in a request that defaults to the cursor position.
+type optionalPoint struct {
+	X *int `json:"x"`
+	Y *int `json:"y"`
+}
+
+func (s *Server) resolve(p optionalPoint) (image.Point, error) {
+	if (p.X == nil) != (p.Y == nil) {
+		return image.Point{}, badRequest("give both x and y, or neither")
+	}
+	if p.X == nil {
+		return s.backend.Position()
+	}
+	return image.Pt(*p.X, *p.Y), nil
+}
+
+type rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func toRect(r image.Rectangle) rect {
+	return rect{r.Min.X, r.Min.Y, r.Dx(), r.Dy()}
+}
+
+// regionParam parses the region query parameter, x,y,width,height, if present.
+func regionParam(r *http.Request) (image.Rectangle, error) {
+	v := r.URL.Query().Get("region")
+	if v == "" {
+		return image.Rectangle{}, nil
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, badRequest("region %q: want x,y,width,height", v)
+	}
+	var n [4]int
+	for i, part := range parts {
+		var err error
+		if n[i], err = strconv.Atoi(strings.TrimSpace(part)); err != nil {
+			return image.Rectangle{}, badRequest("region %q: %q is not an integer", v, part)
+		}
+	}
+	if n[2] <= 0 || n[3] <= 0 {
+		return image.Rectangle{}, badRequest("region %q: the width and height must be positive", v)
+	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, badRequest("%s %q is not an integer", name, v)
+	}
+	return n, nil
+}
+
+func (s *Server) ping(ctx context.Context, r *http.Request) (any, error) {
+	return nil, nil
+}
+
+func (s *Server) position(ctx context.Context, r *http.Request) (any, error) {
+	p, err := s.backend.Position()
+	if err != nil {
+		return nil, err
+	}
+	return point{p.X, p.Y}, nil
+}
+
+func (s *Server) move(ctx context.Context, r *http.Request) (any, error) {
+	var req struct {
+		point
+		Duration duration `json:"duration"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	return nil, s.backend.Move(ctx, image.Pt(req.X, req.Y), time.Duration(req.Duration))
+}
+
+var buttons = []string{"left", "right", "middle", "x1", "x2"}
+
+// button returns the button name, left if it is empty.
+func button(name string) (string, error) {
+	if name == "" {
+		return "left", nil
+	}
+	for _, b := range buttons {
+		if b == name {
+			return name, nil
+		}
+	}
+	return "", badRequest("unknown mouse button %q", name)
+}
+
+func (s *Server) click(ctx context.Context, r *http.Request) (any, error) {
+	var req struct {
+		optionalPoint
+		Button string `json:"button"`
+		Clicks int    `json:"clicks"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	b, err := button(req.Button)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.resolve(req.optionalPoint)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.backend.Click(ctx, p, b, max(1, req.Clicks))
+}
+
+func (s *Server) drag(ctx context.Context, r *http.Request) (any, error) {
+	var req struct {
+		point
+		From     optionalPoint `json:"from"`
+		Duration duration      `json:"duration"`
+		Button   string        `json:"button"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	b, err := button(req.Button)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.resolve(req.From)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.backend.Drag(ctx, from, image.Pt(req.X, req.Y), time.Duration(req.Duration), b)
+}
+
+func (s *Server) scroll(ctx context.Context, r *http.Request) (any, error) {
+	var req struct {
+		optionalPoint
+		Notches    float64 `json:"notches"`
+		Horizontal bool    `json:"horizontal"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	p, err := s.resolve(req.optionalPoint)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.backend.Scroll(ctx, p, req.Notches, req.Horizontal)
+}
+
+func (s *Server) typeText(ctx context.Context, r *http.Request) (any, error) {
+	var req struct {
+		Text     string   `json:"text"`
+		Interval duration `json:"interval"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	return nil, s.backend.Type(ctx, req.Text, time.Duration(req.Interval))
+}
+
+func (s *Server) press(ctx context.Context, r *http.Request) (any, error) {
+	var req struct {
+		Key     string `json:"key"`
+		Presses int    `json:"presses"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if req.Key == "" {
+		return nil, badRequest("missing key")
+	}
+	return nil, s.backend.Press(ctx, req.Key, max(1, req.Presses))
+}
+
+func (s *Server) hotkey(ctx context.Context, r *http.Request) (any, error) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Keys) == 0 {
+		return nil, badRequest("missing keys")
+	}
+	return nil, s.backend.Hotkey(ctx, req.Keys)
+}
+
+func (s *Server) displays(ctx context.Context, r *http.Request) (any, error) {
+	bounds, err := s.backend.Displays()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]rect, len(bounds))
+	for i, b := range bounds {
+		list[i] = toRect(b)
+	}
+	return list, nil
+}
+
+func (s *Server) screenshot(ctx context.Context, r *http.Request) (any, error) {
+	region, err := regionParam(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.backend.Screenshot(region)
+}
+
+type pixelBody struct {
+	point
+	R   uint8  `json:"r"`
+	G   uint8  `json:"g"`
+	B   uint8  `json:"b"`
+	Hex string `json:"hex"`
+}
+
+func (s *Server) pixel(ctx context.Context, r *http.Request) (any, error) {
+	if !r.URL.Query().Has("x") || !r.URL.Query().Has("y") {
+		return nil, badRequest("missing x or y")
+	}
+	x, err := intParam(r, "x", 0)
+	if err != nil {
+		return nil, err
+	}
+	y, err := intParam(r, "y", 0)
+	if err != nil {
+		return nil, err
+	}
+	var c color.RGBA
+	if c, err = s.backend.Pixel(image.Pt(x, y)); err != nil {
+		return nil, err
+	}
+	return pixelBody{point{x, y}, c.R, c.G, c.B, fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)}, nil
+}
+
+// maxImageBody is the largest image accepted by /screen/locate.
+const maxImageBody = 32 << 20
+
+type locateBody struct {
+	rect
+	CenterX int `json:"centerX"`
+	CenterY int `json:"centerY"`
+}
+
+func (s *Server) locate(ctx context.Context, r *http.Request) (any, error) {
+	region, err := regionParam(r)
+	if err != nil {
+		return nil, err
+	}
+	tolerance, err := intParam(r, "tolerance", 0)
+	if err != nil {
+		return nil, err
+	}
+	if tolerance < 0 || tolerance > 255 {
+		return nil, badRequest("tolerance %d is outside 0 to 255", tolerance)
+	}
+	if err := checkMediaType(r, "image/png", "image/jpeg"); err != nil {
+		return nil, err
+	}
+	needle, _, err := image.Decode(http.MaxBytesReader(nil, r.Body, maxImageBody))
+	if err != nil {
+		return nil, badRequest("cannot decode the image: %v", err)
+	}
+	found, ok, err := s.backend.Locate(needle, region, uint8(tolerance))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("image %w on the screen", ErrNotFound)
+	}
+	c := found.Min.Add(found.Max).Div(2)
+	return locateBody{toRect(found), c.X, c.Y}, nil
+}
+
+type windowBody struct {
+	Hwnd  uint64 `json:"hwnd"`
+	Title string `json:"title"`
+	Class string `json:"class"`
+	PID   uint32 `json:"pid"`
+	rect
+	Minimized  bool `json:"minimized"`
+	Foreground bool `json:"foreground"`
+}
+
+func (s *Server) windows(ctx context.Context, r *http.Request) (any, error) {
+	windows, err := s.backend.Windows()
+	if err != nil {
+		return nil, err
+	}
+	title := strings.ToLower(r.URL.Query().Get("title"))
+	list := []windowBody{}
+	for _, w := range windows {
+		if strings.Contains(strings.ToLower(w.Title), title) {
+			list = append(list, windowBody{w.Hwnd, w.Title, w.Class, w.PID, toRect(w.Bounds), w.Minimized, w.Foreground})
+		}
+	}
+	return list, nil
+}
+
+func (s *Server) activate(ctx context.Context, r *http.Request) (any, error) {
+	hwnd, err := strconv.ParseUint(r.PathValue("hwnd"), 0, 64)
+	if err != nil {
+		return nil, badRequest("window handle %q is not a number", r.PathValue("hwnd"))
+	}
+	return nil, s.backend.ActivateWindow(hwnd)
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var red = color.RGBA{R: 0xFF, A: 0xFF}
+
+// newTestServer serves a 200x100 FakeBackend with a red square at 50,40 and two windows,
+// requiring the token "secret".
+func newTestServer(t *testing.T, opts ...Option) (*httptest.Server, *FakeBackend) {
+	t.Helper()
+	fake := NewFakeBackend(200, 100)
+	draw.Draw(fake.Screen, image.Rect(50, 40, 60, 50), image.NewUniform(red), image.Point{}, draw.Src)
+	fake.WindowList = []Window{{Hwnd: 0x10, Title: "Notepad"}, {Hwnd: 0x20, Title: "Calculator", Minimized: true}}
+	ts := httptest.NewServer(New(fake, append([]Option{WithToken("secret"), WithTimeout(2 * time.Second)}, opts...)...))
+	t.Cleanup(ts.Close)
+	return ts, fake
+}
+
+// call sends a request with the token and returns the response body, failing t if the
+// status is not want. A body starting with { is sent as application/json.
+func call(t *testing.T, ts *httptest.Server, method, path, body string, want int) []byte {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	if strings.HasPrefix(body, "{") {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return do(t, req, want)
+}
+
+// do sends req and returns the response body, failing t if the status is not want. It
+// reports errors with t.Error, so that it may be called from other goroutines.
+func do(t *testing.T, req *http.Request, want int) []byte {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	if resp.StatusCode != want {
+		t.Errorf("%s %s: status %d, want %d: %s", req.Method, req.URL.Path, resp.StatusCode, want, data)
+	}
+	return data
+}
+
+func TestToken(t *testing.T) {
+	ts, _ := newTestServer(t)
+	req, _ := http.NewRequest("GET", ts.URL+"/ping", nil)
+	do(t, req, http.StatusUnauthorized)
+	req.Header.Set("Authorization", "Bearer wrong")
+	do(t, req, http.StatusUnauthorized)
+	call(t, ts, "GET", "/ping", "", http.StatusOK)
+	call(t, ts, "GET", "/ping?token=secret", "", http.StatusOK)
+}
+
+func TestQueue(t *testing.T) {
+	ts, fake := newTestServer(t)
+	fake.Delay = 20 * time.Millisecond
+
+	// Concurrent input requests are queued, not interleaved
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			call(t, ts, "POST", "/keyboard/type", fmt.Sprintf(`{"text": "text %d"}`, i), http.StatusOK)
+		}()
+	}
+	wg.Wait()
+	if fake.Overlapped || len(fake.CallLog()) != 5 {
+		t.Errorf("typed %v, overlapped %v; want 5 calls one at a time", fake.CallLog(), fake.Overlapped)
+	}
+}
+
+func TestInput(t *testing.T) {
+	ts, fake := newTestServer(t)
+	steps := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/mouse/move", `{"x": 30, "y": 40, "duration": "10ms"}`, http.StatusOK},
+		// A click without a point clicks at the cursor
+		{"POST", "/mouse/click", `{"button": "right", "clicks": 2}`, http.StatusOK},
+		{"POST", "/mouse/click", `{"button": "thumb"}`, http.StatusBadRequest},
+		{"POST", "/mouse/move", `{"x": 1, "y": 2, "duration": 5}`, http.StatusBadRequest},
+		{"POST", "/mouse/move", `{"x": 1, "y": 2, "speed": 5}`, http.StatusBadRequest},
+		{"POST", "/keyboard/hotkey", `{"keys": ["ctrl", "s"]}`, http.StatusOK},
+		{"POST", "/windows/0x20/activate", ``, http.StatusOK},
+		{"POST", "/windows/0x99/activate", ``, http.StatusNotFound},
+	}
+	for _, s := range steps {
+		call(t, ts, s.method, s.path, s.body, s.want)
+	}
+	want := []string{"move 30,40 over 10ms", "click 30,40 right x2", "hotkey [ctrl s]", "activate 0x20"}
+	if got := fake.CallLog(); !slices.Equal(got, want) {
+		t.Errorf("calls %q, want %q", got, want)
+	}
+}
+
+func TestWindows(t *testing.T) {
+	ts, _ := newTestServer(t)
+	call(t, ts, "POST", "/windows/0x20/activate", "", http.StatusOK)
+	data := call(t, ts, "GET", "/windows?title=CALC", "", http.StatusOK)
+	var windows []struct {
+		Hwnd       uint64 `json:"hwnd"`
+		Foreground bool   `json:"foreground"`
+	}
+	if err := json.Unmarshal(data, &windows); err != nil || len(windows) != 1 || windows[0].Hwnd != 0x20 || !windows[0].Foreground {
+		t.Errorf("windows: %s", data)
+	}
+}
+
+func TestScreenshotAndLocate(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	// Screenshots are PNG
+	data := call(t, ts, "GET", "/screen/screenshot?region=50,40,10,10", "", http.StatusOK)
+	shot, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shot.Bounds().Dx() != 10 || color.RGBAModel.Convert(shot.At(5, 5)) != red {
+		t.Fatalf("screenshot %v is not the red square", shot.Bounds())
+	}
+
+	// The screenshot is found by locate, a green square is not
+	locate := func(img []byte, want int) []byte {
+		req, _ := http.NewRequest("POST", ts.URL+"/screen/locate", bytes.NewReader(img))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "image/png")
+		return do(t, req, want)
+	}
+	data = locate(data, http.StatusOK)
+	var found struct{ CenterX, CenterY int }
+	if err := json.Unmarshal(data, &found); err != nil || found.CenterX != 55 || found.CenterY != 45 {
+		t.Errorf("locate: %s, want the centre at 55,45", data)
+	}
+	green := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(green, green.Bounds(), image.NewUniform(color.RGBA{G: 0xFF, A: 0xFF}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	png.Encode(&buf, green)
+	locate(buf.Bytes(), http.StatusNotFound)
+}
+
+func TestTimeout(t *testing.T) {
+	// A request queued behind a slow one times out
+	slow := NewFakeBackend(10, 10)
+	slow.Delay = 300 * time.Millisecond
+	ts := httptest.NewServer(New(slow, WithTimeout(100*time.Millisecond)))
+	defer ts.Close()
+	resp, err := http.Post(ts.URL+"/keyboard/press", "application/json", strings.NewReader(`{"key": "enter"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("slow press: status %d, want 504", resp.StatusCode)
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	ts, fake := newTestServer(t)
+	tests := []struct {
+		path, contentType, body string
+		want                    int
+	}{
+		{"/keyboard/press", "text/plain", `{"key": "enter"}`, http.StatusUnsupportedMediaType},
+		{"/keyboard/press", "application/x-www-form-urlencoded", `{"key": "enter"}`, http.StatusUnsupportedMediaType},
+		{"/keyboard/press", "", `{"key": "enter"}`, http.StatusUnsupportedMediaType},
+		{"/keyboard/press", "application/json; charset=utf-8", `{"key": "enter"}`, http.StatusOK},
+		{"/screen/locate", "text/plain", "not an image", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", ts.URL+tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer secret")
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		do(t, req, tt.want)
+	}
+	if got := fake.CallLog(); len(got) != 1 {
+		t.Errorf("calls %q, want only the JSON press", got)
+	}
+}
+
+func TestCrossOrigin(t *testing.T) {
+	ts, fake := newTestServer(t)
+	send := func(method, path, origin string, want int) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(`{"key": "enter"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "application/json")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		do(t, req, want)
+	}
+	send("POST", "/keyboard/press", "http://evil.example", http.StatusForbidden)
+	send("POST", "/keyboard/press", "null", http.StatusForbidden)
+	send("POST", "/keyboard/press", ts.URL, http.StatusOK)
+	send("POST", "/keyboard/press", "", http.StatusOK)
+	send("GET", "/ping", "http://evil.example", http.StatusOK)
+	if got := fake.CallLog(); len(got) != 2 {
+		t.Errorf("calls %q, want the two same-origin presses", got)
+	}
+}
+
+func TestHost(t *testing.T) {
+	// Without a token, only requests for a loopback host are answered, so a page cannot
+	// reach the server through a DNS name rebound to 127.0.0.1
+	fake := NewFakeBackend(200, 100)
+	ts := httptest.NewServer(New(fake))
+	t.Cleanup(ts.Close)
+	_, port, _ := strings.Cut(strings.TrimPrefix(ts.URL, "http://"), ":")
+	send := func(method, path, host string, want int) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(`{"text": "rm -rf /"}`))
+		req.Host = host
+		req.Header.Set("Origin", "http://"+host)
+		req.Header.Set("Content-Type", "application/json")
+		do(t, req, want)
+	}
+	send("POST", "/keyboard/type", "evil.example:"+port, http.StatusForbidden)
+	send("GET", "/screen/screenshot", "evil.example", http.StatusForbidden)
+	send("GET", "/live/ws", "evil.example:"+port, http.StatusForbidden)
+	send("GET", "/ping", "127.0.0.2:"+port, http.StatusOK)
+	send("GET", "/ping", "[::1]:"+port, http.StatusOK)
+	send("POST", "/keyboard/type", "LOCALHOST:"+port, http.StatusOK)
+	if got := fake.CallLog(); len(got) != 1 {
+		t.Errorf("calls %q, want only the localhost request", got)
+	}
+
+	// With a token, the token protects the server on any host
+	ts, _ = newTestServer(t)
+	req, _ := http.NewRequest("GET", ts.URL+"/ping", nil)
+	req.Host = "goautogui.example:8765"
+	req.Header.Set("Authorization", "Bearer secret")
+	do(t, req, http.StatusOK)
+}
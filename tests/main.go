@@ -1,15 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
-	"image/png"
+	"image/color"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/mhmdibrahimm/goautogui/script"
 	goautogui "github.com/mhmdibrahimm/goautogui/windows"
 	"github.com/zzl/go-win32api/v2/win32"
 )
@@ -59,6 +62,17 @@ func main() {
 		testCaptureRect,
 		testCaptureDisplay,
 		testCapturePrimaryDisplay,
+		testCapturer,
+		testScreenRecorder,
+		testCaptureWithCursor,
+		testCaptureRegionValidation,
+		testDiff,
+		testReadText,
+		testFindColor,
+		testRecorder,
+		testPlayer,
+		testScript,
+		testWindowsAndFailSafe,
 	}
 
 	var passed, failed int
@@ -112,7 +126,7 @@ func testMoveRelative() testResult {
 }
 
 func testClick() testResult {
-	err := goautogui.Click(goautogui.MouseLeftButton, 484, 766)
+	err := goautogui.Click(484, 766, goautogui.WithButton(goautogui.MouseLeftButton))
 	if err != nil {
 		return testResult{"Click", err}
 	}
@@ -331,17 +345,10 @@ func openImage(img image.Image, test_file_name string) {
 	}
 	tmpDir := os.TempDir()
 	tmpFile := filepath.Join(tmpDir, test_file_name+".png")
-	f, err := os.Create(tmpFile)
-	if err != nil {
-		fmt.Println("Failed to create temp file for image:", err)
-		return
-	}
-	defer f.Close()
-	if err := png.Encode(f, img); err != nil {
-		fmt.Println("Failed to encode image:", err)
+	if err := goautogui.SaveScreenshot(tmpFile, img); err != nil {
+		fmt.Println("Failed to save image:", err)
 		return
 	}
-	f.Close()
 	// Open with default viewer
 	cmd := exec.Command("rundll32", "shell32.dll,ShellExec_RunDLL", tmpFile)
 
@@ -379,6 +386,15 @@ func testCaptureWindow() testResult {
 		return testResult{"CaptureWindow", errors.New("captured image is nil")}
 	}
 	openImage(img, "capture_window_test_image")
+
+	client, err := goautogui.CaptureWindow(hwnd, goautogui.WithClientArea())
+	if err != nil {
+		return testResult{"CaptureWindow", err}
+	}
+	if client.Bounds().Dx() > img.Bounds().Dx() || client.Bounds().Dy() > img.Bounds().Dy() {
+		return testResult{"CaptureWindow", errors.New("client area capture is larger than the window capture")}
+	}
+	openImage(client, "capture_window_client_test_image")
 	fmt.Println("CaptureWindow test passed")
 	return testResult{"CaptureWindow", nil}
 }
@@ -446,3 +462,338 @@ func testCapturePrimaryDisplay() testResult {
 	fmt.Println("CapturePrimaryDisplay test passed")
 	return testResult{"CapturePrimaryDisplay", nil}
 }
+
+func testCapturer() testResult {
+	c, err := goautogui.NewCapturer()
+	if err != nil {
+		return testResult{"Capturer", err}
+	}
+	defer c.Close()
+
+	const frames = 30
+	start := time.Now()
+	var img *image.RGBA
+	for i := 0; i < frames; i++ {
+		img, err = c.Capture(0, 0, 800, 600)
+		if err != nil {
+			return testResult{"Capturer", err}
+		}
+	}
+	fmt.Printf("Capturer: %d frames of 800x600 in %v (%v/frame)\n", frames, time.Since(start), time.Since(start)/frames)
+
+	dst := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	if err := c.CaptureInto(dst, 100, 100); err != nil {
+		return testResult{"Capturer", err}
+	}
+	openImage(img, "capturer_test_image")
+	return testResult{"Capturer", nil}
+}
+
+func testScreenRecorder() testResult {
+	rec, err := goautogui.NewScreenRecorder(image.Rect(0, 0, 400, 300), 10, goautogui.WithKeepLast(time.Second), goautogui.WithCursorMarker())
+	if err != nil {
+		return testResult{"ScreenRecorder", err}
+	}
+	if err := rec.Start(); err != nil {
+		return testResult{"ScreenRecorder", err}
+	}
+	goautogui.DragRel(200, 100, 2*time.Second)
+	if err := rec.Stop(); err != nil {
+		return testResult{"ScreenRecorder", err}
+	}
+	for _, name := range []string{"recording.gif", "recording.png", "recording.avi"} {
+		if err := rec.Save(filepath.Join(os.TempDir(), name)); err != nil {
+			return testResult{"ScreenRecorder", fmt.Errorf("%s: %v", name, err)}
+		}
+	}
+	return testResult{"ScreenRecorder", nil}
+}
+
+func testCaptureWithCursor() testResult {
+	goautogui.SetCursorPosition(200, 200)
+	rect := image.Rect(100, 100, 300, 300)
+	img, err := goautogui.CaptureRect(rect, goautogui.WithCursor())
+	if err != nil {
+		return testResult{"CaptureWithCursor", err}
+	}
+	// Mark where the cursor hotspot should be
+	goautogui.DrawMarker(img, image.Pt(200, 200).Sub(rect.Min), goautogui.MarkerCircle, 12, color.RGBA{G: 0xFF, A: 0xFF})
+	openImage(img, "capture_cursor_test_image")
+	return testResult{"CaptureWithCursor", nil}
+}
+
+func testCaptureRegionValidation() testResult {
+	if _, err := goautogui.Capture(0, 0, -10, 10); !errors.Is(err, goautogui.ErrEmptyRegion) {
+		return testResult{"CaptureRegionValidation", fmt.Errorf("negative size: got %v, want ErrEmptyRegion", err)}
+	}
+
+	primary := goautogui.GetDisplayBounds(0)
+	region := image.Rect(primary.Min.X-50, primary.Min.Y-50, primary.Min.X+50, primary.Min.Y+50)
+	// Only off-screen if no monitor is to the left or above the primary one
+	offScreen := true
+	for _, r := range goautogui.GetAllDisplayBounds() {
+		if r.Min.X < primary.Min.X || r.Min.Y < primary.Min.Y {
+			offScreen = false
+		}
+	}
+	if offScreen {
+		var offErr *goautogui.RegionOffScreenError
+		_, err := goautogui.CaptureRect(region)
+		if !errors.As(err, &offErr) || !errors.Is(err, goautogui.ErrRegionOffScreen) || offErr.Rect != region {
+			return testResult{"CaptureRegionValidation", fmt.Errorf("partly off-screen: got %v, want *RegionOffScreenError", err)}
+		}
+		img, err := goautogui.CaptureRect(region, goautogui.WithClip())
+		if err != nil {
+			return testResult{"CaptureRegionValidation", err}
+		}
+		if want := image.Rect(50, 50, 100, 100); img.Bounds() != want {
+			return testResult{"CaptureRegionValidation", fmt.Errorf("clipped bounds %v, want %v", img.Bounds(), want)}
+		}
+	}
+	fmt.Println("CaptureRegionValidation test passed")
+	return testResult{"CaptureRegionValidation", nil}
+}
+
+func testDiff() testResult {
+	rect := image.Rect(100, 100, 400, 300)
+	a, err := goautogui.CaptureRect(rect)
+	if err != nil {
+		return testResult{"Diff", err}
+	}
+	b, err := goautogui.CaptureRect(rect)
+	if err != nil {
+		return testResult{"Diff", err}
+	}
+	goautogui.DrawMarker(b, image.Pt(150, 100), goautogui.MarkerCircle, 10, color.RGBA{R: 0xFF, A: 0xFF})
+
+	res, err := goautogui.Diff(a, b, goautogui.WithTolerance(8))
+	if err != nil {
+		return testResult{"Diff", err}
+	}
+	fmt.Println("Diff:", res, res.Regions)
+	if res.Equal() || len(res.Regions) == 0 || !image.Pt(150, 100).In(res.Regions[0]) {
+		return testResult{"Diff", errors.New("marker not reported as a changed region")}
+	}
+	res, err = goautogui.Diff(a, b, goautogui.WithTolerance(8), goautogui.WithIgnore(image.Rect(130, 80, 170, 120)))
+	if err != nil {
+		return testResult{"Diff", err}
+	}
+	if !res.Equal() {
+		return testResult{"Diff", fmt.Errorf("ignored area still reported: %v", res)}
+	}
+	openImage(res.Image, "diff_test_image")
+	fmt.Println("Diff test passed")
+	return testResult{"Diff", nil}
+}
+
+func testReadText() testResult {
+	// Reads the title bar of the foreground window, which should contain its title
+	hwnd := win32.GetForegroundWindow()
+	var rc win32.RECT
+	win32.GetWindowRect(hwnd, &rc)
+	region := image.Rect(int(rc.Left), int(rc.Top), int(rc.Right), int(rc.Top)+32)
+
+	start := time.Now()
+	text, err := goautogui.ReadText(region)
+	if err != nil {
+		return testResult{"ReadText", err}
+	}
+	fmt.Printf("ReadText read %q in %v\n", text, time.Since(start))
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return testResult{"ReadText", errors.New("no text recognised in the title bar")}
+	}
+	box, err := goautogui.FindText(region, words[0])
+	if err != nil {
+		return testResult{"ReadText", err}
+	}
+	if !box.In(region) {
+		return testResult{"ReadText", fmt.Errorf("FindText box %v is outside the region %v", box, region)}
+	}
+	fmt.Println("ReadText test passed")
+	return testResult{"ReadText", nil}
+}
+
+func testFindColor() testResult {
+	// FindColorInImage and FindColorBlobs are unit tested; this finds whatever colour is
+	// under the top-left pixel on screen
+	region := image.Rect(0, 0, 200, 200)
+	c, err := goautogui.Pixel(0, 0)
+	if err != nil {
+		return testResult{"FindColor", err}
+	}
+	p, err := goautogui.FindColor(region, c, 0)
+	if err != nil {
+		return testResult{"FindColor", err}
+	}
+	if p != image.Pt(0, 0) {
+		return testResult{"FindColor", fmt.Errorf("first match at %v, want (0,0)", p)}
+	}
+	fmt.Println("FindColor test passed")
+	return testResult{"FindColor", nil}
+}
+
+func testRecorder() testResult {
+	rec := goautogui.NewRecorder(goautogui.WithInjectedInput(), goautogui.WithMoveInterval(0))
+	if err := rec.Start(); err != nil {
+		return testResult{"Recorder", err}
+	}
+	goautogui.SetCursorPosition(300, 300)
+	goautogui.SetCursorPosition(320, 310)
+	goautogui.VKeyDown(goautogui.KEY_SHIFT)
+	goautogui.VKeyUp(goautogui.KEY_SHIFT)
+	time.Sleep(200 * time.Millisecond)
+	recording, err := rec.Stop()
+	if err != nil {
+		return testResult{"Recorder", err}
+	}
+
+	var moved, shifted bool
+	for _, e := range recording.Events {
+		switch {
+		case e.Kind == goautogui.EventMouseMove && e.X == 320 && e.Y == 310:
+			moved = true
+		case e.Kind == goautogui.EventKeyUp && (e.Key == goautogui.KEY_SHIFT || e.Key == goautogui.KEY_LSHIFT):
+			shifted = true
+		}
+	}
+	fmt.Printf("Recorded %d events\n", len(recording.Events))
+	if !moved || !shifted {
+		return testResult{"Recorder", fmt.Errorf("events %v are missing the move or the shift key", recording.Events)}
+	}
+
+	// Round trip through the file format
+	path := filepath.Join(os.TempDir(), "goautogui_recording.json")
+	defer os.Remove(path)
+	if err := recording.Save(path); err != nil {
+		return testResult{"Recorder", err}
+	}
+	loaded, err := goautogui.LoadRecording(path)
+	if err != nil {
+		return testResult{"Recorder", err}
+	}
+	if len(loaded.Events) != len(recording.Events) || loaded.Screen != recording.Screen {
+		return testResult{"Recorder", errors.New("loaded recording differs from the saved one")}
+	}
+	fmt.Println("Recorder test passed")
+	return testResult{"Recorder", nil}
+}
+
+func testPlayer() testResult {
+	// Wait for a patch of the screen taken a moment earlier, which must still be there
+	patch, err := goautogui.CaptureRect(image.Rect(100, 100, 140, 130))
+	if err != nil {
+		return testResult{"Player", err}
+	}
+	shot := filepath.Join(os.TempDir(), "goautogui_player.png")
+	defer os.Remove(shot)
+	script := []goautogui.Action{
+		{Kind: goautogui.ActionMove, Point: image.Pt(400, 300)},
+		{Kind: goautogui.ActionWait, Duration: 200 * time.Millisecond},
+		{Kind: goautogui.ActionMove, Point: image.Pt(10, 10), Breakpoint: true},
+		{Kind: goautogui.ActionWaitForImage, Image: patch, Region: image.Rect(0, 0, 300, 300), Duration: 2 * time.Second},
+		{Kind: goautogui.ActionScreenshot, Region: image.Rect(0, 0, 64, 64), File: shot},
+	}
+
+	// The breakpoint skips the move to (10,10); the 2x speed halves the wait
+	paused := -1
+	player := goautogui.NewPlayer(goautogui.WithSpeed(2), goautogui.WithDebugger(func(step int, a goautogui.Action) goautogui.DebugCommand {
+		if paused < 0 {
+			paused = step
+			return goautogui.DebugSkip
+		}
+		return goautogui.DebugContinue
+	}))
+	logs, err := player.Run(context.Background(), script)
+	if err != nil {
+		return testResult{"Player", err}
+	}
+	for _, l := range logs {
+		fmt.Printf("  step %d %-40s attempts=%d %v %s\n", l.Step, l.Action, l.Attempts, l.Duration.Round(time.Millisecond), l.Result)
+	}
+	if paused != 2 || !logs[2].Skipped {
+		return testResult{"Player", fmt.Errorf("paused at step %d, want 2", paused)}
+	}
+	if pos := goautogui.Position(); pos.X != 400 || pos.Y != 300 {
+		return testResult{"Player", fmt.Errorf("cursor at (%d,%d), want (400,300)", pos.X, pos.Y)}
+	}
+	if d := logs[1].Duration; d < 90*time.Millisecond || d > 190*time.Millisecond {
+		return testResult{"Player", fmt.Errorf("wait took %v at 2x speed, want about 100ms", d)}
+	}
+	if logs[3].Result != "found at (100,100)-(140,130)" {
+		return testResult{"Player", fmt.Errorf("waitForImage %s, want found at (100,100)-(140,130)", logs[3].Result)}
+	}
+	if _, err := os.Stat(shot); err != nil {
+		return testResult{"Player", err}
+	}
+
+	// A failing step is retried and reported as a StepError
+	missing := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	missing.Set(0, 0, color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xFF}) // the rest is transparent
+	player = goautogui.NewPlayer(goautogui.WithRetries(1, 0))
+	logs, err = player.Run(context.Background(), []goautogui.Action{
+		{Kind: goautogui.ActionWaitForImage, Image: missing, Region: image.Rect(0, 0, 8, 8), Duration: 200 * time.Millisecond},
+	})
+	var stepErr *goautogui.StepError
+	if !errors.As(err, &stepErr) || !errors.Is(err, goautogui.ErrImageNotFound) || logs[0].Attempts != 2 {
+		return testResult{"Player", fmt.Errorf("missing image: got %v after %d attempts, want a StepError after 2", err, logs[0].Attempts)}
+	}
+	fmt.Println("Player test passed")
+	return testResult{"Player", nil}
+}
+
+// testScript runs a script on the real screen; the interpreter itself is covered by the
+// tests of the script package, against a FakeSink.
+func testScript() testResult {
+	prog, err := script.Parse("", []byte("move 300,300 over 200ms\nmove 320,310\n"))
+	if err != nil {
+		return testResult{"Script", err}
+	}
+	if err := script.NewInterpreter(script.NewWindowsSink()).Run(context.Background(), prog); err != nil {
+		return testResult{"Script", err}
+	}
+	if pos := goautogui.Position(); pos.X != 320 || pos.Y != 310 {
+		return testResult{"Script", fmt.Errorf("cursor at (%d,%d), want (320,310)", pos.X, pos.Y)}
+	}
+	fmt.Println("Script test passed")
+	return testResult{"Script", nil}
+}
+
+func testWindowsAndFailSafe() testResult {
+	windows := goautogui.ListWindows()
+	if len(windows) == 0 {
+		return testResult{"WindowsAndFailSafe", fmt.Errorf("ListWindows found no windows")}
+	}
+	var front goautogui.Window
+	for _, w := range windows {
+		if w.Foreground {
+			front = w
+		}
+	}
+	if front.Hwnd != 0 {
+		if found := goautogui.FindWindows(strings.ToUpper(front.Title)); len(found) == 0 {
+			return testResult{"WindowsAndFailSafe", fmt.Errorf("FindWindows did not find %q", front.Title)}
+		}
+		if err := goautogui.ActivateWindow(front.Hwnd); err != nil {
+			return testResult{"WindowsAndFailSafe", err}
+		}
+	}
+	if found := goautogui.FindWindows("no window is called this 7f3a"); len(found) != 0 {
+		return testResult{"WindowsAndFailSafe", fmt.Errorf("FindWindows found %d windows for a made-up title", len(found))}
+	}
+
+	// The fail-safe stops a player before the first action
+	goautogui.Move(0, 0)
+	player := goautogui.NewPlayer(goautogui.WithFailSafe())
+	_, err := player.Run(context.Background(), []goautogui.Action{{Kind: goautogui.ActionMove, Point: image.Pt(300, 300)}})
+	if !goautogui.FailSafeTriggered() || !errors.Is(err, goautogui.ErrFailSafe) {
+		return testResult{"WindowsAndFailSafe", fmt.Errorf("got %v with the cursor in the corner, want ErrFailSafe", err)}
+	}
+	goautogui.Move(300, 300)
+	if _, err := player.Run(context.Background(), []goautogui.Action{{Kind: goautogui.ActionMove, Point: image.Pt(320, 320)}}); err != nil {
+		return testResult{"WindowsAndFailSafe", err}
+	}
+	fmt.Println("Windows and fail-safe test passed")
+	return testResult{"WindowsAndFailSafe", nil}
+}
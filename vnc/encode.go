@@ -0,0 +1,369 @@
+package vnc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"image"
+	"image/draw"
+)
+
+// tileSize is the size of the tiles compared between frames, and of the tiles of ZRLE.
+const tileSize = 64
+
+// minScroll is the fewest rows a scroll must move for it to be sent as a CopyRect.
+const minScroll = 32
+
+// pixelFormat is an RFB pixel format. Only true colour is supported, not colour maps.
+type pixelFormat struct {
+	bpp, depth                      uint8
+	bigEndian, trueColour           bool
+	redMax, greenMax, blueMax       uint16
+	redShift, greenShift, blueShift uint8
+}
+
+// defaultPixelFormat is 32-bit little-endian 0x00RRGGBB, how Windows stores pixels.
+var defaultPixelFormat = pixelFormat{
+	bpp: 32, depth: 24, trueColour: true,
+	redMax: 255, greenMax: 255, blueMax: 255,
+	redShift: 16, greenShift: 8, blueShift: 0,
+}
+
+func parsePixelFormat(b []byte) pixelFormat {
+	return pixelFormat{
+		bpp: b[0], depth: b[1], bigEndian: b[2] != 0, trueColour: b[3] != 0,
+		redMax:   binary.BigEndian.Uint16(b[4:]),
+		greenMax: binary.BigEndian.Uint16(b[6:]),
+		blueMax:  binary.BigEndian.Uint16(b[8:]),
+		redShift: b[10], greenShift: b[11], blueShift: b[12],
+	}
+}
+
+func (pf pixelFormat) marshal() []byte {
+	b := []byte{pf.bpp, pf.depth, 0, 0}
+	if pf.bigEndian {
+		b[2] = 1
+	}
+	if pf.trueColour {
+		b[3] = 1
+	}
+	for _, max := range []uint16{pf.redMax, pf.greenMax, pf.blueMax} {
+		b = binary.BigEndian.AppendUint16(b, max)
+	}
+	return append(b, pf.redShift, pf.greenShift, pf.blueShift, 0, 0, 0)
+}
+
+func (pf pixelFormat) validate() error {
+	if !pf.trueColour {
+		return errors.New("colour map pixel formats are not supported")
+	}
+	if pf.bpp != 8 && pf.bpp != 16 && pf.bpp != 32 {
+		return errors.New("pixels must be 8, 16 or 32 bits")
+	}
+	return nil
+}
+
+// pixel returns the value of a colour in the format.
+func (pf pixelFormat) pixel(r, g, b uint8) uint32 {
+	scale := func(v uint8, max uint16) uint32 {
+		return (uint32(v)*uint32(max) + 127) / 255
+	}
+	return scale(r, pf.redMax)<<pf.redShift | scale(g, pf.greenMax)<<pf.greenShift | scale(b, pf.blueMax)<<pf.blueShift
+}
+
+// put appends a pixel value in the byte order of the format.
+func (pf pixelFormat) put(dst []byte, v uint32) []byte {
+	switch {
+	case pf.bpp == 8:
+		return append(dst, byte(v))
+	case pf.bpp == 16 && pf.bigEndian:
+		return binary.BigEndian.AppendUint16(dst, uint16(v))
+	case pf.bpp == 16:
+		return binary.LittleEndian.AppendUint16(dst, uint16(v))
+	case pf.bigEndian:
+		return binary.BigEndian.AppendUint32(dst, v)
+	default:
+		return binary.LittleEndian.AppendUint32(dst, v)
+	}
+}
+
+// putCompact appends a pixel value as a ZRLE CPIXEL: like put, but 3 bytes rather than 4
+// if the colours fit in either the lowest or the highest 3 bytes of a 32-bit pixel.
+func (pf pixelFormat) putCompact(dst []byte, v uint32) []byte {
+	if pf.bpp != 32 || pf.depth > 24 {
+		return pf.put(dst, v)
+	}
+	all := uint32(pf.redMax)<<pf.redShift | uint32(pf.greenMax)<<pf.greenShift | uint32(pf.blueMax)<<pf.blueShift
+	switch low := all < 1<<24; {
+	case low && pf.bigEndian:
+		return append(dst, byte(v>>16), byte(v>>8), byte(v))
+	case low:
+		return append(dst, byte(v), byte(v>>8), byte(v>>16))
+	case all&0xFF == 0 && pf.bigEndian:
+		return append(dst, byte(v>>24), byte(v>>16), byte(v>>8))
+	case all&0xFF == 0:
+		return append(dst, byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	return pf.put(dst, v)
+}
+
+// pixels returns the values of the pixels of img in r, row by row.
+func (pf pixelFormat) pixels(img *image.RGBA, r image.Rectangle) []uint32 {
+	px := make([]uint32, 0, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			px = append(px, pf.pixel(row[i], row[i+1], row[i+2]))
+		}
+	}
+	return px
+}
+
+func encodeRaw(img *image.RGBA, r image.Rectangle, pf pixelFormat) []byte {
+	data := make([]byte, 0, r.Dx()*r.Dy()*int(pf.bpp/8))
+	for _, v := range pf.pixels(img, r) {
+		data = pf.put(data, v)
+	}
+	return data
+}
+
+// zrleEncoder encodes rectangles with ZRLE. The zlib stream lasts as long as the
+// connection, as the viewer keeps a single decompressor.
+type zrleEncoder struct {
+	out  bytes.Buffer
+	zw   *zlib.Writer
+	tile []byte
+}
+
+func newZRLEEncoder() *zrleEncoder {
+	e := &zrleEncoder{}
+	e.zw = zlib.NewWriter(&e.out)
+	return e
+}
+
+// encode returns the compressed ZRLE data of the area r of img, without its length.
+func (e *zrleEncoder) encode(img *image.RGBA, r image.Rectangle, pf pixelFormat) ([]byte, error) {
+	for y := r.Min.Y; y < r.Max.Y; y += tileSize {
+		for x := r.Min.X; x < r.Max.X; x += tileSize {
+			t := image.Rect(x, y, x+tileSize, y+tileSize).Intersect(r)
+			e.tile = encodeZRLETile(e.tile[:0], pf.pixels(img, t), t.Dx(), pf)
+			if _, err := e.zw.Write(e.tile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := e.zw.Flush(); err != nil {
+		return nil, err
+	}
+	data := bytes.Clone(e.out.Bytes())
+	e.out.Reset()
+	return data, nil
+}
+
+// encodeZRLETile appends the encoding of a tile of width w to dst, choosing the smallest
+// of the raw, solid, packed palette, plain RLE and palette RLE subencodings.
+func encodeZRLETile(dst []byte, px []uint32, w int, pf pixelFormat) []byte {
+	cpixel := len(pf.putCompact(nil, 0))
+	palette := map[uint32]int{}
+	var colours []uint32
+	plainRLE, paletteRLE := 0, 0
+	for i := 0; i < len(px); {
+		n := runLength(px[i:])
+		plainRLE += cpixel + runBytes(n)
+		paletteRLE++
+		if n > 1 {
+			paletteRLE += runBytes(n)
+		}
+		if _, ok := palette[px[i]]; !ok && len(colours) < 128 {
+			palette[px[i]] = len(colours)
+			colours = append(colours, px[i])
+		}
+		i += n
+	}
+
+	if len(colours) == 1 {
+		return pf.putCompact(append(dst, 1), px[0])
+	}
+	best, size := 0, len(px)*cpixel
+	if plainRLE < size {
+		best, size = 128, plainRLE
+	}
+	if len(colours) <= 127 && len(colours)*cpixel+paletteRLE < size {
+		best, size = 128+len(colours), len(colours)*cpixel+paletteRLE
+	}
+	bits := paletteBits(len(colours))
+	if len(colours) <= 16 && len(colours)*cpixel+(len(px)/w)*((w*bits+7)/8) < size {
+		best = len(colours)
+	}
+
+	dst = append(dst, byte(best))
+	switch {
+	case best == 0:
+		for _, v := range px {
+			dst = pf.putCompact(dst, v)
+		}
+	case best <= 16:
+		for _, v := range colours {
+			dst = pf.putCompact(dst, v)
+		}
+		for row := 0; row < len(px); row += w {
+			var acc byte
+			used := 0
+			for _, v := range px[row : row+w] {
+				acc |= byte(palette[v]) << (8 - bits - used)
+				if used += bits; used == 8 {
+					dst = append(dst, acc)
+					acc, used = 0, 0
+				}
+			}
+			if used > 0 {
+				dst = append(dst, acc)
+			}
+		}
+	case best == 128:
+		for i := 0; i < len(px); {
+			n := runLength(px[i:])
+			dst = appendRun(pf.putCompact(dst, px[i]), n)
+			i += n
+		}
+	default:
+		for _, v := range colours {
+			dst = pf.putCompact(dst, v)
+		}
+		for i := 0; i < len(px); {
+			n := runLength(px[i:])
+			if n == 1 {
+				dst = append(dst, byte(palette[px[i]]))
+			} else {
+				dst = appendRun(append(dst, byte(palette[px[i]])|128), n)
+			}
+			i += n
+		}
+	}
+	return dst
+}
+
+// paletteBits returns the bits per index of a packed palette of n colours.
+func paletteBits(n int) int {
+	switch {
+	case n <= 2:
+		return 1
+	case n <= 4:
+		return 2
+	}
+	return 4
+}
+
+// runLength returns how many pixels at the start of px have the same value.
+func runLength(px []uint32) int {
+	n := 1
+	for n < len(px) && px[n] == px[0] {
+		n++
+	}
+	return n
+}
+
+// runBytes returns the size of the encoding of a run of length n.
+func runBytes(n int) int {
+	return (n-1)/255 + 1
+}
+
+// appendRun appends the length of a run: n-1 as a sum of bytes, all but the last 255.
+func appendRun(dst []byte, n int) []byte {
+	for n--; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+// fit crops or pads img to bounds, for viewers that cannot resize their framebuffer.
+func fit(img *image.RGBA, bounds image.Rectangle) *image.RGBA {
+	fitted := image.NewRGBA(bounds)
+	draw.Draw(fitted, bounds, img, image.Point{}, draw.Src)
+	return fitted
+}
+
+// copyFrom copies the area r of src to dst.
+func copyFrom(dst, src *image.RGBA, r image.Rectangle) {
+	draw.Draw(dst, r, src, r.Min, draw.Src)
+}
+
+// copyArea copies the area of img at src to dst within img, as a viewer does for a CopyRect.
+func copyArea(img *image.RGBA, dst image.Rectangle, src image.Point) {
+	draw.Draw(img, dst, img, src, draw.Src)
+}
+
+// scrolled looks for content of prev that moved up or down in cur, as when a window
+// scrolls: the longest band of at least minScroll full-width rows of cur that are rows of
+// prev shifted by the same amount. It returns the band in cur and where it was in prev.
+func scrolled(prev, cur *image.RGBA) (dst image.Rectangle, src image.Point, ok bool) {
+	b := cur.Bounds()
+	rowHash := func(img *image.RGBA, y int) (uint64, bool) {
+		row := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		// Rows of a single colour match each other anywhere, so they say nothing
+		if bytes.Equal(row[4:], row[:len(row)-4]) {
+			return 0, false
+		}
+		h := fnv.New64a()
+		h.Write(row)
+		return h.Sum64(), true
+	}
+	prevRows := map[uint64][]int{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		if h, ok := rowHash(prev, y); ok && len(prevRows[h]) < 4 {
+			prevRows[h] = append(prevRows[h], y)
+		}
+	}
+
+	// Each changed row of cur votes for the shifts that would explain it
+	votes := map[int]int{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		h, ok := rowHash(cur, y)
+		if !ok {
+			continue
+		}
+		for _, py := range prevRows[h] {
+			if py != y {
+				votes[y-py]++
+			}
+		}
+	}
+	dy, most := 0, 0
+	for d, n := range votes {
+		if n > most || n == most && abs(d) < abs(dy) {
+			dy, most = d, n
+		}
+	}
+	if most < minScroll {
+		return image.Rectangle{}, image.Point{}, false
+	}
+
+	rowEqual := func(y int) bool {
+		py := y - dy
+		if py < b.Min.Y || py >= b.Max.Y {
+			return false
+		}
+		return bytes.Equal(cur.Pix[cur.PixOffset(b.Min.X, y):cur.PixOffset(b.Max.X, y)],
+			prev.Pix[prev.PixOffset(b.Min.X, py):prev.PixOffset(b.Max.X, py)])
+	}
+	start, best := b.Min.Y, image.Rectangle{}
+	for y := b.Min.Y; y <= b.Max.Y; y++ {
+		if y < b.Max.Y && rowEqual(y) {
+			continue
+		}
+		if band := image.Rect(b.Min.X, start, b.Max.X, y); band.Dy() > best.Dy() {
+			best = band
+		}
+		start = y + 1
+	}
+	if best.Dy() < minScroll {
+		return image.Rectangle{}, image.Point{}, false
+	}
+	return best, image.Pt(b.Min.X, best.Min.Y-dy), true
+}
+
+func abs(n int) int {
+	return max(n, -n)
+}
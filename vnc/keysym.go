@@ -0,0 +1,67 @@
+package vnc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keysyms maps the X keysyms of keys that do not type a character to the key names of the
+// windows package.
+var keysyms = map[uint32]string{
+	0xFF08: "backspace", 0xFF09: "tab", 0xFF0D: "enter", 0xFF13: "pause",
+	0xFF14: "scrolllock", 0xFF1B: "esc", 0xFFFF: "delete",
+	0xFF50: "home", 0xFF51: "left", 0xFF52: "up", 0xFF53: "right", 0xFF54: "down",
+	0xFF55: "pageup", 0xFF56: "pagedown", 0xFF57: "end",
+	0xFF61: "printscreen", 0xFF63: "insert", 0xFF67: "apps", 0xFF7F: "numlock",
+
+	// Keypad
+	0xFF80: "space", 0xFF89: "tab", 0xFF8D: "enter",
+	0xFF95: "home", 0xFF96: "left", 0xFF97: "up", 0xFF98: "right", 0xFF99: "down",
+	0xFF9A: "pageup", 0xFF9B: "pagedown", 0xFF9C: "end", 0xFF9E: "insert", 0xFF9F: "delete",
+	0xFFAA: "multiply", 0xFFAB: "add", 0xFFAC: "separator", 0xFFAD: "subtract",
+	0xFFAE: "decimal", 0xFFAF: "divide", 0xFFBD: "=",
+
+	// Modifiers; AltGr is ISO_Level3_Shift
+	0xFFE1: "shiftleft", 0xFFE2: "shiftright", 0xFFE3: "ctrlleft", 0xFFE4: "ctrlright",
+	0xFFE5: "capslock", 0xFFE7: "winleft", 0xFFE8: "winright", 0xFFE9: "altleft",
+	0xFFEA: "altright", 0xFFEB: "winleft", 0xFFEC: "winright", 0xFE03: "altright",
+
+	// XF86 media keys
+	0x1008FF11: "volumedown", 0x1008FF12: "volumemute", 0x1008FF13: "volumeup",
+	0x1008FF14: "playpause", 0x1008FF15: "stop", 0x1008FF16: "prevtrack",
+	0x1008FF17: "nexttrack", 0x1008FF26: "browserback", 0x1008FF27: "browserforward",
+	0x1008FF29: "browserrefresh", 0x1008FF18: "browserhome",
+}
+
+func init() {
+	for i := uint32(0); i < 24; i++ {
+		keysyms[0xFFBE+i] = fmt.Sprintf("f%d", i+1)
+	}
+	for i := uint32(0); i < 10; i++ {
+		keysyms[0xFFB0+i] = fmt.Sprintf("num%d", i)
+	}
+}
+
+// KeyName returns the name of the key of an X keysym, as RFB key events carry them, in the
+// form goautogui.KeyByName of the windows package accepts: a name such as enter, shiftleft
+// or f5, or the character the key types. Letters are lower case, as viewers send the
+// keysym of the shifted character while shift is held. It reports false for keysyms
+// without a key.
+func KeyName(keysym uint32) (string, bool) {
+	if name, ok := keysyms[keysym]; ok {
+		return name, true
+	}
+	var r rune
+	switch {
+	case keysym == 0x20:
+		return "space", true
+	case keysym > 0x20 && keysym <= 0x7E, keysym >= 0xA0 && keysym <= 0xFF:
+		// Latin-1 keysyms are the code points
+		r = rune(keysym)
+	case keysym >= 0x01000100 && keysym <= 0x0110FFFF:
+		r = rune(keysym - 0x01000000)
+	default:
+		return "", false
+	}
+	return strings.ToLower(string(r)), true
+}
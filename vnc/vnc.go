@@ -0,0 +1,699 @@
+// Package vnc serves a display of a server.LiveBackend over the RFB protocol, so that any
+// VNC viewer can watch and control a machine without a separate VNC server installed.
+//
+// The server speaks RFB 3.8, and 3.7 and 3.3 to older viewers, with no authentication or,
+// given WithPassword, VNC Authentication. Framebuffer updates are sent with the Raw,
+// CopyRect and ZRLE encodings, in the pixel format the viewer asks for, and with the
+// DesktopSize pseudo-encoding when the display changes size. Pointer and key events are
+// injected with the raw input methods of the backend, translating X keysyms to key names
+// with KeyName. The clipboard is not shared.
+package vnc
+
+import (
+	"bufio"
+	"context"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math/bits"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mhmdibrahimm/goautogui/internal/tiles"
+	"github.com/mhmdibrahimm/goautogui/server"
+)
+
+// DefaultAddr is the address ListenAndServe listens on if none is given: the VNC port of
+// display :0, on the loopback interface only.
+const DefaultAddr = "127.0.0.1:5900"
+
+// Security types.
+const (
+	securityNone    = 1
+	securityVNCAuth = 2
+)
+
+// Client to server message types.
+const (
+	msgSetPixelFormat           = 0
+	msgSetEncodings             = 2
+	msgFramebufferUpdateRequest = 3
+	msgKeyEvent                 = 4
+	msgPointerEvent             = 5
+	msgClientCutText            = 6
+)
+
+// Encodings and pseudo-encodings.
+const (
+	encodingRaw         = 0
+	encodingCopyRect    = 1
+	encodingZRLE        = 16
+	encodingDesktopSize = -223
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithPassword requires viewers to log in with VNC Authentication. As with every VNC
+// server, only the first 8 characters of the password count.
+func WithPassword(password string) Option {
+	return func(s *Server) {
+		s.password = password
+	}
+}
+
+// WithDisplay serves the display at index instead of the primary display, 0.
+func WithDisplay(index int) Option {
+	return func(s *Server) {
+		s.display = index
+	}
+}
+
+// WithViewOnly ignores the pointer and key events of viewers.
+func WithViewOnly() Option {
+	return func(s *Server) {
+		s.viewOnly = true
+	}
+}
+
+// WithFPS sets how many times a second the display is captured while a viewer waits for
+// changes. The default is 10. An fps that is not positive, is NaN or is so large that no
+// time passes between captures is ignored, keeping the default.
+func WithFPS(fps float64) Option {
+	return func(s *Server) {
+		// Written so that NaN is ignored too
+		if interval := time.Duration(float64(time.Second) / fps); fps > 0 && interval > 0 {
+			s.interval = interval
+		}
+	}
+}
+
+// WithErrorLog logs the input a viewer sent that the backend failed to perform, such as
+// events refused by the fail-safe, to l. By default they are logged with the log package.
+// A failure is logged once, until the input of the viewer works again.
+func WithErrorLog(l *log.Logger) Option {
+	return func(s *Server) {
+		s.errorLog = l
+	}
+}
+
+// Server is an RFB server for a display of a LiveBackend.
+type Server struct {
+	backend  server.LiveBackend
+	password string
+	display  int
+	viewOnly bool
+	interval time.Duration
+	errorLog *log.Logger
+}
+
+// New creates a Server that captures and controls the display of backend.
+func New(backend server.LiveBackend, opts ...Option) *Server {
+	s := &Server{backend: backend, interval: 100 * time.Millisecond}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListenAndServe serves viewers on addr until ctx is cancelled, and then returns
+// ctx.Err(). It refuses to listen beyond the loopback interface without a password.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	if s.password == "" && !isLoopback(addr) {
+		return fmt.Errorf("refusing to listen on %s without a password", addr)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve serves each connection accepted on l until ctx is cancelled, and then closes l and
+// the connections and returns ctx.Err().
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.ServeConn(ctx, conn)
+		}()
+	}
+}
+
+// ServeConn serves the viewer on conn until it disconnects or ctx is cancelled, and closes
+// conn. Keys and buttons the viewer still holds are released.
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c := &session{
+		s:    s,
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+		pf:   defaultPixelFormat,
+		wake: make(chan struct{}, 1),
+		keys: map[string]bool{},
+		zrle: newZRLEEncoder(),
+		enc:  encodingRaw,
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := c.handshake(); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	updateErr := make(chan error, 1)
+	go func() {
+		err := c.updates(ctx)
+		conn.Close() // stops receive
+		updateErr <- err
+	}()
+	err := c.receive()
+	cancel()
+	if uerr := <-updateErr; !errors.Is(uerr, context.Canceled) && uerr != nil {
+		err = uerr
+	}
+	c.releaseHeld()
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// session is the connection of one viewer.
+type session struct {
+	s    *Server
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer // written by updates once the handshake is done
+	zrle *zrleEncoder
+	fb   *image.RGBA // the framebuffer as the viewer has it
+
+	mu          sync.Mutex
+	pf          pixelFormat
+	enc         int32 // Raw or ZRLE
+	copyRect    bool
+	desktopSize bool
+	pending     *updateRequest
+	wake        chan struct{} // signalled when a request is pending
+	origin      image.Point   // of the display, to which event coordinates are relative
+	cursor      image.Point
+	mask        byte // of the last pointer event
+	keys        map[string]bool
+	inputErr    string // the last input failure logged, until input works again
+}
+
+type updateRequest struct {
+	incremental bool
+	rect        image.Rectangle
+}
+
+func (c *session) handshake() error {
+	if _, err := io.WriteString(c.conn, "RFB 003.008\n"); err != nil {
+		return err
+	}
+	var version [12]byte
+	if _, err := io.ReadFull(c.r, version[:]); err != nil {
+		return err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor); err != nil || major != 3 {
+		return fmt.Errorf("unsupported protocol version %q", version)
+	}
+
+	security := byte(securityNone)
+	if c.s.password != "" {
+		security = securityVNCAuth
+	}
+	if minor < 7 {
+		// RFB 3.3: the server decides
+		if err := c.write(uint32(security)); err != nil {
+			return err
+		}
+	} else {
+		if err := c.write([]byte{1, security}); err != nil {
+			return err
+		}
+		chosen, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if chosen != security {
+			return c.refuse(minor, fmt.Sprintf("security type %d is not offered", chosen))
+		}
+	}
+	if security == securityVNCAuth {
+		challenge := make([]byte, 16)
+		rand.Read(challenge)
+		if err := c.write(challenge); err != nil {
+			return err
+		}
+		response := make([]byte, 16)
+		if _, err := io.ReadFull(c.r, response); err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(response, vncAuthResponse(c.s.password, challenge)) != 1 {
+			return c.refuse(minor, "authentication failed")
+		}
+	}
+	// RFB 3.3 and 3.7 only report the result of authenticating
+	if security == securityVNCAuth || minor >= 8 {
+		if err := c.write(uint32(0)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.r.ReadByte(); err != nil { // ClientInit; every viewer may share the display
+		return err
+	}
+	img, err := c.s.backend.CaptureDisplay(c.s.display)
+	if err != nil {
+		return err
+	}
+	size := img.Bounds().Size()
+	c.fb = image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	c.locate()
+	name := "goautogui"
+	if host, err := os.Hostname(); err == nil {
+		name += " on " + host
+	}
+	return c.write(uint16(size.X), uint16(size.Y), c.pf.marshal(), uint32(len(name)), []byte(name))
+}
+
+// write writes values to the connection in network byte order and flushes it.
+func (c *session) write(values ...any) error {
+	for _, v := range values {
+		if err := binary.Write(c.w, binary.BigEndian, v); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+// refuse reports a failed handshake to the viewer, with the reason from RFB 3.8 on.
+func (c *session) refuse(minor int, reason string) error {
+	if minor >= 8 {
+		c.write(uint32(1), uint32(len(reason)), []byte(reason))
+	} else {
+		c.write(uint32(1))
+	}
+	return errors.New(reason)
+}
+
+// vncAuthResponse encrypts challenge with DES, keyed with the password, as VNC
+// Authentication does: the password is cut or padded to 8 bytes and the bits of each
+// byte are reversed.
+func vncAuthResponse(password string, challenge []byte) []byte {
+	key := make([]byte, 8)
+	copy(key, password)
+	for i, b := range key {
+		key[i] = bits.Reverse8(b)
+	}
+	block, _ := des.NewCipher(key) // only fails for keys that are not 8 bytes
+	response := make([]byte, len(challenge))
+	for i := 0; i < len(challenge); i += des.BlockSize {
+		block.Encrypt(response[i:], challenge[i:])
+	}
+	return response
+}
+
+// locate finds the origin of the display, so that event coordinates can be made absolute.
+func (c *session) locate() {
+	origin := image.Point{}
+	if displays, err := c.s.backend.Displays(); err == nil && c.s.display < len(displays) {
+		origin = displays[c.s.display].Min
+	}
+	c.mu.Lock()
+	c.origin = origin
+	c.mu.Unlock()
+}
+
+// receive handles the messages of the viewer until it disconnects.
+func (c *session) receive() error {
+	buf := make([]byte, 20)
+	read := func(n int) ([]byte, error) {
+		_, err := io.ReadFull(c.r, buf[:n])
+		return buf[:n], err
+	}
+	for {
+		msgType, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case msgSetPixelFormat:
+			b, err := read(19)
+			if err != nil {
+				return err
+			}
+			pf := parsePixelFormat(b[3:])
+			if err := pf.validate(); err != nil {
+				return err
+			}
+			c.mu.Lock()
+			c.pf = pf
+			c.mu.Unlock()
+		case msgSetEncodings:
+			b, err := read(3)
+			if err != nil {
+				return err
+			}
+			encodings := make([]int32, binary.BigEndian.Uint16(b[1:]))
+			if err := binary.Read(c.r, binary.BigEndian, encodings); err != nil {
+				return err
+			}
+			c.setEncodings(encodings)
+		case msgFramebufferUpdateRequest:
+			b, err := read(9)
+			if err != nil {
+				return err
+			}
+			x, y := int(binary.BigEndian.Uint16(b[1:])), int(binary.BigEndian.Uint16(b[3:]))
+			w, h := int(binary.BigEndian.Uint16(b[5:])), int(binary.BigEndian.Uint16(b[7:]))
+			c.request(updateRequest{incremental: b[0] != 0, rect: image.Rect(x, y, x+w, y+h)})
+		case msgKeyEvent:
+			b, err := read(7)
+			if err != nil {
+				return err
+			}
+			c.key(binary.BigEndian.Uint32(b[3:]), b[0] != 0)
+		case msgPointerEvent:
+			b, err := read(5)
+			if err != nil {
+				return err
+			}
+			c.pointer(b[0], int(binary.BigEndian.Uint16(b[1:])), int(binary.BigEndian.Uint16(b[3:])))
+		case msgClientCutText:
+			b, err := read(7)
+			if err != nil {
+				return err
+			}
+			// A negative length is the extended clipboard format; skip either
+			n := int64(int32(binary.BigEndian.Uint32(b[3:])))
+			if _, err := io.CopyN(io.Discard, c.r, max(n, -n)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown message type %d", msgType)
+		}
+	}
+}
+
+func (c *session) setEncodings(encodings []int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enc, c.copyRect, c.desktopSize = encodingRaw, false, false
+	chosen := false
+	for _, e := range encodings {
+		switch e {
+		case encodingRaw, encodingZRLE:
+			// The viewer lists encodings in the order it prefers them
+			if !chosen {
+				c.enc, chosen = e, true
+			}
+		case encodingCopyRect:
+			c.copyRect = true
+		case encodingDesktopSize:
+			c.desktopSize = true
+		}
+	}
+}
+
+// request records a framebuffer update request, merging it with one still pending.
+func (c *session) request(req updateRequest) {
+	c.mu.Lock()
+	if c.pending != nil {
+		req.incremental = req.incremental && c.pending.incremental
+		req.rect = req.rect.Union(c.pending.rect)
+	}
+	c.pending = &req
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *session) takeRequest() *updateRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	req := c.pending
+	c.pending = nil
+	return req
+}
+
+func (c *session) key(keysym uint32, down bool) {
+	name, ok := KeyName(keysym)
+	if !ok || c.s.viewOnly {
+		return
+	}
+	err := c.s.backend.RawKey(name, down)
+	c.report(err)
+	// A key whose press failed is not held, and one whose release failed still is, so only
+	// track keys the backend pressed or released. Keeping the state also keeps a held key
+	// held when an auto-repeated press fails.
+	if err == nil {
+		c.mu.Lock()
+		c.keys[name] = down
+		c.mu.Unlock()
+	}
+}
+
+// pointerButtons are the buttons of the bits of a pointer event's button mask.
+var pointerButtons = []string{"left", "middle", "right"}
+
+// pointer injects a pointer event. Bits 3 to 6 of mask scroll up, down, left and right.
+func (c *session) pointer(mask byte, x, y int) {
+	if c.s.viewOnly {
+		return
+	}
+	c.mu.Lock()
+	p := c.origin.Add(image.Pt(x, y))
+	moved, prev := p != c.cursor, c.mask
+	c.cursor, c.mask = p, mask
+	c.mu.Unlock()
+
+	var errs []error
+	if moved {
+		errs = append(errs, c.s.backend.RawMove(p))
+	}
+	for i, button := range pointerButtons {
+		if bit := byte(1) << i; mask&bit != prev&bit {
+			errs = append(errs, c.s.backend.RawButton(p, button, mask&bit != 0))
+		}
+	}
+	for i, wheel := range []struct {
+		notches    float64
+		horizontal bool
+	}{{1, false}, {-1, false}, {-1, true}, {1, true}} {
+		if bit := byte(1) << (3 + i); mask&bit != 0 && prev&bit == 0 {
+			errs = append(errs, c.s.backend.RawWheel(p, wheel.notches, wheel.horizontal))
+		}
+	}
+	if len(errs) > 0 {
+		c.report(errors.Join(errs...))
+	}
+}
+
+// report logs the failure of an input event, unless it is the same as the last one.
+func (c *session) report(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.inputErr = ""
+		return
+	}
+	if msg := err.Error(); msg != c.inputErr {
+		c.inputErr = msg
+		c.s.logf("vnc: input of %s failed: %v", c.conn.RemoteAddr(), err)
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.errorLog != nil {
+		s.errorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// releaseHeld releases the keys and buttons the viewer pressed and did not release.
+func (c *session) releaseHeld() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, down := range c.keys {
+		if down {
+			c.s.backend.RawKey(key, false)
+		}
+	}
+	for i, button := range pointerButtons {
+		if c.mask&(1<<i) != 0 {
+			c.s.backend.RawButton(c.cursor, button, false)
+		}
+	}
+}
+
+// updates answers framebuffer update requests until ctx is cancelled. An incremental
+// request is answered once part of the display has changed.
+func (c *session) updates(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var req *updateRequest
+	for {
+		if next := c.takeRequest(); next != nil {
+			if req != nil {
+				next.incremental = next.incremental && req.incremental
+				next.rect = next.rect.Union(req.rect)
+			}
+			req = next
+		}
+		if req != nil {
+			sent, err := c.update(*req)
+			if err != nil {
+				return err
+			}
+			if sent {
+				req = nil
+				continue
+			}
+		}
+
+		// Wait for a request, or for the display to change
+		var tick <-chan time.Time
+		if req != nil {
+			timer.Reset(c.s.interval)
+			tick = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.wake:
+		case <-tick:
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// update captures the display and sends what req asks for, if anything. It reports whether
+// it sent an update.
+func (c *session) update(req updateRequest) (bool, error) {
+	img, err := c.s.backend.CaptureDisplay(c.s.display)
+	if err != nil {
+		// e.g. while the secure desktop is shown; try again with the next frame
+		return false, nil
+	}
+	cur := tiles.ToRGBA(img)
+	c.mu.Lock()
+	pf, enc, copyRect, desktopSize := c.pf, c.enc, c.copyRect, c.desktopSize
+	c.mu.Unlock()
+
+	var rects []update
+	if cur.Bounds() != c.fb.Bounds() {
+		if desktopSize {
+			rects = append(rects, update{rect: cur.Bounds(), encoding: encodingDesktopSize})
+			c.fb = image.NewRGBA(cur.Bounds())
+			c.locate()
+			req = updateRequest{rect: cur.Bounds()}
+		} else {
+			cur = fit(cur, c.fb.Bounds())
+		}
+	}
+	area := req.rect.Intersect(c.fb.Bounds())
+	switch {
+	case !req.incremental:
+		if !area.Empty() {
+			rects = append(rects, update{rect: area, encoding: enc})
+		}
+	default:
+		if copyRect && area == c.fb.Bounds() {
+			if dst, src, ok := scrolled(c.fb, cur); ok {
+				rects = append(rects, update{rect: dst, encoding: encodingCopyRect, src: src})
+				copyArea(c.fb, dst, src)
+			}
+		}
+		for _, r := range tiles.Changed(c.fb, cur, tileSize) {
+			if r = r.Intersect(area); !r.Empty() {
+				rects = append(rects, update{rect: r, encoding: enc})
+			}
+		}
+		if len(rects) == 0 {
+			return false, nil
+		}
+	}
+
+	c.w.Write([]byte{0, 0})
+	binary.Write(c.w, binary.BigEndian, uint16(len(rects)))
+	for _, u := range rects {
+		r := u.rect
+		binary.Write(c.w, binary.BigEndian, []uint16{uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy())})
+		binary.Write(c.w, binary.BigEndian, u.encoding)
+		switch u.encoding {
+		case encodingCopyRect:
+			binary.Write(c.w, binary.BigEndian, []uint16{uint16(u.src.X), uint16(u.src.Y)})
+		case encodingRaw:
+			c.w.Write(encodeRaw(cur, r, pf))
+		case encodingZRLE:
+			data, err := c.zrle.encode(cur, r, pf)
+			if err != nil {
+				return false, err
+			}
+			binary.Write(c.w, binary.BigEndian, uint32(len(data)))
+			c.w.Write(data)
+		}
+		if u.encoding == encodingRaw || u.encoding == encodingZRLE {
+			copyFrom(c.fb, cur, r)
+		}
+	}
+	return true, c.w.Flush()
+}
+
+// update is a rectangle of a framebuffer update.
+type update struct {
+	rect     image.Rectangle
+	encoding int32
+	src      image.Point // of CopyRect
+}
@@ -0,0 +1,401 @@
+package vnc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhmdibrahimm/goautogui/server"
+)
+
+// rfbClient is a minimal RFB 3.8 client that decodes Raw, CopyRect and ZRLE updates in the
+// default pixel format, 32-bit little-endian 0x00RRGGBB.
+type rfbClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	fb   *image.RGBA
+	zbuf bytes.Buffer
+	zr   io.Reader
+}
+
+func dialRFB(addr, password string) (*rfbClient, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &rfbClient{conn: conn, r: bufio.NewReader(conn)}
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(c.r, version); err != nil || string(version) != "RFB 003.008\n" {
+		conn.Close()
+		return nil, fmt.Errorf("version %q, %v", version, err)
+	}
+	conn.Write(version)
+	types := make([]byte, 2)
+	if _, err := io.ReadFull(c.r, types); err != nil || types[0] != 1 || types[1] != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("security types %v, %v; want VNC Authentication", types, err)
+	}
+	conn.Write([]byte{2})
+	challenge := make([]byte, 16)
+	io.ReadFull(c.r, challenge)
+	key := make([]byte, 8)
+	copy(key, password)
+	for i := range key {
+		key[i] = bits.Reverse8(key[i])
+	}
+	block, _ := des.NewCipher(key)
+	block.Encrypt(challenge[:8], challenge[:8])
+	block.Encrypt(challenge[8:], challenge[8:])
+	conn.Write(challenge)
+	var result uint32
+	if err := binary.Read(c.r, binary.BigEndian, &result); err != nil || result != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("security result %d, %v", result, err)
+	}
+	conn.Write([]byte{1})
+	var init struct {
+		Width, Height uint16
+		Format        [16]byte
+		NameLength    uint32
+	}
+	if err := binary.Read(c.r, binary.BigEndian, &init); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.r.Discard(int(init.NameLength))
+	c.fb = image.NewRGBA(image.Rect(0, 0, int(init.Width), int(init.Height)))
+	conn.Write([]byte{2, 0, 0, 3, 0, 0, 0, 16, 0, 0, 0, 1, 0, 0, 0, 0}) // ZRLE, CopyRect, Raw
+	return c, nil
+}
+
+// update requests an update of the whole framebuffer and applies it, returning the
+// encodings of its rectangles.
+func (c *rfbClient) update(incremental bool) ([]int32, error) {
+	req := []byte{3, 0, 0, 0, 0, 0}
+	if incremental {
+		req[1] = 1
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(c.fb.Rect.Dx()))
+	req = binary.BigEndian.AppendUint16(req, uint16(c.fb.Rect.Dy()))
+	c.conn.Write(req)
+	var head struct {
+		Type, Padding uint8
+		Rects         uint16
+	}
+	if err := binary.Read(c.r, binary.BigEndian, &head); err != nil {
+		return nil, err
+	}
+	var encodings []int32
+	for range head.Rects {
+		var rect struct {
+			X, Y, W, H uint16
+			Encoding   int32
+		}
+		if err := binary.Read(c.r, binary.BigEndian, &rect); err != nil {
+			return nil, err
+		}
+		encodings = append(encodings, rect.Encoding)
+		r := image.Rect(int(rect.X), int(rect.Y), int(rect.X+rect.W), int(rect.Y+rect.H))
+		var err error
+		switch rect.Encoding {
+		case 0:
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					c.fb.SetRGBA(x, y, readRFBPixel(c.r, 4))
+				}
+			}
+		case 1:
+			var src [2]uint16
+			err = binary.Read(c.r, binary.BigEndian, &src)
+			draw.Draw(c.fb, r, c.fb, image.Pt(int(src[0]), int(src[1])), draw.Src)
+		case 16:
+			err = c.zrle(r)
+		default:
+			err = fmt.Errorf("unexpected encoding %d", rect.Encoding)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return encodings, nil
+}
+
+func readRFBPixel(r io.Reader, size int) color.RGBA {
+	b := make([]byte, size)
+	io.ReadFull(r, b)
+	return color.RGBA{b[2], b[1], b[0], 0xFF}
+}
+
+func (c *rfbClient) zrle(r image.Rectangle) error {
+	var n uint32
+	binary.Read(c.r, binary.BigEndian, &n)
+	if _, err := io.CopyN(&c.zbuf, c.r, int64(n)); err != nil {
+		return err
+	}
+	if c.zr == nil {
+		var err error
+		if c.zr, err = zlib.NewReader(&c.zbuf); err != nil {
+			return err
+		}
+	}
+	readByte := func() int {
+		var b [1]byte
+		io.ReadFull(c.zr, b[:])
+		return int(b[0])
+	}
+	runLength := func() int {
+		n := 1
+		for {
+			b := readByte()
+			if n += b; b != 255 {
+				return n
+			}
+		}
+	}
+	for ty := r.Min.Y; ty < r.Max.Y; ty += 64 {
+		for tx := r.Min.X; tx < r.Max.X; tx += 64 {
+			t := image.Rect(tx, ty, tx+64, ty+64).Intersect(r)
+			size := t.Dx() * t.Dy()
+			sub := readByte()
+			var palette, px []color.RGBA
+			if sub >= 2 && sub <= 16 || sub >= 130 {
+				for range sub % 128 {
+					palette = append(palette, readRFBPixel(c.zr, 3))
+				}
+			}
+			switch {
+			case sub == 0:
+				for range size {
+					px = append(px, readRFBPixel(c.zr, 3))
+				}
+			case sub == 1:
+				px = slices.Repeat([]color.RGBA{readRFBPixel(c.zr, 3)}, size)
+			case sub <= 16:
+				depth := 4
+				if sub == 2 {
+					depth = 1
+				} else if sub <= 4 {
+					depth = 2
+				}
+				row := make([]byte, (t.Dx()*depth+7)/8)
+				for range t.Dy() {
+					io.ReadFull(c.zr, row)
+					for x := range t.Dx() {
+						px = append(px, palette[row[x*depth/8]>>(8-depth-x*depth%8)&(1<<depth-1)])
+					}
+				}
+			case sub == 128:
+				for len(px) < size {
+					p := readRFBPixel(c.zr, 3)
+					px = append(px, slices.Repeat([]color.RGBA{p}, runLength())...)
+				}
+			case sub >= 130:
+				for len(px) < size {
+					if i := readByte(); i < 128 {
+						px = append(px, palette[i])
+					} else {
+						px = append(px, slices.Repeat([]color.RGBA{palette[i-128]}, runLength())...)
+					}
+				}
+			default:
+				return fmt.Errorf("ZRLE tile %v has subencoding %d", t, sub)
+			}
+			if len(px) != size {
+				return fmt.Errorf("ZRLE tile %v with subencoding %d has %d pixels, want %d", t, sub, len(px), size)
+			}
+			for i, p := range px {
+				c.fb.SetRGBA(t.Min.X+i%t.Dx(), t.Min.Y+i/t.Dx(), p)
+			}
+		}
+	}
+	return nil
+}
+
+// serve serves fake with the password "secret" and opts until the test ends, returning
+// the address to dial.
+func serve(t *testing.T, fake *server.FakeBackend, opts ...Option) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- New(fake, append([]Option{WithPassword("secret"), WithFPS(50)}, opts...)...).Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+	})
+	return l.Addr().String()
+}
+
+// waitCalls waits up to 2 seconds for fake to have n calls and returns its calls.
+func waitCalls(fake *server.FakeBackend, n int) []string {
+	for deadline := time.Now().Add(2 * time.Second); len(fake.CallLog()) < n && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fake.CallLog()
+}
+
+func TestServe(t *testing.T) {
+	fake := server.NewFakeBackend(300, 200)
+	// Rows of different shades, so that a scroll can be recognised, and stripes of a few
+	// colours, so that ZRLE uses several subencodings
+	for y := range 200 {
+		for x := range 300 {
+			c := color.RGBA{uint8(y), uint8(y * 7), uint8(x / 40 * 30), 0xFF}
+			if x >= 250 {
+				c = color.RGBA{uint8(x % 3 * 100), 0, 0, 0xFF}
+			}
+			fake.Screen.SetRGBA(x, y, c)
+		}
+	}
+	addr := serve(t, fake)
+
+	// A wrong password is refused
+	if c, err := dialRFB(addr, "wrong"); err == nil {
+		c.conn.Close()
+		t.Fatal("logged in with a wrong password")
+	}
+	c, err := dialRFB(addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.conn.Close()
+
+	// The first update is the whole screen, then only what changed
+	if _, err := c.update(false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.fb.Pix, fake.Screen.Pix) {
+		t.Fatal("the framebuffer differs from the screen after a full update")
+	}
+	draw.Draw(fake.Screen, image.Rect(100, 100, 110, 105), image.NewUniform(color.RGBA{0xFF, 0xFF, 0, 0xFF}), image.Point{}, draw.Src)
+	encodings, err := c.update(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(encodings, []int32{16}) || !bytes.Equal(c.fb.Pix, fake.Screen.Pix) {
+		t.Fatalf("incremental update with encodings %v; want the changed tile with ZRLE", encodings)
+	}
+
+	// A scroll is sent as a CopyRect
+	screen := fake.Screen
+	scrolled := image.NewRGBA(screen.Bounds())
+	draw.Draw(scrolled, screen.Bounds(), image.Black, image.Point{}, draw.Src)
+	draw.Draw(scrolled, screen.Bounds(), screen, image.Pt(0, 40), draw.Src)
+	fake.Screen = scrolled
+	encodings, err = c.update(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encodings) == 0 || encodings[0] != 1 || !bytes.Equal(c.fb.Pix, fake.Screen.Pix) {
+		t.Fatalf("scroll update with encodings %v; want a CopyRect first", encodings)
+	}
+
+	// Pointer and key events are injected; held keys are released on disconnect
+	c.conn.Write([]byte{5, 1, 0, 10, 0, 20}) // left button down at 10,20
+	c.conn.Write([]byte{5, 0, 0, 10, 0, 20})
+	c.conn.Write([]byte{5, 8, 0, 10, 0, 20}) // wheel up
+	c.conn.Write([]byte{5, 0, 0, 10, 0, 20})
+	c.conn.Write([]byte{4, 1, 0, 0, 0, 0, 0xFF, 0xE1}) // Shift_L down
+	c.conn.Write([]byte{4, 1, 0, 0, 0, 0, 0, 'A'})
+	c.conn.Write([]byte{4, 0, 0, 0, 0, 0, 0, 'A'})
+	want := []string{
+		"rawmove 10,20", "rawbutton 10,20 left down", "rawbutton 10,20 left up",
+		"rawwheel 1 at 10,20 horizontal=false", "rawkey shiftleft down", "rawkey a down", "rawkey a up",
+	}
+	if got := waitCalls(fake, len(want)); !slices.Equal(got, want) {
+		t.Fatalf("injected %q, want %q", got, want)
+	}
+	c.conn.Close()
+	want = append(want, "rawkey shiftleft up")
+	if got := waitCalls(fake, len(want)); !slices.Equal(got, want) {
+		t.Fatalf("after disconnecting %q, want %q", got, want)
+	}
+}
+
+// logLines is an io.Writer that sends each log line to the channel.
+type logLines chan string
+
+func (l logLines) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+func TestInputErrors(t *testing.T) {
+	fake := server.NewFakeBackend(40, 30)
+	fake.Errors["rawkey"] = errors.New("fail-safe triggered")
+	logs := make(logLines, 10)
+	addr := serve(t, fake, WithErrorLog(log.New(logs, "", 0)))
+	c, err := dialRFB(addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.conn.Close()
+
+	// Both presses fail, which is logged once, so neither key is released on disconnect,
+	// only the button that was pressed
+	c.conn.Write([]byte{4, 1, 0, 0, 0, 0, 0xFF, 0xE1}) // Shift_L down
+	c.conn.Write([]byte{4, 1, 0, 0, 0, 0, 0, 'a'})
+	c.conn.Write([]byte{5, 1, 0, 5, 0, 6}) // left button down at 5,6
+	waitCalls(fake, 4)
+	c.conn.Close()
+	got := waitCalls(fake, 5)
+	want := []string{"rawkey shiftleft down", "rawkey a down", "rawmove 5,6", "rawbutton 5,6 left down", "rawbutton 5,6 left up"}
+	if !slices.Equal(got, want) {
+		t.Errorf("calls %q, want %q", got, want)
+	}
+	select {
+	case line := <-logs:
+		if !strings.Contains(line, "fail-safe triggered") {
+			t.Errorf("logged %q, want the key failure", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the key failure was not logged")
+	}
+	select {
+	case line := <-logs:
+		t.Errorf("logged %q, want the same failure to be logged once", line)
+	default:
+	}
+}
+
+func TestWithFPS(t *testing.T) {
+	tests := []struct {
+		fps  float64
+		want time.Duration
+	}{
+		{20, 50 * time.Millisecond},
+		{0.5, 2 * time.Second},
+		{0, 100 * time.Millisecond},
+		{-5, 100 * time.Millisecond},
+		{math.NaN(), 100 * time.Millisecond},
+		{math.Inf(1), 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := New(server.NewFakeBackend(1, 1), WithFPS(tt.fps)).interval; got != tt.want {
+			t.Errorf("WithFPS(%v) interval %v, want %v", tt.fps, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+	"unsafe"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// Capturer captures the screen repeatedly without per-call allocations. It keeps the screen
+// DC, a memory DC, a DIB section and a destination image alive between calls, only growing
+// the DIB section when a larger area is requested. Use it instead of Capture for polling
+// loops. A Capturer is safe for concurrent use; calls are serialized.
+type Capturer struct {
+	mu     sync.Mutex
+	hdc    win32.HDC // screen DC
+	memDC  win32.HDC
+	bmp    win32.HBITMAP // DIB section selected into memDC
+	oldObj win32.HGDIOBJ // object memDC held before bmp was selected
+	bits   unsafe.Pointer
+	width  int // size of the DIB section
+	height int
+	img    *image.RGBA // reused by Capture
+}
+
+// NewCapturer creates a Capturer. Call Close to release its GDI resources.
+func NewCapturer() (*Capturer, error) {
+	hdc := win32.GetDC(0)
+	if hdc == 0 {
+		return nil, errors.New("GetDC failed")
+	}
+	memDC := win32.CreateCompatibleDC(hdc)
+	if memDC == 0 {
+		win32.ReleaseDC(0, hdc)
+		return nil, errors.New("CreateCompatibleDC failed")
+	}
+	return &Capturer{hdc: hdc, memDC: memDC}, nil
+}
+
+// Close releases the GDI resources held by the Capturer.
+func (c *Capturer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.memDC == 0 {
+		return nil
+	}
+	c.releaseBitmap()
+	win32.DeleteDC(c.memDC)
+	win32.ReleaseDC(0, c.hdc)
+	c.memDC, c.hdc = 0, 0
+	c.img = nil
+	return nil
+}
+
+func (c *Capturer) releaseBitmap() {
+	if c.bmp == 0 {
+		return
+	}
+	win32.SelectObject(c.memDC, c.oldObj)
+	win32.DeleteObject(win32.HGDIOBJ(c.bmp))
+	c.bmp, c.bits, c.width, c.height = 0, nil, 0, 0
+}
+
+// ensureBitmap makes sure the DIB section is at least width x height.
+func (c *Capturer) ensureBitmap(width, height int) error {
+	if c.bmp != 0 && width <= c.width && height <= c.height {
+		return nil
+	}
+	width, height = max(width, c.width), max(height, c.height)
+	c.releaseBitmap()
+
+	var bmi win32.BITMAPINFO
+	bmi.BmiHeader = win32.BITMAPINFOHEADER{
+		BiSize:        uint32(unsafe.Sizeof(win32.BITMAPINFOHEADER{})),
+		BiWidth:       int32(width),
+		BiHeight:      -int32(height), // top-down
+		BiPlanes:      1,
+		BiBitCount:    32,
+		BiCompression: win32.BI_RGB,
+	}
+	var bits unsafe.Pointer
+	bmp, _ := win32.CreateDIBSection(c.hdc, &bmi, win32.DIB_RGB_COLORS, unsafe.Pointer(&bits), 0, 0)
+	if bmp == 0 || bits == nil {
+		return errors.New("CreateDIBSection failed")
+	}
+	c.oldObj = win32.SelectObject(c.memDC, win32.HGDIOBJ(bmp))
+	c.bmp, c.bits, c.width, c.height = bmp, bits, width, height
+	return nil
+}
+
+// Capture captures the specified area (x, y, width, height), validating it as the Capture
+// function does: it fails if part of the area is off-screen unless WithClip is passed. The
+// returned image is owned by the Capturer and is overwritten by the next call to Capture;
+// copy it if it must be kept.
+func (c *Capturer) Capture(x, y, width, height int, opts ...CaptureOption) (*image.RGBA, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := newCaptureConfig(opts)
+	if width <= 0 || height <= 0 {
+		return nil, ErrEmptyRegion
+	}
+	region := image.Rect(x, y, x+width, y+height)
+	area, err := validateRegion(region, cfg.clip)
+	if err != nil {
+		return nil, err
+	}
+	bounds := area.Sub(region.Min)
+	width, height = bounds.Dx(), bounds.Dy()
+	if c.img == nil || cap(c.img.Pix) < width*height*4 {
+		img, err := createImage(bounds)
+		if err != nil {
+			return nil, err
+		}
+		c.img = img
+	} else {
+		// Reuse the pixel buffer for the new size
+		c.img.Pix = c.img.Pix[:width*height*4]
+		c.img.Stride = width * 4
+		c.img.Rect = bounds
+	}
+	if err := c.captureInto(c.img, area.Min.X, area.Min.Y, cfg); err != nil {
+		return nil, err
+	}
+	return c.img, nil
+}
+
+// CaptureRect is like Capture but takes the area as a rectangle.
+func (c *Capturer) CaptureRect(rect image.Rectangle, opts ...CaptureOption) (*image.RGBA, error) {
+	return c.Capture(rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), opts...)
+}
+
+// CaptureInto captures the area of the screen with its top-left corner at (x, y) and the
+// size of dst's bounds into dst, without allocating. The area is validated as in Capture;
+// with WithClip only its visible part is written and the rest of dst is left untouched.
+func (c *Capturer) CaptureInto(dst *image.RGBA, x, y int, opts ...CaptureOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := newCaptureConfig(opts)
+	region := image.Rect(x, y, x+dst.Rect.Dx(), y+dst.Rect.Dy())
+	area, err := validateRegion(region, cfg.clip)
+	if err != nil {
+		return err
+	}
+	if area != region {
+		dst = dst.SubImage(area.Sub(region.Min).Add(dst.Rect.Min)).(*image.RGBA)
+	}
+	return c.captureInto(dst, area.Min.X, area.Min.Y, cfg)
+}
+
+// captureInto copies the screen area with its top-left corner at (x, y) and the size of
+// dst's bounds into dst, which must already have been validated.
+func (c *Capturer) captureInto(dst *image.RGBA, x, y int, cfg captureConfig) error {
+	width, height := dst.Rect.Dx(), dst.Rect.Dy()
+	if err := c.blit(x, y, width, height, cfg.cursor); err != nil {
+		return err
+	}
+	c.read(dst)
+	return nil
+}
+
+// blit copies the screen area (x, y, width, height) into the DIB section.
+func (c *Capturer) blit(x, y, width, height int, cursor bool) error {
+	if c.memDC == 0 {
+		return errors.New("capturer is closed")
+	}
+	if width <= 0 || height <= 0 {
+		return ErrEmptyRegion
+	}
+	if err := c.ensureBitmap(width, height); err != nil {
+		return err
+	}
+
+	if ok, _ := win32.BitBlt(c.memDC, 0, 0, int32(width), int32(height), c.hdc, int32(x), int32(y), win32.SRCCOPY); ok == 0 {
+		code := win32.GetLastError()
+		return fmt.Errorf("BitBlt failed, GetLastError=%d", code)
+	}
+	if cursor {
+		drawCursor(c.memDC, x, y)
+	}
+	// Make sure GDI has finished drawing into the DIB section before reading it
+	win32.GdiFlush()
+	return nil
+}
+
+// read copies the top-left corner of the DIB section, the size of dst's bounds, into dst.
+func (c *Capturer) read(dst *image.RGBA) {
+	width, height := dst.Rect.Dx(), dst.Rect.Dy()
+	srcStride := c.width * 4
+	src := unsafe.Slice((*byte)(c.bits), srcStride*c.height)
+	for row := 0; row < height; row++ {
+		s := src[row*srcStride : row*srcStride+width*4]
+		d := dst.Pix[row*dst.Stride : row*dst.Stride+width*4]
+		swizzleBGRA(d, s)
+	}
+}
+
+// swizzleBGRA converts a row of BGRX pixels from a DIB into opaque RGBA pixels, one 32-bit
+// word at a time. dst and src must have the same length, a multiple of 4.
+func swizzleBGRA(dst, src []byte) {
+	n := len(src) / 4
+	if n == 0 {
+		return
+	}
+	s := unsafe.Slice((*uint32)(unsafe.Pointer(&src[0])), n)
+	d := unsafe.Slice((*uint32)(unsafe.Pointer(&dst[0])), n)
+	for i, v := range s {
+		// little-endian word: B | G<<8 | R<<16 | X<<24  →  R | G<<8 | B<<16 | 0xFF<<24
+		d[i] = v&0x0000FF00 | (v>>16)&0xFF | (v&0xFF)<<16 | 0xFF000000
+	}
+}
@@ -0,0 +1,81 @@
+//go:build windows
+
+package windows
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+// benchCapturer returns a Capturer whose DIB section already holds a size x size capture of
+// the top-left corner of the primary display.
+func benchCapturer(b *testing.B, size int) *Capturer {
+	b.Helper()
+	c, err := NewCapturer()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { c.Close() })
+	if err := c.blit(0, 0, size, size, false); err != nil {
+		b.Fatal(err)
+	}
+	return c
+}
+
+const benchSize = 512
+
+func BenchmarkCapturerBitBlt(b *testing.B) {
+	c := benchCapturer(b, benchSize)
+	b.SetBytes(benchSize * benchSize * 4)
+	b.ResetTimer()
+	for range b.N {
+		if err := c.blit(0, 0, benchSize, benchSize, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCapturerDIBCopy(b *testing.B) {
+	c := benchCapturer(b, benchSize)
+	dst := image.NewRGBA(image.Rect(0, 0, benchSize, benchSize))
+	b.SetBytes(benchSize * benchSize * 4)
+	b.ResetTimer()
+	for range b.N {
+		c.read(dst)
+	}
+}
+
+func BenchmarkSwizzleBGRA(b *testing.B) {
+	src := make([]byte, benchSize*benchSize*4)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	dst := make([]byte, len(src))
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for range b.N {
+		swizzleBGRA(dst, src)
+	}
+}
+
+func BenchmarkCapturerCapture(b *testing.B) {
+	c := benchCapturer(b, benchSize)
+	b.SetBytes(benchSize * benchSize * 4)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := c.Capture(0, 0, benchSize, benchSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSwizzleBGRA(t *testing.T) {
+	src := []byte{0x10, 0x20, 0x30, 0x00, 0xAA, 0xBB, 0xCC, 0x7F}
+	want := []byte{0x30, 0x20, 0x10, 0xFF, 0xCC, 0xBB, 0xAA, 0xFF}
+	dst := make([]byte, len(src))
+	swizzleBGRA(dst, src)
+	if !bytes.Equal(dst, want) {
+		t.Errorf("swizzleBGRA = % x, want % x", dst, want)
+	}
+}
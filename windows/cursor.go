@@ -0,0 +1,110 @@
+//go:build windows
+
+package windows
+
+import (
+	"image"
+	"image/color"
+	"unsafe"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// CaptureOption configures a screen capture.
+type CaptureOption func(*captureConfig)
+
+type captureConfig struct {
+	cursor     bool // composite the mouse cursor onto the capture
+	clientArea bool // CaptureWindow: client area only
+	noShadow   bool // CaptureWindow: exclude the invisible borders and DWM shadow
+	clip       bool // clip the region to the monitors instead of failing
+}
+
+func newCaptureConfig(opts []CaptureOption) captureConfig {
+	var c captureConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithCursor composites the current mouse cursor onto the capture, at its position adjusted
+// for the cursor's hotspot. BitBlt never includes the cursor, so without this option it is
+// missing from screenshots.
+func WithCursor() CaptureOption {
+	return func(c *captureConfig) {
+		c.cursor = true
+	}
+}
+
+// drawCursor draws the current cursor into hdc, which holds a capture whose top-left corner
+// is at (originX, originY) on the screen. It does nothing if the cursor is hidden.
+func drawCursor(hdc win32.HDC, originX, originY int) {
+	ci := win32.CURSORINFO{CbSize: uint32(unsafe.Sizeof(win32.CURSORINFO{}))}
+	if ok, _ := win32.GetCursorInfo(&ci); ok == 0 || ci.Flags&win32.CURSOR_SHOWING == 0 || ci.HCursor == 0 {
+		return
+	}
+
+	// The cursor position is the hotspot; the image starts at the hotspot offset before it
+	var ii win32.ICONINFO
+	if ok, _ := win32.GetIconInfo(win32.HICON(ci.HCursor), &ii); ok == 0 {
+		return
+	}
+	if ii.HbmMask != 0 {
+		defer win32.DeleteObject(win32.HGDIOBJ(ii.HbmMask))
+	}
+	if ii.HbmColor != 0 {
+		defer win32.DeleteObject(win32.HGDIOBJ(ii.HbmColor))
+	}
+
+	x := int32(ci.PtScreenPos.X) - int32(ii.XHotspot) - int32(originX)
+	y := int32(ci.PtScreenPos.Y) - int32(ii.YHotspot) - int32(originY)
+	win32.DrawIconEx(hdc, x, y, win32.HICON(ci.HCursor), 0, 0, 0, 0, win32.DI_NORMAL)
+}
+
+// MarkerShape selects the shape drawn by DrawMarker.
+type MarkerShape int
+
+const (
+	MarkerCrosshair MarkerShape = iota // MarkerCrosshair is a plus sign centred on the point
+	MarkerCircle                       // MarkerCircle is a ring centred on the point
+)
+
+// DrawMarker draws a marker of the given shape and size (arm length or radius in pixels)
+// centred on p, in image coordinates, e.g. to annotate where a click landed. The marker is
+// outlined in white so it stays visible on any background. Parts outside img are clipped.
+// To mark a screen point on a capture of rect, pass the point minus rect.Min.
+func DrawMarker(img *image.RGBA, p image.Point, shape MarkerShape, size int, c color.RGBA) {
+	outline := color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	switch shape {
+	case MarkerCircle:
+		// Plot every pixel whose distance from p rounds to the ring, outline first
+		for _, pass := range []struct {
+			c         color.RGBA
+			thickness int
+		}{{outline, 2}, {c, 1}} {
+			inner := (size - pass.thickness) * (size - pass.thickness)
+			outer := (size + pass.thickness) * (size + pass.thickness)
+			r := size + pass.thickness
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					if d := dx*dx + dy*dy; d >= inner && d <= outer {
+						img.SetRGBA(p.X+dx, p.Y+dy, pass.c)
+					}
+				}
+			}
+		}
+	default:
+		for _, pass := range []struct {
+			c     color.RGBA
+			width int
+		}{{outline, 1}, {c, 0}} {
+			for d := -size; d <= size; d++ {
+				for w := -pass.width; w <= pass.width; w++ {
+					img.SetRGBA(p.X+d, p.Y+w, pass.c)
+					img.SetRGBA(p.X+w, p.Y+d, pass.c)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,268 @@
+//go:build windows
+
+package windows
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// DiffOption configures Diff.
+type DiffOption func(*diffConfig)
+
+type diffConfig struct {
+	tolerance uint8
+	ignore    []image.Rectangle
+	merge     int
+}
+
+// WithTolerance sets how much each colour channel may differ before a pixel counts as
+// changed, to absorb anti-aliasing and compression noise. The default is 0.
+func WithTolerance(tolerance uint8) DiffOption {
+	return func(c *diffConfig) {
+		c.tolerance = tolerance
+	}
+}
+
+// WithIgnore excludes areas from the comparison, e.g. a clock or the cursor. Rectangles
+// are in the coordinates of the first image passed to Diff.
+func WithIgnore(rects ...image.Rectangle) DiffOption {
+	return func(c *diffConfig) {
+		c.ignore = append(c.ignore, rects...)
+	}
+}
+
+// WithMergeDistance sets how close, in pixels, changed pixels must be to be reported in
+// the same region. The default is 4; 0 reports each connected group of changed pixels.
+func WithMergeDistance(pixels int) DiffOption {
+	return func(c *diffConfig) {
+		c.merge = max(0, pixels)
+	}
+}
+
+// DiffResult describes the differences between two images.
+type DiffResult struct {
+	Regions []image.Rectangle // bounding boxes of the changed areas, in the first image's coordinates
+	Changed int               // number of changed pixels
+	Total   int               // number of compared pixels, excluding ignored areas
+	Percent float64           // Changed as a percentage of Total
+	Image   *image.RGBA       // the first image faded, with changed pixels in red and regions outlined
+}
+
+// Equal reports whether no pixels changed.
+func (r *DiffResult) Equal() bool {
+	return r.Changed == 0
+}
+
+// String method for better printing
+func (r *DiffResult) String() string {
+	return fmt.Sprintf("%d of %d pixels changed (%.3f%%) in %d regions", r.Changed, r.Total, r.Percent, len(r.Regions))
+}
+
+// Diff compares a and b pixel by pixel. The images must be the same size; they are compared
+// relative to their Bounds().Min, so a capture of a region can be compared with a decoded
+// PNG of it. A pixel is changed if any channel differs by more than the tolerance.
+func Diff(a, b image.Image, opts ...DiffOption) (*DiffResult, error) {
+	cfg := diffConfig{merge: 4}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return nil, fmt.Errorf("image sizes differ: %dx%d and %dx%d", ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	}
+	ra, rb := toRGBA(a), toRGBA(b)
+	w, h := ab.Dx(), ab.Dy()
+
+	ignored := make([]bool, w*h)
+	for _, r := range cfg.ignore {
+		r = r.Intersect(ab).Sub(ab.Min)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				ignored[y*w+x] = true
+			}
+		}
+	}
+
+	res := &DiffResult{}
+	changed := make([]bool, w*h)
+	tol := int(cfg.tolerance)
+	for y := 0; y < h; y++ {
+		pa := ra.Pix[y*ra.Stride : y*ra.Stride+w*4]
+		pb := rb.Pix[y*rb.Stride : y*rb.Stride+w*4]
+		for x := 0; x < w; x++ {
+			if ignored[y*w+x] {
+				continue
+			}
+			res.Total++
+			i := x * 4
+			if absDiff(pa[i], pb[i]) > tol || absDiff(pa[i+1], pb[i+1]) > tol ||
+				absDiff(pa[i+2], pb[i+2]) > tol || absDiff(pa[i+3], pb[i+3]) > tol {
+				changed[y*w+x] = true
+				res.Changed++
+			}
+		}
+	}
+	if res.Total > 0 {
+		res.Percent = 100 * float64(res.Changed) / float64(res.Total)
+	}
+
+	if res.Changed > 0 {
+		// Group changed pixels that are within the merge distance of each other
+		groups, labels := components(dilate(changed, w, h, cfg.merge), w, h)
+		for n, c := range groups {
+			// Shrink the box back to the changed pixels, dropping the dilation margin
+			box := image.Rectangle{}
+			for y := c.Bounds.Min.Y; y < c.Bounds.Max.Y; y++ {
+				for x := c.Bounds.Min.X; x < c.Bounds.Max.X; x++ {
+					if changed[y*w+x] && labels[y*w+x] == n+1 {
+						box = box.Union(image.Rect(x, y, x+1, y+1))
+					}
+				}
+			}
+			if !box.Empty() {
+				res.Regions = append(res.Regions, box.Add(ab.Min))
+			}
+		}
+	}
+
+	res.Image = diffImage(ra, changed, ignored, res.Regions)
+	return res, nil
+}
+
+// diffImage renders the highlighted diff image over a: unchanged pixels are faded towards
+// white, ignored areas tinted blue, changed pixels red and each region outlined in magenta.
+func diffImage(a *image.RGBA, changed, ignored []bool, regions []image.Rectangle) *image.RGBA {
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	out := image.NewRGBA(a.Rect)
+	for y := 0; y < h; y++ {
+		src := a.Pix[y*a.Stride:]
+		dst := out.Pix[y*out.Stride:]
+		for x := 0; x < w; x++ {
+			i := x * 4
+			switch {
+			case changed[y*w+x]:
+				copy(dst[i:i+4], []byte{0xFF, 0, 0, 0xFF})
+			case ignored[y*w+x]:
+				copy(dst[i:i+4], []byte{src[i] / 4, src[i+1] / 4, 0x80 + src[i+2]/2, 0xFF})
+			default:
+				// Keep a quarter of the original so the changes stand out but stay in context
+				for c := 0; c < 3; c++ {
+					dst[i+c] = 0xC0 + src[i+c]/4
+				}
+				dst[i+3] = 0xFF
+			}
+		}
+	}
+
+	outline := color.RGBA{R: 0xFF, B: 0xFF, A: 0xFF}
+	for _, r := range regions {
+		r = r.Inset(-1)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			out.SetRGBA(x, r.Min.Y, outline)
+			out.SetRGBA(x, r.Max.Y-1, outline)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			out.SetRGBA(r.Min.X, y, outline)
+			out.SetRGBA(r.Max.X-1, y, outline)
+		}
+	}
+	return out
+}
+
+// toRGBA returns img as an *image.RGBA, converting it if it is of another type.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Rect, img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// dilate grows the set pixels of a w x h mask by d pixels in every direction. It applies a
+// square max filter as a horizontal then a vertical pass, each counting set pixels in the
+// window with a running prefix sum.
+func dilate(mask []bool, w, h, d int) []bool {
+	if d <= 0 {
+		return mask
+	}
+	pass := func(src []bool, n, lines int, at func(line, i int) int) []bool {
+		dst := make([]bool, len(src))
+		prefix := make([]int, n+1)
+		for line := 0; line < lines; line++ {
+			for i := 0; i < n; i++ {
+				prefix[i+1] = prefix[i]
+				if src[at(line, i)] {
+					prefix[i+1]++
+				}
+			}
+			for i := 0; i < n; i++ {
+				if prefix[min(n, i+d+1)]-prefix[max(0, i-d)] > 0 {
+					dst[at(line, i)] = true
+				}
+			}
+		}
+		return dst
+	}
+	rows := pass(mask, w, h, func(y, x int) int { return y*w + x })
+	return pass(rows, h, w, func(x, y int) int { return y*w + x })
+}
+
+// component is a group of connected set pixels in a mask.
+type component struct {
+	Bounds   image.Rectangle
+	Area     int
+	Centroid image.Point
+}
+
+// components labels the 8-connected groups of set pixels in a w x h mask, in the order
+// their first pixel appears scanning row by row. labels holds, for each pixel, the index
+// of its group plus one, or 0 for unset pixels.
+func components(mask []bool, w, h int) (groups []component, labels []int) {
+	labels = make([]int, len(mask))
+	var stack []int
+	for start, set := range mask {
+		if !set || labels[start] != 0 {
+			continue
+		}
+		c := component{}
+		var sumX, sumY int
+		label := len(groups) + 1
+		labels[start] = label
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%w, i/w
+			c.Area++
+			sumX += x
+			sumY += y
+			c.Bounds = c.Bounds.Union(image.Rect(x, y, x+1, y+1))
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					if j := ny*w + nx; mask[j] && labels[j] == 0 {
+						labels[j] = label
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+		c.Centroid = image.Pt((sumX+c.Area/2)/c.Area, (sumY+c.Area/2)/c.Area)
+		groups = append(groups, c)
+	}
+	return groups, labels
+}
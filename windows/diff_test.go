@@ -0,0 +1,102 @@
+//go:build windows
+
+package windows
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"testing"
+)
+
+var grey = color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+
+// greyImage returns a 40x20 grey image with bounds at origin and each rect filled with c.
+func greyImage(origin image.Point, c color.RGBA, rects ...image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20).Add(origin))
+	draw.Draw(img, img.Rect, image.NewUniform(grey), image.Point{}, draw.Src)
+	for _, r := range rects {
+		draw.Draw(img, r.Add(origin), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func TestDiff(t *testing.T) {
+	slightly := color.RGBA{R: 0x85, G: 0x80, B: 0x80, A: 0xFF}
+	white := color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	spot := image.Rect(4, 4, 6, 8)     // 8 pixels
+	beside := image.Rect(9, 4, 10, 5)  // 3 pixels right of spot
+	far := image.Rect(30, 10, 32, 12)  // 4 pixels
+	clock := image.Rect(28, 8, 40, 20) // covers far
+
+	tests := []struct {
+		name        string
+		b           *image.RGBA
+		opts        []DiffOption
+		wantRegions []image.Rectangle
+		wantChanged int
+		wantTotal   int
+		wantPercent float64
+	}{
+		{"equal", greyImage(image.Point{}, white), nil, nil, 0, 800, 0},
+		{"within tolerance", greyImage(image.Point{}, slightly, spot), []DiffOption{WithTolerance(5)}, nil, 0, 800, 0},
+		{"beyond tolerance", greyImage(image.Point{}, slightly, spot), []DiffOption{WithTolerance(4)}, []image.Rectangle{spot}, 8, 800, 1},
+		{"nearby changes merged", greyImage(image.Point{}, white, spot, beside), nil, []image.Rectangle{spot.Union(beside)}, 9, 800, 1.125},
+		{"nearby changes apart", greyImage(image.Point{}, white, spot, beside), []DiffOption{WithMergeDistance(0)}, []image.Rectangle{spot, beside}, 9, 800, 1.125},
+		{"distant changes apart", greyImage(image.Point{}, white, spot, far), nil, []image.Rectangle{spot, far}, 12, 800, 1.5},
+		{"ignored", greyImage(image.Point{}, white, far), []DiffOption{WithIgnore(clock)}, nil, 0, 656, 0},
+		{"partly ignored", greyImage(image.Point{}, white, spot, far), []DiffOption{WithIgnore(clock)}, []image.Rectangle{spot}, 8, 656, 100 * 8.0 / 656},
+		// b is compared relative to its own bounds
+		{"offset bounds", greyImage(image.Pt(100, 50), white, spot), nil, []image.Rectangle{spot}, 8, 800, 1},
+	}
+	a := greyImage(image.Point{}, white)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Diff(a, tt.b, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.Regions, tt.wantRegions) {
+				t.Errorf("Regions = %v, want %v", res.Regions, tt.wantRegions)
+			}
+			if res.Changed != tt.wantChanged || res.Total != tt.wantTotal || res.Percent != tt.wantPercent {
+				t.Errorf("Diff = %v, want %d of %d pixels changed (%.3f%%)", res, tt.wantChanged, tt.wantTotal, tt.wantPercent)
+			}
+			if res.Equal() != (tt.wantChanged == 0) {
+				t.Errorf("Equal() = %v with %d changed pixels", res.Equal(), res.Changed)
+			}
+			if res.Image.Rect != a.Rect {
+				t.Errorf("diff image bounds %v, want %v", res.Image.Rect, a.Rect)
+			}
+		})
+	}
+}
+
+func TestDiffImage(t *testing.T) {
+	spot := image.Rect(4, 4, 6, 8)
+	res, err := Diff(greyImage(image.Point{}, grey), greyImage(image.Point{}, color.RGBA{A: 0xFF}, spot), WithIgnore(image.Rect(30, 0, 40, 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		p    image.Point
+		want color.RGBA
+	}{
+		{image.Pt(5, 5), color.RGBA{R: 0xFF, A: 0xFF}},                     // changed
+		{image.Pt(3, 3), color.RGBA{R: 0xFF, B: 0xFF, A: 0xFF}},            // region outline
+		{image.Pt(35, 10), color.RGBA{R: 0x20, G: 0x20, B: 0xC0, A: 0xFF}}, // ignored
+		{image.Pt(20, 10), color.RGBA{R: 0xE0, G: 0xE0, B: 0xE0, A: 0xFF}}, // faded
+	}
+	for _, tt := range tests {
+		if got := res.Image.RGBAAt(tt.p.X, tt.p.Y); got != tt.want {
+			t.Errorf("diff image at %v = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestDiffSizeMismatch(t *testing.T) {
+	if _, err := Diff(image.NewRGBA(image.Rect(0, 0, 4, 4)), image.NewRGBA(image.Rect(0, 0, 4, 5))); err == nil {
+		t.Error("Diff of images of different sizes succeeded")
+	}
+}
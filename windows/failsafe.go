@@ -0,0 +1,16 @@
+//go:build windows
+
+package windows
+
+import "errors"
+
+// ErrFailSafe is returned by a Player with WithFailSafe when the cursor is in a corner of
+// the primary display, where the user can throw the mouse to stop a runaway script.
+var ErrFailSafe = errors.New("fail-safe triggered: the mouse is in a corner of the screen")
+
+// FailSafeTriggered reports whether the cursor is in a corner of the primary display, the
+// fail-safe position of pyautogui.
+func FailSafeTriggered() bool {
+	p, screen := Position(), GetScreenDimensions()
+	return (p.X == 0 || p.X == screen.X-1) && (p.Y == 0 || p.Y == screen.Y-1)
+}
@@ -0,0 +1,135 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"slices"
+)
+
+// ErrColorNotFound is returned by FindColor when no pixel in the region matches the colour.
+var ErrColorNotFound = errors.New("color not found")
+
+// ColorBlob is a group of connected pixels matching a colour.
+type ColorBlob struct {
+	Bounds   image.Rectangle // bounding box, in the coordinates of the searched image
+	Area     int             // number of matching pixels
+	Centroid image.Point     // mean position of the matching pixels, a good point to click
+}
+
+// colorMask returns, for each pixel of img in row order, whether it matches c within
+// tolerance per channel.
+func colorMask(img image.Image, c color.Color, tolerance uint8) []bool {
+	rgba := toRGBA(img)
+	want := color.RGBAModel.Convert(c).(color.RGBA)
+	w, h := rgba.Rect.Dx(), rgba.Rect.Dy()
+	mask := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x := 0; x < w; x++ {
+			mask[y*w+x] = pixelMatches(row[x*4:], want, tolerance)
+		}
+	}
+	return mask
+}
+
+// pixelMatches reports whether the RGBA pixel at the start of pix matches want, allowing
+// each of the red, green and blue channels to differ by up to tolerance as
+// PixelMatchesColor does.
+func pixelMatches(pix []byte, want color.RGBA, tolerance uint8) bool {
+	tol := int(tolerance)
+	return absDiff(pix[0], want.R) <= tol && absDiff(pix[1], want.G) <= tol && absDiff(pix[2], want.B) <= tol
+}
+
+// FindColorInImage returns the first pixel of img, scanning row by row, that matches c
+// within tolerance per channel. It reports false if there is none.
+func FindColorInImage(img image.Image, c color.Color, tolerance uint8) (image.Point, bool) {
+	rgba := toRGBA(img)
+	want := color.RGBAModel.Convert(c).(color.RGBA)
+	for y := rgba.Rect.Min.Y; y < rgba.Rect.Max.Y; y++ {
+		for x := rgba.Rect.Min.X; x < rgba.Rect.Max.X; x++ {
+			if pixelMatches(rgba.Pix[rgba.PixOffset(x, y):], want, tolerance) {
+				return image.Pt(x, y), true
+			}
+		}
+	}
+	return image.Point{}, false
+}
+
+// FindAllColorInImage returns every pixel of img that matches c within tolerance per
+// channel, in row order.
+func FindAllColorInImage(img image.Image, c color.Color, tolerance uint8) []image.Point {
+	b := img.Bounds()
+	var points []image.Point
+	for i, ok := range colorMask(img, c, tolerance) {
+		if ok {
+			points = append(points, image.Pt(b.Min.X+i%b.Dx(), b.Min.Y+i/b.Dx()))
+		}
+	}
+	return points
+}
+
+// FindColorBlobs groups the pixels of img that match c within tolerance per channel into
+// blobs of 8-connected pixels, e.g. to find status dots or error badges, and returns them
+// largest first. Blobs smaller than minArea pixels are left out, to skip anti-aliasing
+// specks. img can be a CaptureRect result; add the captured region's Min to its
+// coordinates to get screen coordinates.
+func FindColorBlobs(img image.Image, c color.Color, tolerance uint8, minArea int) []ColorBlob {
+	b := img.Bounds()
+	groups, _ := components(colorMask(img, c, tolerance), b.Dx(), b.Dy())
+	var blobs []ColorBlob
+	for _, g := range groups {
+		if g.Area < minArea {
+			continue
+		}
+		blobs = append(blobs, ColorBlob{Bounds: g.Bounds.Add(b.Min), Area: g.Area, Centroid: g.Centroid.Add(b.Min)})
+	}
+	slices.SortStableFunc(blobs, func(x, y ColorBlob) int { return y.Area - x.Area })
+	return blobs
+}
+
+// FindColor returns the first point, in screen coordinates, of the region of the screen
+// that matches c within tolerance per channel, scanning row by row. It returns
+// ErrColorNotFound if there is none.
+func FindColor(region image.Rectangle, c color.Color, tolerance uint8) (image.Point, error) {
+	img, err := CaptureRect(region)
+	if err != nil {
+		return image.Point{}, err
+	}
+	p, ok := FindColorInImage(img, c, tolerance)
+	if !ok {
+		return image.Point{}, ErrColorNotFound
+	}
+	return p.Add(region.Min), nil
+}
+
+// FindAllColor returns every point, in screen coordinates, of the region of the screen
+// that matches c within tolerance per channel, in row order.
+func FindAllColor(region image.Rectangle, c color.Color, tolerance uint8) ([]image.Point, error) {
+	img, err := CaptureRect(region)
+	if err != nil {
+		return nil, err
+	}
+	points := FindAllColorInImage(img, c, tolerance)
+	for i := range points {
+		points[i] = points[i].Add(region.Min)
+	}
+	return points, nil
+}
+
+// FindColorBlobsOnScreen is like FindColorBlobs for a region of the screen, returning
+// blobs in screen coordinates.
+func FindColorBlobsOnScreen(region image.Rectangle, c color.Color, tolerance uint8, minArea int) ([]ColorBlob, error) {
+	img, err := CaptureRect(region)
+	if err != nil {
+		return nil, err
+	}
+	blobs := FindColorBlobs(img, c, tolerance, minArea)
+	for i := range blobs {
+		blobs[i].Bounds = blobs[i].Bounds.Add(region.Min)
+		blobs[i].Centroid = blobs[i].Centroid.Add(region.Min)
+	}
+	return blobs, nil
+}
@@ -0,0 +1,111 @@
+//go:build windows
+
+package windows
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"testing"
+)
+
+// badgeImage returns a 20x10 white image with bounds at origin, a 2x2 red square at 3,2
+// and a 3x3 slightly lighter red square at 12,5.
+func badgeImage(origin image.Point) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10).Add(origin))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(3, 2, 5, 4).Add(origin), image.NewUniform(color.RGBA{R: 0xFF, A: 0xFF}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(12, 5, 15, 8).Add(origin), image.NewUniform(color.RGBA{R: 0xF0, G: 0x10, B: 0x10, A: 0xFF}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestFindColorInImage(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	tests := []struct {
+		name      string
+		origin    image.Point
+		c         color.Color
+		tolerance uint8
+		want      image.Point
+		wantOK    bool
+	}{
+		{"exact", image.Point{}, red, 0, image.Pt(3, 2), true},
+		{"exact lighter square", image.Point{}, color.RGBA{R: 0xF0, G: 0x10, B: 0x10, A: 0xFF}, 0, image.Pt(12, 5), true},
+		{"tolerance reaches the first square", image.Point{}, color.RGBA{R: 0xF0, G: 0x10, B: 0x10, A: 0xFF}, 0x10, image.Pt(3, 2), true},
+		{"missing", image.Point{}, color.RGBA{B: 0xFF, A: 0xFF}, 8, image.Point{}, false},
+		{"other colour model", image.Point{}, color.NRGBA{R: 0xFF, A: 0xFF}, 0, image.Pt(3, 2), true},
+		{"image coordinates", image.Pt(100, 50), red, 0, image.Pt(103, 52), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FindColorInImage(badgeImage(tt.origin), tt.c, tt.tolerance)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("FindColorInImage() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFindAllColorInImage(t *testing.T) {
+	img := badgeImage(image.Pt(10, 10))
+	got := FindAllColorInImage(img, color.RGBA{R: 0xFF, A: 0xFF}, 0)
+	want := []image.Point{{13, 12}, {14, 12}, {13, 13}, {14, 13}}
+	if !slices.Equal(got, want) {
+		t.Errorf("FindAllColorInImage() = %v, want %v", got, want)
+	}
+	if got := FindAllColorInImage(img, color.RGBA{R: 0xFF, A: 0xFF}, 0x10); len(got) != 4+9 {
+		t.Errorf("FindAllColorInImage() with tolerance found %d pixels, want 13", len(got))
+	}
+}
+
+func TestFindColorBlobs(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	tests := []struct {
+		name      string
+		origin    image.Point
+		tolerance uint8
+		minArea   int
+		want      []ColorBlob
+	}{
+		{"one exact blob", image.Point{}, 0, 1, []ColorBlob{
+			{Bounds: image.Rect(3, 2, 5, 4), Area: 4, Centroid: image.Pt(4, 3)},
+		}},
+		{"largest first", image.Point{}, 0x10, 1, []ColorBlob{
+			{Bounds: image.Rect(12, 5, 15, 8), Area: 9, Centroid: image.Pt(13, 6)},
+			{Bounds: image.Rect(3, 2, 5, 4), Area: 4, Centroid: image.Pt(4, 3)},
+		}},
+		{"small blobs left out", image.Point{}, 0x10, 5, []ColorBlob{
+			{Bounds: image.Rect(12, 5, 15, 8), Area: 9, Centroid: image.Pt(13, 6)},
+		}},
+		{"image coordinates", image.Pt(100, 50), 0, 1, []ColorBlob{
+			{Bounds: image.Rect(103, 52, 105, 54), Area: 4, Centroid: image.Pt(104, 53)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FindColorBlobs(badgeImage(tt.origin), red, tt.tolerance, tt.minArea); !slices.Equal(got, tt.want) {
+				t.Errorf("FindColorBlobs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindColorBlobsMarkers(t *testing.T) {
+	// Markers drawn in a darker red are found within a tolerance, one blob each
+	img := image.NewRGBA(image.Rect(0, 0, 100, 60))
+	DrawMarker(img, image.Pt(20, 20), MarkerCircle, 6, color.RGBA{R: 0xE0, G: 0x10, B: 0x10, A: 0xFF})
+	DrawMarker(img, image.Pt(70, 35), MarkerCrosshair, 4, color.RGBA{R: 0xE0, G: 0x10, B: 0x10, A: 0xFF})
+
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	if p, ok := FindColorInImage(img, red, 8); ok {
+		t.Errorf("FindColorInImage() matched %v outside the tolerance", p)
+	}
+	blobs := FindColorBlobs(img, red, 40, 4)
+	if len(blobs) != 2 {
+		t.Fatalf("found %d blobs, want 2: %v", len(blobs), blobs)
+	}
+	if c := blobs[1].Centroid; c != image.Pt(70, 35) {
+		t.Errorf("crosshair centroid %v, want (70,35)", c)
+	}
+}
@@ -0,0 +1,71 @@
+//go:build windows
+
+// Package goldentest compares screenshots with golden images in tests. It is kept apart
+// from the windows package so programs using goautogui do not import testing or get the
+// -update-golden flag.
+package goldentest
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	goautogui "github.com/mhmdibrahimm/goautogui/windows"
+)
+
+var update = flag.Bool("update-golden", false, "rewrite golden images passed to AssertMatchesGolden")
+
+// AssertMatchesGolden compares img with the PNG golden image at path and fails t if they
+// differ, writing the actual image and a highlighted diff image next to the golden file,
+// with its extension replaced by .actual.png and .diff.png. opts are passed to
+// goautogui.Diff, e.g. to set a tolerance or ignore a clock.
+//
+// Run the tests with -update-golden, or with GOAUTOGUI_UPDATE_GOLDEN=1 in the environment,
+// to create or rewrite the golden files instead.
+func AssertMatchesGolden(t testing.TB, img image.Image, path string, opts ...goautogui.DiffOption) {
+	t.Helper()
+	if *update || os.Getenv("GOAUTOGUI_UPDATE_GOLDEN") == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		// No metadata, so the file only changes when the pixels do
+		if err := goautogui.SaveScreenshot(path, img, goautogui.WithoutMetadata()); err != nil {
+			t.Fatalf("failed to update golden image: %v", err)
+		}
+		t.Logf("updated golden image %s", path)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open golden image (run with -update-golden to create it): %v", err)
+	}
+	golden, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("failed to decode golden image %s: %v", path, err)
+	}
+
+	res, err := goautogui.Diff(golden, img, opts...)
+	if err != nil {
+		t.Fatalf("image does not match golden %s: %v", path, err)
+	}
+	if res.Equal() {
+		return
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	actualPath, diffPath := base+".actual.png", base+".diff.png"
+	if err := goautogui.SaveScreenshot(actualPath, img, goautogui.WithoutMetadata()); err != nil {
+		t.Logf("failed to write actual image: %v", err)
+	}
+	if err := goautogui.SaveScreenshot(diffPath, res.Image, goautogui.WithoutMetadata()); err != nil {
+		t.Logf("failed to write diff image: %v", err)
+	}
+	t.Errorf("image does not match golden %s: %v, regions %v\nactual: %s\ndiff: %s",
+		path, res, res.Regions, actualPath, diffPath)
+}
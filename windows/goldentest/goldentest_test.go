@@ -0,0 +1,28 @@
+//go:build windows
+
+package goldentest
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssertMatchesGolden(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(3, 4, color.RGBA{R: 255, A: 255})
+	path := filepath.Join(t.TempDir(), "golden.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	AssertMatchesGolden(t, img, path)
+}
@@ -0,0 +1,149 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+var (
+	// ErrWindowHung is returned by the *Hwnd functions when the target window did not
+	// process a sent message within the timeout, or its thread is not responding.
+	ErrWindowHung = errors.New("window is not responding")
+	// ErrInvalidWindow is returned by the *Hwnd functions when the handle does not
+	// identify an existing window, e.g. because the window has been destroyed.
+	ErrInvalidWindow = errors.New("invalid window handle")
+)
+
+// HwndOption configures how the *Hwnd functions deliver messages to a window.
+// Options can be passed per call, or set for all calls with SetHwndDefaults.
+type HwndOption func(*hwndConfig)
+
+type hwndConfig struct {
+	post    bool                             // queue messages with PostMessage instead of sending them
+	timeout time.Duration                    // SendMessageTimeout timeout
+	flags   win32.SEND_MESSAGE_TIMEOUT_FLAGS // SendMessageTimeout SMTO_* flags
+}
+
+// hwndDefaults holds the configuration every *Hwnd call starts from.
+var hwndDefaults = struct {
+	sync.RWMutex
+	cfg hwndConfig
+}{cfg: hwndConfig{
+	// SMTO_ABORTIFHUNG: return if target thread is not responding
+	// 2000 ms timeout is plenty
+	timeout: 2000 * time.Millisecond,
+	flags:   win32.SMTO_ABORTIFHUNG,
+}}
+
+// SetHwndDefaults changes the delivery options used by every *Hwnd call. Options passed to
+// an individual call are applied on top of these defaults.
+func SetHwndDefaults(opts ...HwndOption) {
+	hwndDefaults.Lock()
+	defer hwndDefaults.Unlock()
+	for _, opt := range opts {
+		opt(&hwndDefaults.cfg)
+	}
+}
+
+// WithPostMessage makes the *Hwnd functions queue messages with PostMessage instead of
+// sending them with SendMessageTimeout. Posting does not wait for the window to process
+// the message, so it never blocks on a busy window, but it also cannot report whether the
+// window handled it.
+//
+// The modifier state set for the window's thread is released when a call returns, which
+// is usually before the window gets to the posted messages, so a posted Ctrl+S is not
+// reliably seen as a shortcut by code that checks GetKeyState, including accelerators.
+// To post a shortcut, hold the modifiers with HoldHwndOpts, press the key, and release
+// them once the window has handled it.
+func WithPostMessage() HwndOption {
+	return func(c *hwndConfig) {
+		c.post = true
+	}
+}
+
+// WithSendMessage makes the *Hwnd functions send messages with SendMessageTimeout and wait
+// for the window to process them. This is the default; use it to override a global
+// WithPostMessage for a single call.
+func WithSendMessage() HwndOption {
+	return func(c *hwndConfig) {
+		c.post = false
+	}
+}
+
+// WithMessageTimeout sets how long SendMessageTimeout waits for the window to process each
+// message before the call fails with ErrWindowHung.
+func WithMessageTimeout(timeout time.Duration) HwndOption {
+	return func(c *hwndConfig) {
+		c.timeout = timeout
+	}
+}
+
+// WithSendFlags sets the SMTO_* flags passed to SendMessageTimeout, e.g.
+// win32.SMTO_ABORTIFHUNG|win32.SMTO_ERRORONEXIT.
+func WithSendFlags(flags win32.SEND_MESSAGE_TIMEOUT_FLAGS) HwndOption {
+	return func(c *hwndConfig) {
+		c.flags = flags
+	}
+}
+
+func newHwndConfig(opts []HwndOption) hwndConfig {
+	hwndDefaults.RLock()
+	c := hwndDefaults.cfg
+	hwndDefaults.RUnlock()
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// validateHwnd returns ErrInvalidWindow if hwnd does not identify an existing window.
+func validateHwnd(hwnd win32.HWND) error {
+	if hwnd == 0 || win32.IsWindow(hwnd) == 0 {
+		return fmt.Errorf("%w: %#x", ErrInvalidWindow, hwnd)
+	}
+	return nil
+}
+
+// deliver sends or posts msg to hwnd as configured.
+func (c hwndConfig) deliver(hwnd win32.HWND, msg uint32, wparam win32.WPARAM, lparam win32.LPARAM) error {
+	if c.post {
+		return postMessage(hwnd, msg, wparam, lparam)
+	}
+	return c.sendMessageTimeout(hwnd, msg, wparam, lparam)
+}
+
+func (c hwndConfig) sendMessageTimeout(hwnd win32.HWND, msg uint32, wparam win32.WPARAM, lparam win32.LPARAM) error {
+	ret, winerr := win32.SendMessageTimeout(hwnd, msg, wparam, lparam,
+		c.flags, uint32(c.timeout.Milliseconds()), nil)
+	if ret != 0 {
+		return nil
+	}
+	switch winerr {
+	case win32.ERROR_INVALID_WINDOW_HANDLE:
+		return fmt.Errorf("%w: %#x", ErrInvalidWindow, hwnd)
+	case win32.ERROR_SUCCESS, win32.ERROR_TIMEOUT:
+		// SMTO_ABORTIFHUNG fails without setting an error code when the thread is hung
+		if validateHwnd(hwnd) != nil {
+			return fmt.Errorf("%w: %#x", ErrInvalidWindow, hwnd)
+		}
+		return fmt.Errorf("%w: window %#x did not process message %#x within %v", ErrWindowHung, hwnd, msg, c.timeout)
+	default:
+		return fmt.Errorf("SendMessageTimeout failed for message %#x: %v", msg, winerr)
+	}
+}
+
+func postMessage(hwnd win32.HWND, msg uint32, wparam win32.WPARAM, lparam win32.LPARAM) error {
+	if ok, winerr := win32.PostMessage(hwnd, msg, wparam, lparam); ok == 0 {
+		if winerr == win32.ERROR_INVALID_WINDOW_HANDLE {
+			return fmt.Errorf("%w: %#x", ErrInvalidWindow, hwnd)
+		}
+		return fmt.Errorf("PostMessage failed for message %#x: %v", msg, winerr)
+	}
+	return nil
+}
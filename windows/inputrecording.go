@@ -0,0 +1,291 @@
+//go:build windows
+
+package windows
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"time"
+)
+
+// EventKind is the kind of a RecordedEvent.
+type EventKind string
+
+const (
+	EventMouseMove EventKind = "move"      // EventMouseMove is a cursor move
+	EventMouseDown EventKind = "mouseDown" // EventMouseDown is a mouse button press
+	EventMouseUp   EventKind = "mouseUp"   // EventMouseUp is a mouse button release
+	EventScroll    EventKind = "scroll"    // EventScroll is a vertical wheel movement
+	EventHScroll   EventKind = "hscroll"   // EventHScroll is a horizontal wheel movement
+	EventKeyDown   EventKind = "keyDown"   // EventKeyDown is a key press, repeated while the key is held
+	EventKeyUp     EventKind = "keyUp"     // EventKeyUp is a key release
+)
+
+// RecordedEvent is a single input event of a Recording.
+type RecordedEvent struct {
+	Kind EventKind
+	Time time.Duration // since the recording started
+
+	// Mouse events
+	X, Y      int         // cursor position in screen coordinates
+	Button    MouseButton // EventMouseDown and EventMouseUp
+	Delta     int         // EventScroll and EventHScroll, in wheel units of WHEEL_DELTA (120) per notch
+	Thumbnail []byte      // EventMouseDown with WithThumbnails: PNG of the screen around the click
+
+	// Keyboard events
+	Key      KeyboardKeys
+	ScanCode int
+	Extended bool // the key is an extended key, such as the arrows or the right-hand Ctrl
+}
+
+// recordedEventJSON is the JSON form of a RecordedEvent, described in Recording.
+type recordedEventJSON struct {
+	Time      int64     `json:"t"`
+	Kind      EventKind `json:"kind"`
+	X         *int      `json:"x,omitempty"`
+	Y         *int      `json:"y,omitempty"`
+	Button    string    `json:"button,omitempty"`
+	Delta     int       `json:"delta,omitempty"`
+	Thumbnail []byte    `json:"thumbnail,omitempty"`
+	VK        int       `json:"vk,omitempty"`
+	Scan      int       `json:"scan,omitempty"`
+	Extended  bool      `json:"extended,omitempty"`
+}
+
+var buttonNames = map[MouseButton]string{
+	MouseLeftButton:   "left",
+	MouseMiddleButton: "middle",
+	MouseRightButton:  "right",
+	MouseX1Button:     "x1",
+	MouseX2Button:     "x2",
+}
+
+// isMouseEvent reports whether the event has a cursor position.
+func (e RecordedEvent) isMouseEvent() bool {
+	return e.Kind != EventKeyDown && e.Kind != EventKeyUp
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e RecordedEvent) MarshalJSON() ([]byte, error) {
+	j := recordedEventJSON{Time: e.Time.Milliseconds(), Kind: e.Kind}
+	if e.isMouseEvent() {
+		j.X, j.Y = &e.X, &e.Y
+	} else {
+		j.VK, j.Scan, j.Extended = int(e.Key), e.ScanCode, e.Extended
+	}
+	if e.Kind == EventMouseDown || e.Kind == EventMouseUp {
+		name, ok := buttonNames[e.Button]
+		if !ok {
+			return nil, fmt.Errorf("cannot record %v", e.Button)
+		}
+		j.Button = name
+	}
+	j.Delta, j.Thumbnail = e.Delta, e.Thumbnail
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *RecordedEvent) UnmarshalJSON(data []byte) error {
+	var j recordedEventJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*e = RecordedEvent{
+		Kind:      j.Kind,
+		Time:      time.Duration(j.Time) * time.Millisecond,
+		Delta:     j.Delta,
+		Thumbnail: j.Thumbnail,
+		Key:       KeyboardKeys(j.VK),
+		ScanCode:  j.Scan,
+		Extended:  j.Extended,
+	}
+	if j.X != nil && j.Y != nil {
+		e.X, e.Y = *j.X, *j.Y
+	}
+	switch j.Kind {
+	case EventMouseDown, EventMouseUp:
+		found := false
+		for mb, name := range buttonNames {
+			if name == j.Button {
+				e.Button, found = mb, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown mouse button %q", j.Button)
+		}
+	case EventMouseMove, EventScroll, EventHScroll:
+		if j.X == nil || j.Y == nil {
+			return fmt.Errorf("%s event at %dms has no position", j.Kind, j.Time)
+		}
+	case EventKeyDown, EventKeyUp:
+		if j.VK == 0 {
+			return fmt.Errorf("%s event at %dms has no key", j.Kind, j.Time)
+		}
+	default:
+		return fmt.Errorf("unknown event kind %q", j.Kind)
+	}
+	return nil
+}
+
+// Replay performs the event with the matching input call: SetCursorPosition, MouseDown,
+// MouseUp, ScrollRaw, HorizontalScrollRaw, VKeyDown or VKeyUp.
+func (e RecordedEvent) Replay() error {
+	switch e.Kind {
+	case EventMouseMove:
+		SetCursorPosition(e.X, e.Y)
+	case EventMouseDown:
+		_, err := MouseDown(e.Button, e.X, e.Y)
+		return err
+	case EventMouseUp:
+		_, err := MouseUp(e.Button, e.X, e.Y)
+		return err
+	case EventScroll:
+		ScrollRaw(e.X, e.Y, e.Delta)
+	case EventHScroll:
+		HorizontalScrollRaw(e.X, e.Y, e.Delta)
+	case EventKeyDown:
+		return VKeyDown(e.Key)
+	case EventKeyUp:
+		return VKeyUp(e.Key)
+	default:
+		return fmt.Errorf("unknown event kind %q", e.Kind)
+	}
+	return nil
+}
+
+// String method for better printing
+func (e RecordedEvent) String() string {
+	switch e.Kind {
+	case EventMouseDown, EventMouseUp:
+		return fmt.Sprintf("%v %s %v at (%d,%d)", e.Time, e.Kind, e.Button, e.X, e.Y)
+	case EventScroll, EventHScroll:
+		return fmt.Sprintf("%v %s %d at (%d,%d)", e.Time, e.Kind, e.Delta, e.X, e.Y)
+	case EventKeyDown, EventKeyUp:
+		return fmt.Sprintf("%v %s vk 0x%02X", e.Time, e.Kind, int(e.Key))
+	default:
+		return fmt.Sprintf("%v %s (%d,%d)", e.Time, e.Kind, e.X, e.Y)
+	}
+}
+
+// RecordingVersion is the version of the recording format written by Recording.
+const RecordingVersion = 1
+
+// Recording is a sequence of input events captured by a Recorder.
+//
+// Recordings are stored as JSON, which is also valid YAML 1.2, so it can be read and
+// edited with YAML tooling:
+//
+//	{
+//	  "version": 1,
+//	  "started": "2025-01-02T15:04:05Z",
+//	  "screen": {"x": 0, "y": 0, "width": 1920, "height": 1080},
+//	  "events": [
+//	    {"t": 0, "kind": "move", "x": 10, "y": 20},
+//	    {"t": 250, "kind": "mouseDown", "x": 10, "y": 20, "button": "left", "thumbnail": "iVBORw0KGgo..."},
+//	    {"t": 310, "kind": "mouseUp", "x": 10, "y": 20, "button": "left"},
+//	    {"t": 900, "kind": "scroll", "x": 10, "y": 20, "delta": -120},
+//	    {"t": 1500, "kind": "keyDown", "vk": 39, "scan": 77, "extended": true},
+//	    {"t": 1580, "kind": "keyUp", "vk": 39, "scan": 77, "extended": true}
+//	  ]
+//	}
+//
+// version is RecordingVersion, started the RFC 3339 time recording started and screen the
+// virtual screen bounds. t is milliseconds since the recording started. kind is one of
+// move, mouseDown, mouseUp, scroll, hscroll, keyDown and keyUp. button is left, middle,
+// right, x1 or x2. delta is in wheel units, 120 per notch, positive away from the user or
+// to the right. vk is the virtual-key code (KeyboardKeys) and scan the hardware scan code.
+// thumbnail is a base64 PNG, present on mouseDown events recorded with WithThumbnails.
+type Recording struct {
+	Started time.Time
+	Screen  image.Rectangle // virtual screen bounds when recording started
+	Events  []RecordedEvent
+}
+
+type recordingJSON struct {
+	Version int       `json:"version"`
+	Started time.Time `json:"started"`
+	Screen  struct {
+		X      int `json:"x"`
+		Y      int `json:"y"`
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"screen"`
+	Events []RecordedEvent `json:"events"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *Recording) MarshalJSON() ([]byte, error) {
+	j := recordingJSON{Version: RecordingVersion, Started: r.Started, Events: r.Events}
+	j.Screen.X, j.Screen.Y = r.Screen.Min.X, r.Screen.Min.Y
+	j.Screen.Width, j.Screen.Height = r.Screen.Dx(), r.Screen.Dy()
+	if j.Events == nil {
+		j.Events = []RecordedEvent{}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Recording) UnmarshalJSON(data []byte) error {
+	var j recordingJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Version != RecordingVersion {
+		return fmt.Errorf("unsupported recording version %d", j.Version)
+	}
+	r.Started = j.Started
+	r.Screen = image.Rect(j.Screen.X, j.Screen.Y, j.Screen.X+j.Screen.Width, j.Screen.Y+j.Screen.Height)
+	r.Events = j.Events
+	return nil
+}
+
+// WriteTo writes the recording to w as indented JSON.
+func (r *Recording) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// Save writes the recording to path as JSON.
+func (r *Recording) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadRecording reads a recording in the JSON format described in Recording.
+func ReadRecording(rd io.Reader) (*Recording, error) {
+	var r Recording
+	if err := json.NewDecoder(rd).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid recording: %v", err)
+	}
+	return &r, nil
+}
+
+// LoadRecording reads a recording saved with Save.
+func LoadRecording(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRecording(f)
+}
+
+// virtualScreenRect returns the bounds of the virtual screen.
+func virtualScreenRect() image.Rectangle {
+	off, size := GetVirtualScreenOffset(), GetVirtualScreenSize()
+	return image.Rect(off.X, off.Y, off.X+size.X, off.Y+size.Y)
+}
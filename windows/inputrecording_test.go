@@ -0,0 +1,84 @@
+//go:build windows
+
+package windows
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	want := &Recording{
+		Started: time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC),
+		Screen:  image.Rect(-1920, 0, 1920, 1080),
+		Events: []RecordedEvent{
+			{Kind: EventMouseMove, X: -10, Y: 20},
+			{Kind: EventMouseDown, Time: 250 * time.Millisecond, X: 10, Y: 20, Button: MouseX2Button, Thumbnail: []byte("\x89PNG")},
+			{Kind: EventMouseUp, Time: 310 * time.Millisecond, X: 10, Y: 20, Button: MouseX2Button},
+			{Kind: EventScroll, Time: 900 * time.Millisecond, X: 0, Y: 0, Delta: -120},
+			{Kind: EventHScroll, Time: time.Second, X: 5, Y: 5, Delta: 240},
+			{Kind: EventKeyDown, Time: 1500 * time.Millisecond, Key: KEY_RIGHT, ScanCode: 77, Extended: true},
+			{Kind: EventKeyUp, Time: 1580 * time.Millisecond, Key: KEY_RIGHT, ScanCode: 77, Extended: true},
+		},
+	}
+	var buf bytes.Buffer
+	if _, err := want.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"version": 1`, `"kind": "mouseDown"`, `"button": "x2"`, `"vk": 39`, `"width": 3840`} {
+		if !strings.Contains(buf.String(), field) {
+			t.Errorf("recording JSON has no %s:\n%s", field, buf.String())
+		}
+	}
+	got, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRecording() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecordingEmpty(t *testing.T) {
+	data, err := json.Marshal(&Recording{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"events":[]`) {
+		t.Errorf("empty recording %s, want an empty events list", data)
+	}
+}
+
+func TestReadRecordingErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"unknown version", `{"version": 2, "events": []}`, "unsupported recording version 2"},
+		{"no version", `{"events": []}`, "unsupported recording version 0"},
+		{"unknown kind", `{"version": 1, "events": [{"t": 0, "kind": "teleport", "x": 1, "y": 1}]}`, `unknown event kind "teleport"`},
+		{"unknown button", `{"version": 1, "events": [{"t": 0, "kind": "mouseDown", "x": 1, "y": 1, "button": "thumb"}]}`, `unknown mouse button "thumb"`},
+		{"move without position", `{"version": 1, "events": [{"t": 5, "kind": "move", "x": 1}]}`, "move event at 5ms has no position"},
+		{"key without vk", `{"version": 1, "events": [{"t": 5, "kind": "keyUp"}]}`, "keyUp event at 5ms has no key"},
+		{"not JSON", `version: 1`, "invalid recording"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadRecording(strings.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ReadRecording() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordedEventMarshalButton(t *testing.T) {
+	// Primary and secondary depend on the mouse settings, so they are not recorded
+	if _, err := json.Marshal(RecordedEvent{Kind: EventMouseDown, Button: MousePrimaryButton}); err == nil {
+		t.Error("marshalling a primary button press succeeded")
+	}
+}
@@ -3,11 +3,13 @@
 package windows
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf16"
 
 	win32 "github.com/zzl/go-win32api/v2/win32"
 )
@@ -78,49 +80,6 @@ func KeyDown(key rune) error {
 	return nil
 }
 
-// Sends WM_KEYDOWN for a virtual key to a specific HWND.
-func VKeyDownHwnd(hwnd win32.HWND, key KeyboardKeys) {
-	vk := win32.VIRTUAL_KEY(key)
-	// Build lParam: repeat=1, scancode, extended-bit if needed
-	scan := win32.MapVirtualKey(uint32(vk), win32.MAPVK_VK_TO_VSC)
-	lp := uintptr(1) | (uintptr(scan) << 16)
-
-	// Extended keys set bit 24
-	switch vk {
-	case win32.VK_INSERT, win32.VK_DELETE, win32.VK_HOME, win32.VK_END,
-		win32.VK_PRIOR, win32.VK_NEXT,
-		win32.VK_LEFT, win32.VK_RIGHT, win32.VK_UP, win32.VK_DOWN,
-		win32.VK_DIVIDE, win32.VK_NUMLOCK,
-		win32.VK_RCONTROL, win32.VK_RMENU: // Right Ctrl/Alt
-		lp |= 1 << 24
-	}
-
-	sendMessageTimeout(hwnd, win32.WM_KEYDOWN, win32.WPARAM(vk), win32.LPARAM(lp))
-}
-
-// Sends WM_KEYUP for a virtual key to a specific HWND.
-func VKeyUpHwnd(hwnd win32.HWND, key KeyboardKeys) {
-	vk := win32.VIRTUAL_KEY(key)
-	// Build lParam mirroring the keydown (same scan/extended), plus up flags
-	scan := win32.MapVirtualKey(uint32(vk), win32.MAPVK_VK_TO_VSC)
-	lp := uintptr(1) | (uintptr(scan) << 16)
-
-	switch vk {
-	case win32.VK_INSERT, win32.VK_DELETE, win32.VK_HOME, win32.VK_END,
-		win32.VK_PRIOR, win32.VK_NEXT,
-		win32.VK_LEFT, win32.VK_RIGHT, win32.VK_UP, win32.VK_DOWN,
-		win32.VK_DIVIDE, win32.VK_NUMLOCK,
-		win32.VK_RCONTROL, win32.VK_RMENU:
-		lp |= 1 << 24
-	}
-
-	// Key-up bits: bit30=previous state, bit31=transition
-	lp |= 1 << 30
-	lp |= 1 << 31
-
-	sendMessageTimeout(hwnd, win32.WM_KEYUP, win32.WPARAM(vk), win32.LPARAM(lp))
-}
-
 // Presses the specified key up. If the key is not valid, it returns an error.
 func KeyUp(key rune) error {
 	needsShift := isShiftCharacter(string(key))
@@ -147,8 +106,17 @@ func KeyUp(key rune) error {
 }
 
 func Press(keys string, presses int, interval time.Duration) error {
+	return PressCtx(context.Background(), keys, presses, interval)
+}
+
+// PressCtx is like Press but stops between key presses when ctx is cancelled, returning ctx.Err().
+// Every key that was pressed down is released before returning.
+func PressCtx(ctx context.Context, keys string, presses int, interval time.Duration) error {
 	for i := 0; i < presses; i++ {
 		for _, k := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 
 			err := KeyDown(k)
 			if err != nil {
@@ -162,7 +130,9 @@ func Press(keys string, presses int, interval time.Duration) error {
 		}
 
 		if i < presses-1 { // Don't sleep after the last press
-			time.Sleep(interval)
+			if err := sleepCtx(ctx, interval); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -170,8 +140,18 @@ func Press(keys string, presses int, interval time.Duration) error {
 }
 
 func VPress(presses int, interval time.Duration, keys ...KeyboardKeys) error {
+	return VPressCtx(context.Background(), presses, interval, keys...)
+}
+
+// VPressCtx is like VPress but stops between key presses when ctx is cancelled, returning ctx.Err().
+// Every key that was pressed down is released before returning.
+func VPressCtx(ctx context.Context, presses int, interval time.Duration, keys ...KeyboardKeys) error {
 	for i := 0; i < presses; i++ {
 		for _, k := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			err := VKeyDown(k)
 			if err != nil {
 				return fmt.Errorf("failed to press key down '%d': %v", k, err)
@@ -184,7 +164,9 @@ func VPress(presses int, interval time.Duration, keys ...KeyboardKeys) error {
 		}
 
 		if i < presses-1 { // Don't sleep after the last press
-			time.Sleep(interval)
+			if err := sleepCtx(ctx, interval); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -192,7 +174,9 @@ func VPress(presses int, interval time.Duration, keys ...KeyboardKeys) error {
 }
 
 type HoldContext struct {
-	keys []KeyboardKeys
+	keys    []KeyboardKeys
+	hwnd    win32.HWND // target window for keys held with HoldHwnd, 0 for global input
+	hwndCfg hwndConfig // how HoldHwnd delivered its messages
 }
 
 // Hold presses the specified key(s) down and returns a cleanup function to release them.
@@ -217,6 +201,11 @@ func Hold(keys ...KeyboardKeys) (*HoldContext, error) {
 }
 
 func (hc *HoldContext) Release() error {
+	if hc.hwnd != 0 {
+		err := releaseHwnd(hc.hwnd, hc.keys, hc.hwndCfg)
+		hc.keys = nil
+		return err
+	}
 	// Release all keys in reverse order
 	for i := len(hc.keys) - 1; i >= 0; i-- {
 		err := VKeyUp(hc.keys[i])
@@ -230,16 +219,27 @@ func (hc *HoldContext) Release() error {
 
 // Typewrite simulates typing a message character by character with an optional interval between each character.
 func TypeWrite(message string, interval time.Duration) error {
+	return TypeWriteCtx(context.Background(), message, interval)
+}
+
+// TypeWriteCtx is like TypeWrite but stops between characters when ctx is cancelled, returning ctx.Err().
+func TypeWriteCtx(ctx context.Context, message string, interval time.Duration) error {
 	for _, char := range message {
 		charStr := string(char)
 
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		err := Press(charStr, 1, 0) // Press once with no interval between key down/up
 		if err != nil {
 			return fmt.Errorf("failed to type character '%s': %v", charStr, err)
 		}
 
 		if interval > 0 {
-			time.Sleep(interval * time.Millisecond)
+			if err := sleepCtx(ctx, interval*time.Millisecond); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -251,54 +251,146 @@ func Write(message string, interval time.Duration) error {
 	return TypeWrite(message, interval)
 }
 
-func WriteToHwnd(hwnd win32.HWND, s string, interval time.Duration) {
-	for _, r := range s {
-		// Skip non-printable (control) runes by convention
-		if !unicode.IsPrint(r) {
-			continue
-		}
+// WriteCtx is like Write but stops between characters when ctx is cancelled, returning ctx.Err().
+func WriteCtx(ctx context.Context, message string, interval time.Duration) error {
+	return TypeWriteCtx(ctx, message, interval)
+}
+
+// hwndControlKeys maps the control characters WriteToHwnd understands to the key that
+// produces them, so they are delivered as a keystroke the window can react to instead of
+// a bare WM_CHAR.
+var hwndControlKeys = map[rune]KeyboardKeys{
+	'\n': KEY_RETURN,
+	'\r': KEY_RETURN,
+	'\t': KEY_TAB,
+	'\b': KEY_BACK,
+}
 
-		if r <= 0xFFFF {
-			// Single UTF-16 unit
-			sendMessageTimeout(hwnd, win32.WM_CHAR, win32.WPARAM(r), 1)
-		} else {
-			// Encode as UTF-16 surrogate pair
-			cp := uint32(r) - 0x10000
-			hi := 0xD800 + (cp >> 10)
-			lo := 0xDC00 + (cp & 0x3FF)
-			sendMessageTimeout(hwnd, win32.WM_CHAR, win32.WPARAM(hi), 1)
-			sendMessageTimeout(hwnd, win32.WM_CHAR, win32.WPARAM(lo), 1)
+// WriteToHwnd types s into a specific HWND using WM_CHAR messages, pausing interval between
+// characters. Newlines, carriage returns ("\r\n" counts as one), tabs and backspaces are sent
+// as a WM_KEYDOWN, WM_CHAR, WM_KEYUP sequence for the matching key. Other control characters
+// are skipped. The returned error reports the first rune that could not be delivered.
+func WriteToHwnd(hwnd win32.HWND, s string, interval time.Duration, opts ...HwndOption) error {
+	if err := validateHwnd(hwnd); err != nil {
+		return err
+	}
+	cfg := newHwndConfig(opts)
+	for _, c := range hwndChars(s) {
+		if err := writeCharToHwnd(hwnd, c, cfg); err != nil {
+			return fmt.Errorf("failed to write rune %q at index %d: %w", c.r, c.index, err)
 		}
 
 		if interval > 0 {
 			time.Sleep(interval)
 		}
 	}
+	return nil
+}
+
+// hwndChar is a character WriteToHwnd delivers: the UTF-16 code units of its WM_CHAR
+// messages, sent within a keystroke of key unless key is 0.
+type hwndChar struct {
+	index int  // of the rune in s
+	r     rune // as it appears in s
+	key   KeyboardKeys
+	units []uint16
+}
+
+// hwndChars translates s into the characters WriteToHwnd delivers, turning "\r\n" into a
+// single Enter and dropping control characters without a key.
+func hwndChars(s string) []hwndChar {
+	var chars []hwndChar
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == '\n' && i > 0 && runes[i-1] == '\r' {
+			continue // already sent as part of "\r\n"
+		}
+		if key, ok := hwndControlKeys[r]; ok {
+			// Enter always produces a carriage return character
+			char := uint16(r)
+			if key == KEY_RETURN {
+				char = '\r'
+			}
+			chars = append(chars, hwndChar{i, r, key, []uint16{char}})
+			continue
+		}
+		// Skip other non-printable (control) runes by convention
+		if !unicode.IsPrint(r) {
+			continue
+		}
+		chars = append(chars, hwndChar{i, r, 0, utf16.AppendRune(nil, r)})
+	}
+	return chars
+}
+
+func writeCharToHwnd(hwnd win32.HWND, c hwndChar, cfg hwndConfig) error {
+	if c.key != 0 {
+		if err := keyDownHwnd(hwnd, c.key, cfg); err != nil {
+			return err
+		}
+		if err := cfg.deliver(hwnd, win32.WM_CHAR, win32.WPARAM(c.units[0]), keystrokeLParam(c.key, false, false, false)); err != nil {
+			keyUpHwnd(hwnd, c.key, cfg) // don't leave the key held
+			return err
+		}
+		return keyUpHwnd(hwnd, c.key, cfg)
+	}
+	// Characters beyond the BMP are sent as a surrogate pair
+	for _, u := range c.units {
+		if err := cfg.deliver(hwnd, win32.WM_CHAR, win32.WPARAM(u), 1); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Performs key down presses on the arguments passed in order, then performs key releases in reverse order.
 func HotKey(interval time.Duration, keys ...KeyboardKeys) error {
+	return HotKeyCtx(context.Background(), interval, keys...)
+}
+
+// HotKeyCtx is like HotKey but stops when ctx is cancelled. Keys that are already down are
+// released in reverse order, without waiting for interval, before ctx.Err() is returned.
+func HotKeyCtx(ctx context.Context, interval time.Duration, keys ...KeyboardKeys) error {
 	if len(keys) == 0 {
 		return errors.New("no keys provided for HotKey")
 	}
-	fmt.Println("Pressing keys:", keys)
+	pressed := 0
 	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return releaseKeys(keys[:pressed], err)
+		}
 		err := VKeyDown(key)
 		if err != nil {
-			return fmt.Errorf("failed to release key '%s': %v", fmt.Sprint(key), err)
+			return releaseKeys(keys[:pressed], fmt.Errorf("failed to press key '%s': %v", fmt.Sprint(key), err))
+		}
+		pressed++
+		if err := sleepCtx(ctx, interval); err != nil {
+			return releaseKeys(keys[:pressed], err)
 		}
-		time.Sleep(interval)
-	}
-	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
-		keys[i], keys[j] = keys[j], keys[i]
 	}
-	fmt.Println(keys)
-	for _, key := range keys {
-		err := VKeyUp(key)
+	for i := len(keys) - 1; i >= 0; i-- {
+		err := VKeyUp(keys[i])
 		if err != nil {
-			return fmt.Errorf("failed to release key '%s': %v", fmt.Sprint(key), err)
+			return releaseKeys(keys[:i], fmt.Errorf("failed to release key '%s': %v", fmt.Sprint(keys[i]), err))
+		}
+		if err := sleepCtx(ctx, interval); err != nil {
+			return releaseKeys(keys[:i], err)
 		}
-		time.Sleep(interval)
 	}
 	return nil
 }
+
+// releaseKeys releases keys in reverse order and returns cause, joined with any keys that
+// failed to release. It is used to avoid leaving keys stuck down when an action is aborted.
+func releaseKeys(keys []KeyboardKeys, cause error) error {
+	errs := []error{cause}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err := VKeyUp(keys[i]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release key '%d': %w", keys[i], err))
+		}
+	}
+	if len(errs) == 1 {
+		return cause
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,336 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// extendedKeys are the virtual keys that set the extended-key flag (bit 24) in the lParam
+// of a keystroke message. These are the keys of the enhanced keyboard that share a scan
+// code with a key in the main block: the navigation cluster, the arrows, numpad divide,
+// right Ctrl/Alt and the Windows/Application keys.
+var extendedKeys = map[KeyboardKeys]bool{
+	KEY_CANCEL:   true,
+	KEY_PRIOR:    true,
+	KEY_NEXT:     true,
+	KEY_END:      true,
+	KEY_HOME:     true,
+	KEY_LEFT:     true,
+	KEY_UP:       true,
+	KEY_RIGHT:    true,
+	KEY_DOWN:     true,
+	KEY_SNAPSHOT: true,
+	KEY_INSERT:   true,
+	KEY_DELETE:   true,
+	KEY_LWIN:     true,
+	KEY_RWIN:     true,
+	KEY_APPS:     true,
+	KEY_DIVIDE:   true,
+	KEY_NUMLOCK:  true,
+	KEY_RCONTROL: true,
+	KEY_RMENU:    true,
+}
+
+// hwndKeys tracks which keys are currently held down for each window that received
+// keystrokes through the *Hwnd functions. Windows only keeps this state for real input,
+// so it is needed to fill in the previous-state and context bits and to choose between
+// WM_KEYDOWN and WM_SYSKEYDOWN.
+var hwndKeys = struct {
+	sync.Mutex
+	held map[win32.HWND]map[KeyboardKeys]bool
+}{held: make(map[win32.HWND]map[KeyboardKeys]bool)}
+
+// genericKey maps left/right modifier keys to the generic key that Windows reports in the
+// wParam of keystroke messages. Other keys are returned unchanged.
+func genericKey(key KeyboardKeys) KeyboardKeys {
+	switch key {
+	case KEY_LSHIFT, KEY_RSHIFT:
+		return KEY_SHIFT
+	case KEY_LCONTROL, KEY_RCONTROL:
+		return KEY_CONTROL
+	case KEY_LMENU, KEY_RMENU:
+		return KEY_MENU
+	}
+	return key
+}
+
+// isModifierKey reports whether key is a Shift, Ctrl, Alt or Windows key.
+func isModifierKey(key KeyboardKeys) bool {
+	switch genericKey(key) {
+	case KEY_SHIFT, KEY_CONTROL, KEY_MENU, KEY_LWIN, KEY_RWIN:
+		return true
+	}
+	return false
+}
+
+// modifierHeld reports whether any variant (generic, left or right) of the modifier is
+// in the held set.
+func modifierHeld(held map[KeyboardKeys]bool, modifier KeyboardKeys) bool {
+	for k, down := range held {
+		if down && genericKey(k) == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// keystrokeMessage returns the message to send for a keystroke. Windows sends
+// WM_SYSKEYDOWN/WM_SYSKEYUP for F10 and for keys pressed while Alt is held, unless Ctrl
+// is held too.
+func keystrokeMessage(key KeyboardKeys, up, altDown, ctrlDown bool) uint32 {
+	sys := key == KEY_F10 || (altDown && !ctrlDown)
+	switch {
+	case sys && up:
+		return win32.WM_SYSKEYUP
+	case sys:
+		return win32.WM_SYSKEYDOWN
+	case up:
+		return win32.WM_KEYUP
+	default:
+		return win32.WM_KEYDOWN
+	}
+}
+
+// keystrokeLParam builds the lParam of a keystroke message:
+// bits 0-15 repeat count, 16-23 scan code, 24 extended key, 29 context code (Alt is down),
+// 30 previous key state and 31 transition state.
+func keystrokeLParam(key KeyboardKeys, up, prevDown, altDown bool) win32.LPARAM {
+	scan := win32.MapVirtualKey(uint32(key), win32.MAPVK_VK_TO_VSC)
+	lp := uintptr(1) | (uintptr(scan&0xFF) << 16)
+	if extendedKeys[key] {
+		lp |= 1 << 24
+	}
+	if altDown {
+		lp |= 1 << 29
+	}
+	if up || prevDown {
+		lp |= 1 << 30
+	}
+	if up {
+		lp |= 1 << 31
+	}
+	return win32.LPARAM(lp)
+}
+
+// syncKeyboardState sets or clears key in the keyboard state of the thread that owns hwnd,
+// so that GetKeyState calls made by the window while handling a message (e.g. checking for
+// Ctrl in a WM_KEYDOWN handler) see the modifiers held through the *Hwnd functions.
+func syncKeyboardState(hwnd win32.HWND, key KeyboardKeys, down bool) {
+	// AttachThreadInput and Get/SetKeyboardState operate on the calling OS thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	current := win32.GetCurrentThreadId()
+	target := win32.GetWindowThreadProcessId(hwnd, nil)
+	if target == 0 {
+		return
+	}
+	if target != current {
+		if win32.AttachThreadInput(current, target, 1) == 0 {
+			return
+		}
+		defer win32.AttachThreadInput(current, target, 0)
+	}
+
+	var state [256]byte
+	if ok, _ := win32.GetKeyboardState(&state[0]); ok == 0 {
+		return
+	}
+	for _, k := range []KeyboardKeys{key, genericKey(key)} {
+		if down {
+			state[k] |= 0x80
+		} else {
+			state[k] &^= 0x80
+		}
+	}
+	win32.SetKeyboardState(&state[0])
+}
+
+// Sends WM_KEYDOWN for a virtual key to a specific HWND, or WM_SYSKEYDOWN while Alt is held.
+// Keys held through the *Hwnd functions are remembered per window, so the lParam carries
+// the correct context and previous-state bits and modifiers are visible to GetKeyState.
+func VKeyDownHwnd(hwnd win32.HWND, key KeyboardKeys, opts ...HwndOption) error {
+	if err := validateHwnd(hwnd); err != nil {
+		return err
+	}
+	return keyDownHwnd(hwnd, key, newHwndConfig(opts))
+}
+
+// Sends WM_KEYUP for a virtual key to a specific HWND, or WM_SYSKEYUP while Alt is held.
+func VKeyUpHwnd(hwnd win32.HWND, key KeyboardKeys, opts ...HwndOption) error {
+	if err := validateHwnd(hwnd); err != nil {
+		return err
+	}
+	return keyUpHwnd(hwnd, key, newHwndConfig(opts))
+}
+
+func keyDownHwnd(hwnd win32.HWND, key KeyboardKeys, cfg hwndConfig) error {
+	hwndKeys.Lock()
+	held := hwndKeys.held[hwnd]
+	if held == nil {
+		held = make(map[KeyboardKeys]bool)
+		hwndKeys.held[hwnd] = held
+	}
+	prevDown := held[key]
+	held[key] = true
+	altDown := modifierHeld(held, KEY_MENU)
+	ctrlDown := modifierHeld(held, KEY_CONTROL)
+	hwndKeys.Unlock()
+
+	if isModifierKey(key) {
+		syncKeyboardState(hwnd, key, true)
+	}
+
+	msg := keystrokeMessage(key, false, altDown, ctrlDown)
+	lp := keystrokeLParam(key, false, prevDown, altDown)
+	if err := cfg.deliver(hwnd, msg, win32.WPARAM(genericKey(key)), lp); err != nil {
+		// The key did not go down, so do not leave it held
+		if !prevDown {
+			forgetHwndKey(hwnd, key)
+			if isModifierKey(key) {
+				syncKeyboardState(hwnd, key, false)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// forgetHwndKey removes key from the keys held for hwnd.
+func forgetHwndKey(hwnd win32.HWND, key KeyboardKeys) {
+	hwndKeys.Lock()
+	defer hwndKeys.Unlock()
+	held := hwndKeys.held[hwnd]
+	delete(held, key)
+	if len(held) == 0 {
+		delete(hwndKeys.held, hwnd)
+	}
+}
+
+func keyUpHwnd(hwnd win32.HWND, key KeyboardKeys, cfg hwndConfig) error {
+	hwndKeys.Lock()
+	held := hwndKeys.held[hwnd]
+	// The message type depends on Alt being held before the release (releasing Alt
+	// itself is a WM_SYSKEYUP), the context bit on Alt still being held after it.
+	altBefore := modifierHeld(held, KEY_MENU) || genericKey(key) == KEY_MENU
+	ctrlDown := modifierHeld(held, KEY_CONTROL)
+	delete(held, key)
+	altAfter := modifierHeld(held, KEY_MENU)
+	if len(held) == 0 {
+		delete(hwndKeys.held, hwnd)
+	}
+	hwndKeys.Unlock()
+
+	if isModifierKey(key) {
+		syncKeyboardState(hwnd, key, false)
+	}
+
+	msg := keystrokeMessage(key, true, altBefore, ctrlDown)
+	lp := keystrokeLParam(key, true, true, altAfter)
+	return cfg.deliver(hwnd, msg, win32.WPARAM(genericKey(key)), lp)
+}
+
+// PressHwnd sends key down and key up messages for each of the keys to a specific HWND,
+// repeating the sequence presses times with interval between repetitions.
+// Messages are delivered with the options set by SetHwndDefaults; PressHwndOpts takes
+// options per call.
+func PressHwnd(hwnd win32.HWND, presses int, interval time.Duration, keys ...KeyboardKeys) error {
+	return PressHwndOpts(hwnd, presses, interval, keys)
+}
+
+// PressHwndOpts is like PressHwnd, delivering the messages with opts applied on top of the
+// defaults.
+func PressHwndOpts(hwnd win32.HWND, presses int, interval time.Duration, keys []KeyboardKeys, opts ...HwndOption) error {
+	if err := validateHwnd(hwnd); err != nil {
+		return err
+	}
+	cfg := newHwndConfig(opts)
+	for i := 0; i < presses; i++ {
+		for _, k := range keys {
+			if err := keyDownHwnd(hwnd, k, cfg); err != nil {
+				return fmt.Errorf("failed to press key down '%d': %w", k, err)
+			}
+			if err := keyUpHwnd(hwnd, k, cfg); err != nil {
+				return fmt.Errorf("failed to release key '%d': %w", k, err)
+			}
+		}
+
+		if i < presses-1 { // Don't sleep after the last press
+			time.Sleep(interval)
+		}
+	}
+	return nil
+}
+
+// HotKeyHwnd sends key down messages for the keys in order to a specific HWND, then key up
+// messages in reverse order. Modifiers are tracked, so HotKeyHwnd(hwnd, KEY_MENU, KEY_F)
+// produces WM_SYSKEYDOWN messages and HotKeyHwnd(hwnd, KEY_CONTROL, KEY_S) is seen as Ctrl+S.
+// Messages are delivered with the options set by SetHwndDefaults; HotKeyHwndOpts takes
+// options per call.
+func HotKeyHwnd(hwnd win32.HWND, keys ...KeyboardKeys) error {
+	return HotKeyHwndOpts(hwnd, keys)
+}
+
+// HotKeyHwndOpts is like HotKeyHwnd, delivering the messages with opts applied on top of the
+// defaults.
+func HotKeyHwndOpts(hwnd win32.HWND, keys []KeyboardKeys, opts ...HwndOption) error {
+	if len(keys) == 0 {
+		return errors.New("no keys provided for HotKeyHwnd")
+	}
+	if err := validateHwnd(hwnd); err != nil {
+		return err
+	}
+	cfg := newHwndConfig(opts)
+	for i, key := range keys {
+		if err := keyDownHwnd(hwnd, key, cfg); err != nil {
+			// Release the keys that were already pressed; keyDownHwnd has let go of key
+			releaseHwnd(hwnd, keys[:i], cfg)
+			return fmt.Errorf("failed to press key down '%d': %w", key, err)
+		}
+	}
+	return releaseHwnd(hwnd, keys, cfg)
+}
+
+// releaseHwnd sends key up messages for keys in reverse order. Every key is released even
+// if an earlier one fails; the first error is returned.
+func releaseHwnd(hwnd win32.HWND, keys []KeyboardKeys, cfg hwndConfig) error {
+	var firstErr error
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err := keyUpHwnd(hwnd, keys[i], cfg); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to release key '%d': %w", keys[i], err)
+		}
+	}
+	return firstErr
+}
+
+// HoldHwnd sends key down messages for the specified key(s) to a specific HWND and returns
+// a HoldContext whose Release method sends the matching key up messages.
+// Messages are delivered with the options set by SetHwndDefaults; HoldHwndOpts takes
+// options per call.
+func HoldHwnd(hwnd win32.HWND, keys ...KeyboardKeys) (*HoldContext, error) {
+	return HoldHwndOpts(hwnd, keys)
+}
+
+// HoldHwndOpts is like HoldHwnd, delivering the messages, including those of Release, with
+// opts applied on top of the defaults.
+func HoldHwndOpts(hwnd win32.HWND, keys []KeyboardKeys, opts ...HwndOption) (*HoldContext, error) {
+	if err := validateHwnd(hwnd); err != nil {
+		return nil, err
+	}
+	cfg := newHwndConfig(opts)
+	for i, k := range keys {
+		if err := keyDownHwnd(hwnd, k, cfg); err != nil {
+			// keyDownHwnd has let go of k, so only the keys before it are held
+			releaseHwnd(hwnd, keys[:i], cfg)
+			return nil, fmt.Errorf("failed to press key down '%d': %w", k, err)
+		}
+	}
+	return &HoldContext{keys: append([]KeyboardKeys(nil), keys...), hwnd: hwnd, hwndCfg: cfg}, nil
+}
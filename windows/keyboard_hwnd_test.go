@@ -0,0 +1,87 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+func TestKeystrokeMessage(t *testing.T) {
+	tests := []struct {
+		name                  string
+		key                   KeyboardKeys
+		up, altDown, ctrlDown bool
+		want                  uint32
+	}{
+		{"key down", KEY_A, false, false, false, win32.WM_KEYDOWN},
+		{"key up", KEY_A, true, false, false, win32.WM_KEYUP},
+		{"alt held", KEY_F, false, true, false, win32.WM_SYSKEYDOWN},
+		{"alt held up", KEY_F, true, true, false, win32.WM_SYSKEYUP},
+		{"ctrl+alt held", KEY_F, false, true, true, win32.WM_KEYDOWN},
+		{"f10", KEY_F10, false, false, false, win32.WM_SYSKEYDOWN},
+		{"f10 up", KEY_F10, true, false, false, win32.WM_SYSKEYUP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keystrokeMessage(tt.key, tt.up, tt.altDown, tt.ctrlDown); got != tt.want {
+				t.Errorf("keystrokeMessage(%d, up=%v, alt=%v, ctrl=%v) = %#x, want %#x",
+					tt.key, tt.up, tt.altDown, tt.ctrlDown, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeystrokeLParam(t *testing.T) {
+	const (
+		extended = 1 << 24
+		context  = 1 << 29
+		previous = 1 << 30
+		released = 1 << 31
+	)
+	tests := []struct {
+		name                  string
+		key                   KeyboardKeys
+		up, prevDown, altDown bool
+		want                  uintptr // apart from the scan code
+	}{
+		{"first press", KEY_A, false, false, false, 1},
+		{"repeat", KEY_A, false, true, false, 1 | previous},
+		{"release", KEY_A, true, true, false, 1 | previous | released},
+		{"alt held", KEY_F, false, false, true, 1 | context},
+		{"extended key", KEY_LEFT, false, false, false, 1 | extended},
+		{"extended release with alt", KEY_DELETE, true, true, true, 1 | extended | context | previous | released},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := uintptr(keystrokeLParam(tt.key, tt.up, tt.prevDown, tt.altDown))
+			if got := lp &^ (0xFF << 16); got != tt.want {
+				t.Errorf("keystrokeLParam flags = %#x, want %#x", got, tt.want)
+			}
+			scan := win32.MapVirtualKey(uint32(tt.key), win32.MAPVK_VK_TO_VSC)
+			if got := (lp >> 16) & 0xFF; got != uintptr(scan&0xFF) {
+				t.Errorf("keystrokeLParam scan code = %#x, want %#x", got, scan&0xFF)
+			}
+		})
+	}
+}
+
+func TestKeyDownHwndRollsBack(t *testing.T) {
+	// A handle that no window has, so delivery fails
+	const hwnd = win32.HWND(0x7FFF0001)
+	for _, post := range []bool{false, true} {
+		cfg := newHwndConfig(nil)
+		cfg.post = post
+		if err := keyDownHwnd(hwnd, KEY_CONTROL, cfg); !errors.Is(err, ErrInvalidWindow) {
+			t.Fatalf("keyDownHwnd(post=%v) error = %v, want ErrInvalidWindow", post, err)
+		}
+		hwndKeys.Lock()
+		held, ok := hwndKeys.held[hwnd]
+		hwndKeys.Unlock()
+		if ok {
+			t.Errorf("keyDownHwnd(post=%v) failed but left %v held", post, held)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package windows
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHwndChars(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []hwndChar
+	}{
+		{"plain", "ab", []hwndChar{{0, 'a', 0, []uint16{'a'}}, {1, 'b', 0, []uint16{'b'}}}},
+		{"crlf is one enter", "a\r\nb", []hwndChar{
+			{0, 'a', 0, []uint16{'a'}},
+			{1, '\r', KEY_RETURN, []uint16{'\r'}},
+			{3, 'b', 0, []uint16{'b'}},
+		}},
+		{"lf is enter", "\n", []hwndChar{{0, '\n', KEY_RETURN, []uint16{'\r'}}}},
+		{"lone cr", "\r", []hwndChar{{0, '\r', KEY_RETURN, []uint16{'\r'}}}},
+		{"lf lf", "\n\n", []hwndChar{
+			{0, '\n', KEY_RETURN, []uint16{'\r'}},
+			{1, '\n', KEY_RETURN, []uint16{'\r'}},
+		}},
+		{"tab and backspace", "\t\b", []hwndChar{
+			{0, '\t', KEY_TAB, []uint16{'\t'}},
+			{1, '\b', KEY_BACK, []uint16{'\b'}},
+		}},
+		{"other control characters are skipped", "\x00a\x1b", []hwndChar{{1, 'a', 0, []uint16{'a'}}}},
+		{"surrogate pair", "😀", []hwndChar{{0, '😀', 0, []uint16{0xD83D, 0xDE00}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hwndChars(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hwndChars(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+//go:build windows
+
+package windows
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// keyNames maps the key names accepted by KeyByName, like pyautogui's KEYBOARD_KEYS, to keys.
+var keyNames = map[string]KeyboardKeys{
+	"enter": KEY_RETURN, "return": KEY_RETURN, "tab": KEY_TAB, "space": KEY_SPACE,
+	"backspace": KEY_BACK, "esc": KEY_ESCAPE, "escape": KEY_ESCAPE,
+	"delete": KEY_DELETE, "del": KEY_DELETE, "insert": KEY_INSERT, "ins": KEY_INSERT,
+	"home": KEY_HOME, "end": KEY_END, "pageup": KEY_PRIOR, "pgup": KEY_PRIOR,
+	"pagedown": KEY_NEXT, "pgdn": KEY_NEXT,
+	"up": KEY_UP, "down": KEY_DOWN, "left": KEY_LEFT, "right": KEY_RIGHT,
+
+	"shift": KEY_SHIFT, "shiftleft": KEY_LSHIFT, "shiftright": KEY_RSHIFT,
+	"ctrl": KEY_CONTROL, "control": KEY_CONTROL, "ctrlleft": KEY_LCONTROL, "ctrlright": KEY_RCONTROL,
+	"alt": KEY_MENU, "altleft": KEY_LMENU, "altright": KEY_RMENU,
+	"win": KEY_LWIN, "winleft": KEY_LWIN, "winright": KEY_RWIN, "apps": KEY_APPS,
+
+	"capslock": KEY_CAPITAL, "numlock": KEY_NUMLOCK, "scrolllock": KEY_SCROLL,
+	"printscreen": KEY_SNAPSHOT, "prtsc": KEY_SNAPSHOT, "pause": KEY_PAUSE,
+
+	"add": KEY_ADD, "subtract": KEY_SUBTRACT, "multiply": KEY_MULTIPLY, "divide": KEY_DIVIDE,
+	"decimal": KEY_DECIMAL, "separator": KEY_SEPARATOR,
+
+	"volumemute": KEY_VOLUME_MUTE, "volumedown": KEY_VOLUME_DOWN, "volumeup": KEY_VOLUME_UP,
+	"nexttrack": KEY_MEDIA_NEXT_TRACK, "prevtrack": KEY_MEDIA_PREV_TRACK,
+	"stop": KEY_MEDIA_STOP, "playpause": KEY_MEDIA_PLAY_PAUSE,
+	"browserback": KEY_BROWSER_BACK, "browserforward": KEY_BROWSER_FORWARD,
+	"browserrefresh": KEY_BROWSER_REFRESH, "browserhome": KEY_BROWSER_HOME,
+}
+
+func init() {
+	for i := 0; i < 24; i++ {
+		keyNames[fmt.Sprintf("f%d", i+1)] = KEY_F1 + KeyboardKeys(i)
+	}
+	for i := 0; i < 10; i++ {
+		keyNames[fmt.Sprintf("num%d", i)] = KEY_NUMPAD0 + KeyboardKeys(i)
+	}
+}
+
+// KeyByName returns the key with the given name, ignoring case: a name such as enter,
+// ctrl, shift, alt, win, esc, f5, pageup or num0 (the same names as pyautogui), or a single
+// character, which maps to the key that types it on the current keyboard layout without
+// modifiers. It reports false if there is no such key.
+func KeyByName(name string) (KeyboardKeys, bool) {
+	if key, ok := keyNames[strings.ToLower(name)]; ok {
+		return key, true
+	}
+	if r := []rune(name); len(r) == 1 && r[0] <= 0xFFFF {
+		// The low byte is the virtual-key code, the high byte the modifiers it needs
+		if vk := win32.VkKeyScanW(uint16(r[0])); vk != -1 {
+			return KeyboardKeys(vk & 0xFF), true
+		}
+	}
+	return 0, false
+}
+
+// KeyNames returns the key names KeyByName accepts besides single characters, sorted.
+func KeyNames() []string {
+	return slices.Sorted(maps.Keys(keyNames))
+}
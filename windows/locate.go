@@ -0,0 +1,76 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ErrImageNotFound is returned by LocateOnScreen when the image does not appear in the region.
+var ErrImageNotFound = errors.New("image not found")
+
+// LocateInImage returns the bounds, in haystack's coordinates, of the first occurrence of
+// needle in haystack, scanning row by row. Every pixel of needle must match within tolerance
+// per channel; fully transparent needle pixels match anything, so cut-out icons can be
+// found on any background. It reports false if needle does not appear.
+//
+// The search compares pixels directly, so needle must be at the same scale as haystack,
+// e.g. captured with CaptureRect on the same display.
+func LocateInImage(haystack, needle image.Image, tolerance uint8) (image.Rectangle, bool) {
+	hay, ndl := toRGBA(haystack), toRGBA(needle)
+	hw, hh := hay.Rect.Dx(), hay.Rect.Dy()
+	nw, nh := ndl.Rect.Dx(), ndl.Rect.Dy()
+	if nw == 0 || nh == 0 || nw > hw || nh > hh {
+		return image.Rectangle{}, false
+	}
+
+	// The opaque needle pixels, as offsets into a haystack position, with their colours
+	type pixel struct {
+		off int
+		c   color.RGBA
+	}
+	var pixels []pixel
+	for y := 0; y < nh; y++ {
+		for x := 0; x < nw; x++ {
+			p := ndl.Pix[y*ndl.Stride+x*4:]
+			if p[3] == 0 {
+				continue
+			}
+			pixels = append(pixels, pixel{off: y*hay.Stride + x*4, c: color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]}})
+		}
+	}
+
+	for y := 0; y <= hh-nh; y++ {
+		for x := 0; x <= hw-nw; x++ {
+			base := y*hay.Stride + x*4
+			found := true
+			for _, p := range pixels {
+				if !pixelMatches(hay.Pix[base+p.off:], p.c, tolerance) {
+					found = false
+					break
+				}
+			}
+			if found {
+				return image.Rect(x, y, x+nw, y+nh).Add(hay.Rect.Min), true
+			}
+		}
+	}
+	return image.Rectangle{}, false
+}
+
+// LocateOnScreen returns the bounds, in screen coordinates, of the first occurrence of
+// needle in the region of the screen, as LocateInImage does. It returns ErrImageNotFound
+// if needle does not appear. Searching a smaller region is faster.
+func LocateOnScreen(region image.Rectangle, needle image.Image, tolerance uint8) (image.Rectangle, error) {
+	img, err := CaptureRect(region)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	r, ok := LocateInImage(img, needle, tolerance)
+	if !ok {
+		return image.Rectangle{}, ErrImageNotFound
+	}
+	return r.Add(region.Min), nil
+}
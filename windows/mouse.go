@@ -3,7 +3,12 @@
 package windows
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"math"
 	"time"
 
 	"github.com/zzl/go-win32api/v2/win32"
@@ -57,6 +62,29 @@ func IsMouseSwapped() bool {
 	return win32.GetSystemMetrics(win32.SM_SWAPBUTTON) != 0
 }
 
+// DoubleClickTime returns the maximum time allowed between two clicks for Windows to treat
+// them as a double-click, as set in the mouse control panel.
+func DoubleClickTime() time.Duration {
+	return time.Duration(win32.GetDoubleClickTime()) * time.Millisecond
+}
+
+// clickInterval returns the pause to leave between consecutive clicks, given the system
+// double-click time dct. Multi-clicks are kept well inside the double-click time, separate
+// clicks are spaced just beyond it. An interval set with WithInterval that would split a
+// multi-click is an error.
+func (c mouseConfig) clickInterval(dct time.Duration) (time.Duration, error) {
+	if c.separate {
+		return max(c.interval, dct+50*time.Millisecond), nil
+	}
+	if c.interval <= 0 {
+		return dct / 10, nil
+	}
+	if c.interval >= dct && c.clicks > 1 {
+		return 0, fmt.Errorf("click interval %v is not shorter than the double-click time %v; use WithSeparateClicks for separate clicks", c.interval, dct)
+	}
+	return c.interval, nil
+}
+
 // normalizeMouseButton normalizes the mouse button to a valid MouseButton type.
 // It converts MousePrimaryButton to MouseLeftButton or MouseRightButton based on the swap state
 // This only applies to the primary and secondary buttons.
@@ -89,6 +117,196 @@ func normalizeMouseButton(mb MouseButton) (MouseButton, error) {
 	}
 }
 
+// MouseOption configures a mouse action such as Click.
+type MouseOption func(*mouseConfig)
+
+type mouseConfig struct {
+	button       MouseButton
+	clicks       int
+	interval     time.Duration // pause between clicks, 0 to derive it from the double-click time
+	separate     bool          // space clicks so Windows does not combine them into a multi-click
+	moveDuration time.Duration // time taken to move to the target before acting
+	tween        TweenFunc
+	modifiers    []KeyboardKeys // keys held down for the duration of the action
+	holdDelay    time.Duration  // drag: pause after pressing the button, before moving
+	releaseDelay time.Duration  // drag: pause at the end point, before releasing the button
+}
+
+func newMouseConfig(opts []MouseOption) mouseConfig {
+	c := mouseConfig{
+		button: MousePrimaryButton,
+		clicks: 1,
+		tween:  Linear,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithButton sets the mouse button to use. The default is MousePrimaryButton.
+func WithButton(mb MouseButton) MouseOption {
+	return func(c *mouseConfig) {
+		c.button = mb
+	}
+}
+
+// WithClicks sets the number of clicks to perform. The default is 1.
+func WithClicks(clicks int) MouseOption {
+	return func(c *mouseConfig) {
+		c.clicks = clicks
+	}
+}
+
+// WithInterval sets the pause between consecutive clicks. By default the pause is a fraction
+// of the system double-click time. Click fails if the interval would reach the double-click
+// time and split a multi-click; use WithSeparateClicks for separate clicks.
+func WithInterval(interval time.Duration) MouseOption {
+	return func(c *mouseConfig) {
+		c.interval = interval
+	}
+}
+
+// WithSeparateClicks makes consecutive clicks wait out the system double-click time, so they
+// are delivered as individual single clicks instead of a double or triple click.
+func WithSeparateClicks() MouseOption {
+	return func(c *mouseConfig) {
+		c.separate = true
+	}
+}
+
+// WithMoveDuration makes the cursor glide from its current position to the target over
+// duration before acting, instead of jumping there.
+func WithMoveDuration(duration time.Duration) MouseOption {
+	return func(c *mouseConfig) {
+		c.moveDuration = duration
+	}
+}
+
+// WithTween sets the easing function used when moving over a duration. The default is Linear.
+func WithTween(tween TweenFunc) MouseOption {
+	return func(c *mouseConfig) {
+		if tween != nil {
+			c.tween = tween
+		}
+	}
+}
+
+// WithModifiers holds the keys down for the duration of the action and releases them in
+// reverse order afterwards, e.g. WithModifiers(KEY_CONTROL) for a ctrl-click or
+// WithModifiers(KEY_SHIFT) to extend a selection.
+func WithModifiers(keys ...KeyboardKeys) MouseOption {
+	return func(c *mouseConfig) {
+		c.modifiers = append([]KeyboardKeys(nil), keys...)
+	}
+}
+
+// WithHoldDelay makes drags wait for delay after pressing the button before starting to move,
+// for targets that only start a drag once the button has been held for a moment.
+func WithHoldDelay(delay time.Duration) MouseOption {
+	return func(c *mouseConfig) {
+		c.holdDelay = delay
+	}
+}
+
+// WithReleaseDelay makes drags hover at the end point for delay before releasing the button,
+// so that drag-and-drop targets register the hover and accept the drop.
+func WithReleaseDelay(delay time.Duration) MouseOption {
+	return func(c *mouseConfig) {
+		c.releaseDelay = delay
+	}
+}
+
+// mouseButtonEvents returns the mouse_event flags that press and release a physical button,
+// and the dwData identifying the X button.
+func mouseButtonEvents(mb MouseButton) (down, up win32.MOUSE_EVENT_FLAGS, dwData int, err error) {
+	switch mb {
+	case MouseLeftButton:
+		return win32.MOUSEEVENTF_LEFTDOWN, win32.MOUSEEVENTF_LEFTUP, 0, nil
+	case MouseRightButton:
+		return win32.MOUSEEVENTF_RIGHTDOWN, win32.MOUSEEVENTF_RIGHTUP, 0, nil
+	case MouseMiddleButton:
+		return win32.MOUSEEVENTF_MIDDLEDOWN, win32.MOUSEEVENTF_MIDDLEUP, 0, nil
+	case MouseX1Button:
+		return win32.MOUSEEVENTF_XDOWN, win32.MOUSEEVENTF_XUP, int(KEY_XBUTTON1), nil
+	case MouseX2Button:
+		return win32.MOUSEEVENTF_XDOWN, win32.MOUSEEVENTF_XUP, int(KEY_XBUTTON2), nil
+	}
+	return 0, 0, 0, fmt.Errorf("invalid mouse button: %v", mb)
+}
+
+// holdModifiers presses keys down in order. If one fails, the keys already pressed are released.
+func holdModifiers(keys []KeyboardKeys) error {
+	for i, k := range keys {
+		if err := VKeyDown(k); err != nil {
+			return releaseKeys(keys[:i], fmt.Errorf("failed to press modifier '%d': %v", k, err))
+		}
+	}
+	return nil
+}
+
+// tweenCursor moves the cursor from (startX, startY) to (endX, endY) over duration, following
+// tween. It stops early and returns ctx.Err() if ctx is cancelled.
+func tweenCursor(ctx context.Context, startX, startY, endX, endY int, duration time.Duration, tween TweenFunc) error {
+	return tweenPath(ctx, []image.Point{{startX, startY}, {endX, endY}}, duration, tween)
+}
+
+// tweenPath moves the cursor along the polyline through points over duration. tween is
+// applied to the distance travelled along the whole path, so waypoints do not cause the
+// cursor to stop. It stops early and returns ctx.Err() if ctx is cancelled.
+func tweenPath(ctx context.Context, points []image.Point, duration time.Duration, tween TweenFunc) error {
+	end := points[len(points)-1]
+
+	// Cumulative distance along the path at each point
+	dist := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		d := points[i].Sub(points[i-1])
+		dist[i] = dist[i-1] + math.Hypot(float64(d.X), float64(d.Y))
+	}
+	total := dist[len(dist)-1]
+
+	if duration <= 0 || total == 0 {
+		SetCursorPosition(end.X, end.Y)
+		return nil
+	}
+
+	// Calculate steps for smooth movement
+	dim := GetScreenDimensions()
+	numSteps := max(dim.X, dim.Y)
+	sleepAmount := duration / time.Duration(numSteps)
+	const MINIMUM_SLEEP = time.Millisecond
+	if sleepAmount < MINIMUM_SLEEP {
+		numSteps = max(1, int(duration/MINIMUM_SLEEP))
+		sleepAmount = duration / time.Duration(numSteps)
+	}
+
+	seg := 1
+	for i := 1; i <= numSteps; i++ {
+		if err := sleepCtx(ctx, sleepAmount); err != nil {
+			return err
+		}
+		d := tween(float64(i)/float64(numSteps)) * total
+		// Find the segment containing distance d; tweens may overshoot, so search both ways
+		for seg < len(points)-1 && d > dist[seg] {
+			seg++
+		}
+		for seg > 1 && d < dist[seg-1] {
+			seg--
+		}
+		a, b := points[seg-1], points[seg]
+		t := 0.0
+		if length := dist[seg] - dist[seg-1]; length > 0 {
+			t = (d - dist[seg-1]) / length
+		}
+		tweenX, tweenY := Lerp(float64(a.X), float64(a.Y), float64(b.X), float64(b.Y), t)
+		SetCursorPosition(int(math.Round(tweenX)), int(math.Round(tweenY)))
+	}
+
+	// Ensure we end at the exact target position
+	SetCursorPosition(end.X, end.Y)
+	return nil
+}
+
 // Makes the call to the mouse_event() win32 function.
 // dwData: if event has MOUSEEVENTF_WHEEL or MOUSEEVENTF_HWHEEL, then it specifies the amount
 // of wheel movement which is usually 120 units per notch (WHEEL_DELTA).
@@ -104,13 +322,6 @@ func sendMouseEvent(event win32.MOUSE_EVENT_FLAGS, x, y, dwData int) {
 	win32.Mouse_event(event, int32(convertedX), int32(convertedY), int32(dwData), 0)
 }
 
-func sendMessageTimeout(hwnd win32.HWND, msg uint32, wparam win32.WPARAM, lparam win32.LPARAM) {
-	// SMTO_ABORTIFHUNG: return if target thread is not responding
-	// 2000 ms timeout is plenty
-	win32.SendMessageTimeout(hwnd, msg, wparam, lparam,
-		win32.SMTO_ABORTIFHUNG, 2000, nil)
-}
-
 // Send the down up event to Windows by calling the mouse_event() win32
 // function.
 func MouseDown(mb MouseButton, x, y int) (bool, error) {
@@ -119,21 +330,9 @@ func MouseDown(mb MouseButton, x, y int) (bool, error) {
 		return false, err
 	}
 
-	var event win32.MOUSE_EVENT_FLAGS
-	dwData := 0
-	switch mb {
-	case MouseLeftButton:
-		event = win32.MOUSEEVENTF_LEFTDOWN
-	case MouseRightButton:
-		event = win32.MOUSEEVENTF_RIGHTDOWN
-	case MouseMiddleButton:
-		event = win32.MOUSEEVENTF_MIDDLEDOWN
-	case MouseX1Button:
-		event = win32.MOUSEEVENTF_XDOWN
-		dwData = int(KEY_XBUTTON1)
-	case MouseX2Button:
-		event = win32.MOUSEEVENTF_XDOWN
-		dwData = int(KEY_XBUTTON2)
+	event, _, dwData, err := mouseButtonEvents(mb)
+	if err != nil {
+		return false, err
 	}
 	sendMouseEvent(event, x, y, dwData)
 
@@ -148,100 +347,108 @@ func MouseUp(mb MouseButton, x, y int) (bool, error) {
 		return false, err
 	}
 
-	var event win32.MOUSE_EVENT_FLAGS
-	dwData := 0
-
-	switch mb {
-	case MouseLeftButton:
-		event = win32.MOUSEEVENTF_LEFTUP
-	case MouseRightButton:
-		event = win32.MOUSEEVENTF_RIGHTUP
-	case MouseMiddleButton:
-		event = win32.MOUSEEVENTF_MIDDLEUP
-	case MouseX1Button:
-		event = win32.MOUSEEVENTF_XUP
-		dwData = int(KEY_XBUTTON1)
-	case MouseX2Button:
-		event = win32.MOUSEEVENTF_XUP
-		dwData = int(KEY_XBUTTON2)
+	_, event, dwData, err := mouseButtonEvents(mb)
+	if err != nil {
+		return false, err
 	}
 	sendMouseEvent(event, x, y, dwData)
 
 	return true, nil
 }
 
-// Click performs a mouse button click at the specified (x, y) coordinates supporting multiple clicks.
+// ClickAt performs a mouse button click at the specified (x, y) coordinates supporting multiple clicks.
 func ClickAt(mb MouseButton, x, y, clicks int) error {
-	if mb != MouseLeftButton && mb != MouseRightButton && mb != MouseMiddleButton {
-		return fmt.Errorf("mouse button must be one of MouseLeftButton, MouseRightButton, or Middle; received %v", mb)
-	}
+	return Click(x, y, WithButton(mb), WithClicks(clicks))
+}
 
-	var event win32.MOUSE_EVENT_FLAGS
-	switch mb {
-	case MouseLeftButton:
-		event = MOUSEEVENTF_LEFTCLICK
-	case MouseRightButton:
-		event = MOUSEEVENTF_RIGHTCLICK
-	case MouseMiddleButton:
-		event = MOUSEEVENTF_MIDDLECLICK
+// Click clicks at the specified (x, y) coordinates, the equivalent of pyautogui's click().
+// By default it performs a single click with the primary button, moving there instantly.
+// Multiple clicks are spaced according to the system double-click time so that they are
+// recognised as a double or triple click.
+// Options select the button (any of the physical, primary or secondary buttons), the number
+// of clicks and the pause between them, a tweened move to the target, and modifier keys to
+// hold while clicking:
+//
+//	Click(x, y, WithModifiers(KEY_CONTROL))                        // ctrl-click
+//	Click(x, y, WithButton(MouseRightButton), WithClicks(2))       // right double-click
+//	Click(x, y, WithMoveDuration(time.Second), WithTween(EaseOutQuad))
+func Click(x, y int, opts ...MouseOption) error {
+	cfg := newMouseConfig(opts)
+	mb, err := normalizeMouseButton(cfg.button)
+	if err != nil {
+		return err
 	}
-	for i := 0; i < clicks; i++ {
-		sendMouseEvent(event|win32.MOUSEEVENTF_ABSOLUTE|win32.MOUSEEVENTF_MOVE, x, y, 0)
+	down, up, dwData, err := mouseButtonEvents(mb)
+	if err != nil {
+		return err
+	}
+	interval, err := cfg.clickInterval(DoubleClickTime())
+	if err != nil {
+		return err
+	}
+
+	if cfg.moveDuration > 0 {
+		cur := Position()
+		if err := tweenCursor(context.Background(), cur.X, cur.Y, x, y, cfg.moveDuration, cfg.tween); err != nil {
+			return err
+		}
 	}
-	return nil
-}
 
-// Click performs a mouse button click at the specified (x, y) coordinates.
-func Click(mb MouseButton, x, y int) error {
-	return ClickAt(mb, x, y, 1)
+	if err := holdModifiers(cfg.modifiers); err != nil {
+		return err
+	}
+	// Every click is sent with absolute coordinates to the same point, so follow-up clicks
+	// always land inside the double-click rectangle (SM_CXDOUBLECLK by SM_CYDOUBLECLK) of the
+	// first one, even if the cursor is moved while waiting.
+	for i := 0; i < cfg.clicks; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		sendMouseEvent(down|win32.MOUSEEVENTF_ABSOLUTE|win32.MOUSEEVENTF_MOVE, x, y, dwData)
+		sendMouseEvent(up|win32.MOUSEEVENTF_ABSOLUTE|win32.MOUSEEVENTF_MOVE, x, y, dwData)
+	}
+	return releaseKeys(cfg.modifiers, nil)
 }
 
 // LeftClick performs a left mouse button click at the specified (x, y) coordinates.
 func LeftClick(x, y int) error {
-	return Click(MouseLeftButton, x, y)
+	return Click(x, y, WithButton(MouseLeftButton))
 }
 
 // RightClick performs a right mouse button click at the specified (x, y) coordinates.
 func RightClick(x, y int) error {
-	return Click(MouseRightButton, x, y)
+	return Click(x, y, WithButton(MouseRightButton))
 }
 
 // MiddleClick performs a middle mouse button click at the specified (x, y) coordinates.
 func MiddleClick(x, y int) error {
-	return Click(MouseMiddleButton, x, y)
+	return Click(x, y, WithButton(MouseMiddleButton))
 }
 
 // X1Click performs a click with the first extra mouse button (usually the back button) at the specified (x, y) coordinates.
 func X1Click(x, y int) error {
-	return Click(MouseX1Button, x, y)
+	return Click(x, y, WithButton(MouseX1Button))
 }
 
 // X2Click performs a click with the second extra mouse button (usually the forward button) at the specified (x, y) coordinates.
 func X2Click(x, y int) error {
-	return Click(MouseX2Button, x, y)
+	return Click(x, y, WithButton(MouseX2Button))
 }
 
 // PrimaryClick perform a click with the primary mouse button at the specified (x, y) coordinates.
 // The primary button is usually the physical left button, only if swapped, it will be the right button.
 func PrimaryClick(x, y int) error {
-	mb, err := normalizeMouseButton(MousePrimaryButton)
-	if err != nil {
-		return fmt.Errorf("invalid primary mouse button: %v", err)
-	}
-	return Click(mb, x, y)
+	return Click(x, y, WithButton(MousePrimaryButton))
 }
 
 // SecondaryClick perform a click with the secondary mouse button at the specified (x, y) coordinates.
 // The secondary button is usually the physical right button, only if swapped, it will be the left button.
 func SecondaryClick(x, y int) error {
-	mb, err := normalizeMouseButton(MouseSecondaryButton)
-	if err != nil {
-		return fmt.Errorf("invalid secondary mouse button: %v", err)
-	}
-	return Click(mb, x, y)
+	return Click(x, y, WithButton(MouseSecondaryButton))
 }
 
 // DoubleClick performs a double mopuse button click at the specified (x, y) coordinates.
+// The clicks are spaced inside the system double-click time.
 func DoubleClick(mb MouseButton, x, y int) error {
 	return ClickAt(mb, x, y, 2)
 }
@@ -252,7 +459,12 @@ func TripleClick(mb MouseButton, x, y int) error {
 }
 
 // Click a specific HWND at a SCREEN point (no z-order issues).
-func ClickHwnd(hwnd win32.HWND, screenX, screenY int) {
+func ClickHwnd(hwnd win32.HWND, screenX, screenY int, opts ...HwndOption) error {
+	if err := validateHwnd(hwnd); err != nil {
+		return err
+	}
+	cfg := newHwndConfig(opts)
+
 	pt := win32.POINT{X: int32(screenX), Y: int32(screenY)}
 
 	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-mapwindowpoints
@@ -263,10 +475,13 @@ func ClickHwnd(hwnd win32.HWND, screenX, screenY int) {
 	cx, cy := clampToClient(hwnd, pt.X, pt.Y)
 
 	lp := win32.LPARAM(uintptr(cx) | uintptr(cy)<<16)
-	// SendMessage is synchronous; use SendMessageTimeout if you fear hangs.
-	sendMessageTimeout(hwnd, win32.WM_MOUSEMOVE, 0, lp)
-	sendMessageTimeout(hwnd, win32.WM_LBUTTONDOWN, win32.WPARAM(win32.MK_LBUTTON), lp)
-	sendMessageTimeout(hwnd, win32.WM_LBUTTONUP, 0, lp)
+	if err := cfg.deliver(hwnd, win32.WM_MOUSEMOVE, 0, lp); err != nil {
+		return err
+	}
+	if err := cfg.deliver(hwnd, win32.WM_LBUTTONDOWN, win32.WPARAM(win32.MK_LBUTTON), lp); err != nil {
+		return err
+	}
+	return cfg.deliver(hwnd, win32.WM_LBUTTONUP, 0, lp)
 }
 
 // Scroll performs a mouse scroll at the specified (x, y) coordinates.
@@ -292,13 +507,25 @@ func ScrollRaw(x, y, dwData int) {
 }
 
 // HorizontalScroll performs a horizontal mouse scroll at the specified (x, y) coordinates.
+// Positive notches scroll right, negative notches scroll left. Like Scroll, each notch is
+// WHEEL_DELTA (120) units.
 func HorizontalScroll(x, y, notches int) {
 	dim := GetScreenDimensions()
 	width, height := dim.X, dim.Y
 	x = max(0, min(x, width-1))
 	y = max(0, min(y, height-1))
+	dwData := notches * int(win32.WHEEL_DELTA)
+	sendMouseEvent(win32.MOUSEEVENTF_HWHEEL, x, y, dwData)
+}
 
-	sendMouseEvent(win32.MOUSEEVENTF_HWHEEL, x, y, notches)
+// HorizontalScrollRaw performs a horizontal mouse scroll at the specified (x, y)
+// coordinates with a custom scroll amount in wheel units, WHEEL_DELTA (120) per notch.
+func HorizontalScrollRaw(x, y, dwData int) {
+	dim := GetScreenDimensions()
+	width, height := dim.X, dim.Y
+	x = max(0, min(x, width-1))
+	y = max(0, min(y, height-1))
+	sendMouseEvent(win32.MOUSEEVENTF_HWHEEL, x, y, dwData)
 }
 
 // VerticalScroll performs a vertical mouse scroll at the specified (x, y) coordinates.
@@ -306,6 +533,127 @@ func VerticalScroll(x, y, notches int) {
 	Scroll(x, y, notches)
 }
 
+// ScrollSmooth scrolls vertically at the specified (x, y) coordinates by amount notches
+// (fractions allowed) spread over duration, the way precision touchpads do. The wheel delta
+// is split into sub-notch increments whose pacing follows tween (Linear if nil). Positive
+// amounts scroll up, negative amounts scroll down. Applications that only handle whole notches
+// accumulate the increments until they add up to WHEEL_DELTA.
+func ScrollSmooth(x, y int, amount float64, duration time.Duration, tween TweenFunc) {
+	scrollSmooth(context.Background(), win32.MOUSEEVENTF_WHEEL, x, y, amount, duration, tween)
+}
+
+// HorizontalScrollSmooth is like ScrollSmooth but scrolls horizontally. Positive amounts
+// scroll right, negative amounts scroll left.
+func HorizontalScrollSmooth(x, y int, amount float64, duration time.Duration, tween TweenFunc) {
+	scrollSmooth(context.Background(), win32.MOUSEEVENTF_HWHEEL, x, y, amount, duration, tween)
+}
+
+// scrollSmooth sends amount notches of wheel movement of the given kind (MOUSEEVENTF_WHEEL
+// or MOUSEEVENTF_HWHEEL) in increments over duration. It stops early and returns ctx.Err()
+// if ctx is cancelled.
+func scrollSmooth(ctx context.Context, event win32.MOUSE_EVENT_FLAGS, x, y int, amount float64, duration time.Duration, tween TweenFunc) error {
+	if tween == nil {
+		tween = Linear
+	}
+	dim := GetScreenDimensions()
+	x = max(0, min(x, dim.X-1))
+	y = max(0, min(y, dim.Y-1))
+
+	total := amount * float64(win32.WHEEL_DELTA)
+	// One increment per display frame, but never smaller than one wheel unit
+	const STEP_INTERVAL = 16 * time.Millisecond
+	numSteps := max(1, min(int(duration/STEP_INTERVAL), int(math.Abs(total))))
+	sleepAmount := duration / time.Duration(numSteps)
+
+	sent := 0
+	for i := 1; i <= numSteps; i++ {
+		target := int(math.Round(tween(float64(i)/float64(numSteps)) * total))
+		if delta := target - sent; delta != 0 {
+			sendMouseEvent(event, x, y, delta)
+			sent = target
+		}
+		if i < numSteps {
+			if err := sleepCtx(ctx, sleepAmount); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ScrollCondition reports whether ScrollUntil has reached its goal.
+type ScrollCondition func() (bool, error)
+
+// PixelCondition returns a ScrollCondition that is met once the pixel at (x, y) matches c
+// within tolerance on every channel.
+func PixelCondition(x, y int, c color.Color, tolerance uint8) ScrollCondition {
+	return func() (bool, error) {
+		return PixelMatchesColor(x, y, c, tolerance)
+	}
+}
+
+// RegionCondition returns a ScrollCondition that captures rect and is met once match
+// reports true for the captured image.
+func RegionCondition(rect image.Rectangle, match func(img *image.RGBA) bool) ScrollCondition {
+	return func() (bool, error) {
+		img, err := CaptureRect(rect)
+		if err != nil {
+			return false, err
+		}
+		return match(img), nil
+	}
+}
+
+// ImageCondition returns a ScrollCondition that is met once needle is visible in the region
+// of the screen, matching within tolerance as LocateOnScreen does.
+func ImageCondition(region image.Rectangle, needle image.Image, tolerance uint8) ScrollCondition {
+	return RegionCondition(region, func(img *image.RGBA) bool {
+		_, ok := LocateInImage(img, needle, tolerance)
+		return ok
+	})
+}
+
+// ScrollUntil scrolls vertically at the specified (x, y) coordinates, amount notches at a
+// time, until cond is met. Each step is scrolled smoothly and cond is checked every
+// interval, which also gives the content time to settle; intervals shorter than
+// MinScrollInterval are raised to it. It returns nil once cond is met, the error from cond
+// if it fails, or ctx.Err() when ctx is cancelled, so callers should bound it with a
+// deadline.
+func ScrollUntil(ctx context.Context, x, y int, amount float64, interval time.Duration, cond ScrollCondition) error {
+	return scrollUntil(ctx, win32.MOUSEEVENTF_WHEEL, x, y, amount, interval, cond)
+}
+
+// HorizontalScrollUntil is like ScrollUntil but scrolls horizontally. Positive amounts
+// scroll right, negative amounts scroll left.
+func HorizontalScrollUntil(ctx context.Context, x, y int, amount float64, interval time.Duration, cond ScrollCondition) error {
+	return scrollUntil(ctx, win32.MOUSEEVENTF_HWHEEL, x, y, amount, interval, cond)
+}
+
+// MinScrollInterval is the shortest interval at which ScrollUntil checks its condition, so
+// a zero interval does not capture the screen in a busy loop.
+const MinScrollInterval = 50 * time.Millisecond
+
+func scrollUntil(ctx context.Context, event win32.MOUSE_EVENT_FLAGS, x, y int, amount float64, interval time.Duration, cond ScrollCondition) error {
+	interval = max(interval, MinScrollInterval)
+	for {
+		ok, err := cond()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		// Scroll over half the interval and let the content settle for the rest
+		start := time.Now()
+		if err := scrollSmooth(ctx, event, x, y, amount, interval/2, Linear); err != nil {
+			return err
+		}
+		if err := sleepCtx(ctx, interval-time.Since(start)); err != nil {
+			return err
+		}
+	}
+}
+
 // MoveTo moves the mouse cursor to the specified (x, y) coordinates.
 func SetCursorPosition(x, y int) {
 	// TODO: use sendInput instead of win32.SetCursorPos for better compatibility
@@ -328,6 +676,12 @@ func Move(x, y int) {
 }
 
 func DragTo(x, y int, duration float64, mb MouseButton) error {
+	return DragToCtx(context.Background(), x, y, duration, mb)
+}
+
+// DragToCtx is like DragTo but stops between movement steps when ctx is cancelled.
+// The mouse button is released at the current cursor position before ctx.Err() is returned.
+func DragToCtx(ctx context.Context, x, y int, duration float64, mb MouseButton) error {
 	// Validate mouse button
 	if mb != MouseLeftButton && mb != MouseRightButton && mb != MouseMiddleButton {
 		return fmt.Errorf("mouse button must be one of MouseLeftButton, MouseRightButton, or Middle; received %v", mb)
@@ -343,6 +697,10 @@ func DragTo(x, y int, duration float64, mb MouseButton) error {
 		return nil
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Press mouse button down at start position
 	_, err := MouseDown(mb, startPos.X, startPos.Y)
 	if err != nil {
@@ -379,7 +737,13 @@ func DragTo(x, y int, duration float64, mb MouseButton) error {
 		SetCursorPosition(int(tweenX+0.5), int(tweenY+0.5)) // Round to nearest int
 
 		// Sleep between steps
-		time.Sleep(time.Duration(sleepAmount*1000) * time.Millisecond)
+		if err := sleepCtx(ctx, time.Duration(sleepAmount*1000)*time.Millisecond); err != nil {
+			cur := Position()
+			if _, upErr := MouseUp(mb, cur.X, cur.Y); upErr != nil {
+				return errors.Join(err, fmt.Errorf("failed to release mouse button: %w", upErr))
+			}
+			return err
+		}
 	}
 
 	// Ensure we end at the exact target position
@@ -393,3 +757,66 @@ func DragTo(x, y int, duration float64, mb MouseButton) error {
 
 	return nil
 }
+
+// DragPath presses the mouse button at the first point, drags the cursor through the remaining
+// points over duration and releases the button at the last point. It is useful for drawing and
+// gestures. Options select the button (WithButton), easing (WithTween), modifier keys held for
+// the whole drag such as KEY_CONTROL to copy or KEY_SHIFT to constrain (WithModifiers), and
+// pauses before moving (WithHoldDelay) and before releasing (WithReleaseDelay).
+func DragPath(points []image.Point, duration time.Duration, opts ...MouseOption) error {
+	return DragPathCtx(context.Background(), points, duration, opts...)
+}
+
+// DragPathCtx is like DragPath but stops when ctx is cancelled. The mouse button and modifier
+// keys are released before ctx.Err() is returned.
+func DragPathCtx(ctx context.Context, points []image.Point, duration time.Duration, opts ...MouseOption) error {
+	if len(points) == 0 {
+		return errors.New("no points provided for DragPath")
+	}
+	cfg := newMouseConfig(opts)
+	mb, err := normalizeMouseButton(cfg.button)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	start := points[0]
+	SetCursorPosition(start.X, start.Y)
+	if err := holdModifiers(cfg.modifiers); err != nil {
+		return err
+	}
+	if _, err := MouseDown(mb, start.X, start.Y); err != nil {
+		return releaseKeys(cfg.modifiers, fmt.Errorf("failed to press mouse button down: %v", err))
+	}
+
+	// release lets go of the button where the cursor is, then of the modifiers, so that
+	// modifiers such as Ctrl are still held when the drop happens.
+	release := func(cause error) error {
+		cur := Position()
+		if _, err := MouseUp(mb, cur.X, cur.Y); err != nil && cause == nil {
+			cause = fmt.Errorf("failed to release mouse button: %v", err)
+		}
+		return releaseKeys(cfg.modifiers, cause)
+	}
+
+	if err := sleepCtx(ctx, cfg.holdDelay); err != nil {
+		return release(err)
+	}
+	if err := tweenPath(ctx, points, duration, cfg.tween); err != nil {
+		return release(err)
+	}
+	if err := sleepCtx(ctx, cfg.releaseDelay); err != nil {
+		return release(err)
+	}
+	return release(nil)
+}
+
+// DragRel drags the mouse from its current position by (dx, dy) over duration.
+// It accepts the same options as DragPath.
+func DragRel(dx, dy int, duration time.Duration, opts ...MouseOption) error {
+	cur := Position()
+	start := image.Pt(cur.X, cur.Y)
+	return DragPath([]image.Point{start, start.Add(image.Pt(dx, dy))}, duration, opts...)
+}
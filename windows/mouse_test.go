@@ -0,0 +1,36 @@
+//go:build windows
+
+package windows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClickInterval(t *testing.T) {
+	const dct = 500 * time.Millisecond
+	tests := []struct {
+		name    string
+		cfg     mouseConfig
+		want    time.Duration
+		wantErr bool
+	}{
+		{"default", mouseConfig{clicks: 2}, dct / 10, false},
+		{"caller interval", mouseConfig{clicks: 2, interval: 100 * time.Millisecond}, 100 * time.Millisecond, false},
+		{"interval splits a multi-click", mouseConfig{clicks: 2, interval: dct}, 0, true},
+		{"long interval with a single click", mouseConfig{clicks: 1, interval: time.Second}, time.Second, false},
+		{"separate", mouseConfig{clicks: 2, separate: true}, dct + 50*time.Millisecond, false},
+		{"separate with a longer interval", mouseConfig{clicks: 2, separate: true, interval: time.Second}, time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.clickInterval(dct)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("clickInterval() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("clickInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
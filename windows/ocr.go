@@ -0,0 +1,627 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// ErrTextNotFound is returned by FindText when the text does not appear in the region.
+var ErrTextNotFound = errors.New("text not found")
+
+// bitmap is a binarised image: true pixels are ink.
+type bitmap struct {
+	w, h int
+	bits []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	return b.bits[y*b.w+x]
+}
+
+// crop returns the part of b inside r.
+func (b *bitmap) crop(r image.Rectangle) *bitmap {
+	out := &bitmap{w: r.Dx(), h: r.Dy(), bits: make([]bool, r.Dx()*r.Dy())}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(out.bits[(y-r.Min.Y)*out.w:], b.bits[y*b.w+r.Min.X:y*b.w+r.Max.X])
+	}
+	return out
+}
+
+func (b *bitmap) bounds() image.Rectangle {
+	return image.Rect(0, 0, b.w, b.h)
+}
+
+// near reports whether b has ink at (x, y) or any of its eight neighbours.
+func (b *bitmap) near(x, y int) bool {
+	for ny := max(0, y-1); ny <= min(b.h-1, y+1); ny++ {
+		for nx := max(0, x-1); nx <= min(b.w-1, x+1); nx++ {
+			if b.bits[ny*b.w+nx] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// glyphTemplate is the bitmap of a character, cropped to its ink, with the offset of its
+// bottom edge from the baseline (positive below it, as for descenders).
+type glyphTemplate struct {
+	r      rune
+	bitmap *bitmap
+	bottom int
+}
+
+// OCR recognises text in images by matching glyphs against bitmap font templates. It works
+// best on the crisp, horizontal UI text of screenshots, in the fonts and sizes it has
+// templates for. Use DefaultOCR for the templates of common Windows UI fonts, and AddFace
+// or Train to add others. An OCR is safe for concurrent use.
+type OCR struct {
+	mu        sync.RWMutex
+	templates []glyphTemplate
+	minScore  float64
+}
+
+// OCROption configures an OCR.
+type OCROption func(*OCR)
+
+// WithMinScore sets the similarity, from 0 to 1, a glyph must reach with its best template
+// to be recognised. Glyphs below it are returned as '?'. The default is 0.6.
+func WithMinScore(score float64) OCROption {
+	return func(o *OCR) {
+		o.minScore = score
+	}
+}
+
+// NewOCR creates an OCR without any templates.
+func NewOCR(opts ...OCROption) *OCR {
+	o := &OCR{minScore: 0.6}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// AddFace renders each character of chars with face and adds them as templates.
+func (o *OCR) AddFace(face font.Face, chars string) {
+	metrics := face.Metrics()
+	ascent := metrics.Ascent.Ceil()
+	height := ascent + metrics.Descent.Ceil()
+
+	var templates []glyphTemplate
+	for _, r := range chars {
+		advance, ok := face.GlyphAdvance(r)
+		if !ok || r == ' ' {
+			continue
+		}
+		// Leave a margin for glyphs that overhang their advance
+		width := advance.Ceil() + height
+		dst := image.NewAlpha(image.Rect(0, 0, width, height))
+		d := font.Drawer{Dst: dst, Src: image.Opaque, Face: face, Dot: fixed.P(height/2, ascent)}
+		d.DrawString(string(r))
+
+		// Threshold the anti-aliased rendering the same way as the images being read
+		bm := binarize(dst)
+		ink := inkBounds(bm, bm.bounds())
+		if ink.Empty() {
+			continue
+		}
+		templates = append(templates, glyphTemplate{r: r, bitmap: bm.crop(ink), bottom: ink.Max.Y - ascent})
+	}
+
+	o.mu.Lock()
+	o.templates = append(o.templates, templates...)
+	o.mu.Unlock()
+}
+
+// Train adds templates from an image of known text, e.g. a capture of a label in a font
+// the OCR does not recognise yet. text must list the characters in the image in reading
+// order; whitespace is ignored. Characters that touch each other cannot be separated, so
+// it returns an error if the number of glyphs found differs from the number of characters.
+func (o *OCR) Train(img image.Image, text string) error {
+	chars := []rune(strings.Join(strings.Fields(text), ""))
+	var glyphs []glyph
+	for _, line := range segmentLines(binarize(img)) {
+		glyphs = append(glyphs, line.glyphs...)
+	}
+	if len(glyphs) != len(chars) {
+		return fmt.Errorf("found %d glyphs in the image for %d characters", len(glyphs), len(chars))
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, g := range glyphs {
+		o.templates = append(o.templates, glyphTemplate{r: chars[i], bitmap: g.bitmap, bottom: g.bottom})
+	}
+	return nil
+}
+
+// TextWord is a word recognised by OCR.
+type TextWord struct {
+	Text       string
+	Bounds     image.Rectangle // in the coordinates of the recognised image
+	Confidence float64         // the lowest similarity of any glyph of the word to its template, 0 to 1
+}
+
+// TextLine is a line of text recognised by OCR.
+type TextLine struct {
+	Text   string // the words separated by single spaces
+	Bounds image.Rectangle
+	Words  []TextWord
+
+	chars []textChar // every character, including spaces, for searching
+}
+
+// textChar is a recognised character; spaces have empty bounds.
+type textChar struct {
+	r      rune
+	bounds image.Rectangle
+}
+
+// Recognize reads the text in img, line by line from top to bottom. img is binarised
+// automatically, for dark text on a light background or light text on a dark one.
+func (o *OCR) Recognize(img image.Image) ([]TextLine, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if len(o.templates) == 0 {
+		return nil, errors.New("OCR has no templates")
+	}
+
+	origin := img.Bounds().Min
+	var lines []TextLine
+	for _, seg := range segmentLines(binarize(img)) {
+		var line TextLine
+		var word TextWord
+		var chars []textChar
+		flush := func() {
+			if word.Text == "" {
+				return
+			}
+			if len(line.Words) > 0 {
+				line.Text += " "
+				line.chars = append(line.chars, textChar{r: ' '})
+			}
+			line.Text += word.Text
+			line.chars = append(line.chars, chars...)
+			line.Words = append(line.Words, word)
+			line.Bounds = line.Bounds.Union(word.Bounds)
+			word, chars = TextWord{}, chars[:0]
+		}
+		for i, g := range seg.glyphs {
+			if i > 0 && g.bounds.Min.X-seg.glyphs[i-1].bounds.Max.X >= seg.spaceGap {
+				flush()
+			}
+			for _, c := range o.recognizeGlyph(g, seg) {
+				bounds := c.bounds.Add(origin)
+				if word.Text == "" {
+					word.Confidence = c.score
+				}
+				word.Text += string(c.r)
+				word.Bounds = word.Bounds.Union(bounds)
+				word.Confidence = min(word.Confidence, c.score)
+				chars = append(chars, textChar{r: c.r, bounds: bounds})
+			}
+		}
+		flush()
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// ReadText returns the text in img, one line per recognised line of text.
+func (o *OCR) ReadText(img image.Image) (string, error) {
+	lines, err := o.Recognize(img)
+	if err != nil {
+		return "", err
+	}
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.Text
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// FindText returns the bounding box, in img's coordinates, of the first occurrence of text
+// in img. Runs of whitespace in text match the gap between words. The text must be on a
+// single line.
+func (o *OCR) FindText(img image.Image, text string) (image.Rectangle, error) {
+	want := []rune(strings.Join(strings.Fields(text), " "))
+	if len(want) == 0 {
+		return image.Rectangle{}, errors.New("empty search text")
+	}
+	lines, err := o.Recognize(img)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	for _, line := range lines {
+		for start := 0; start+len(want) <= len(line.chars); start++ {
+			var box image.Rectangle
+			found := true
+			for i, r := range want {
+				c := line.chars[start+i]
+				if c.r != r {
+					found = false
+					break
+				}
+				box = box.Union(c.bounds)
+			}
+			if found {
+				return box, nil
+			}
+		}
+	}
+	return image.Rectangle{}, ErrTextNotFound
+}
+
+// recognized is a character recognised from a glyph or part of one.
+type recognized struct {
+	r      rune
+	score  float64
+	bounds image.Rectangle // in the coordinates of the binarised image
+}
+
+// recognizeGlyph recognises the characters of g. Characters that touch, as anti-aliased
+// text often does, form a single glyph, so g is also tried split into pieces that each
+// match a template.
+func (o *OCR) recognizeGlyph(g glyph, line textSegment) []recognized {
+	r, score := o.match(g, line)
+	whole := []recognized{{r: r, score: score, bounds: g.bounds}}
+	// Prefer the split only if it is clearly better, so 'm' is not read as "rn"
+	if pieces, avg := o.split(g, line); len(pieces) > 1 && avg > score+0.05 {
+		return pieces
+	}
+	return whole
+}
+
+// split divides g into pieces at columns with little ink, choosing the division whose
+// pieces have the highest width-weighted template score less a cost per piece, and
+// returns the pieces and their average score after the cost of the extra pieces.
+func (o *OCR) split(g glyph, line textSegment) ([]recognized, float64) {
+	w, h := g.bitmap.w, g.bitmap.h
+	// Touching characters are joined by bridges thinner than their strokes, so only cut
+	// at columns with at most two ink pixels and less ink than the fullest column
+	columns := make([]int, w)
+	for x := range columns {
+		for y := 0; y < h; y++ {
+			if g.bitmap.at(x, y) {
+				columns[x]++
+			}
+		}
+	}
+	fullest := slices.Max(columns)
+	cuts := []int{0}
+	for x := 1; x < w; x++ {
+		if columns[x] <= 2 && columns[x] < fullest {
+			cuts = append(cuts, x)
+		}
+	}
+	cuts = append(cuts, w)
+	if len(cuts) == 2 {
+		return nil, 0
+	}
+
+	type step struct {
+		total float64 // sum of score * width of the pieces up to this cut
+		prev  int     // index of the previous cut, or -1 if unreachable
+		piece recognized
+	}
+	best := make([]step, len(cuts))
+	for i := range best {
+		best[i].prev = -1
+	}
+	best[0].prev = 0
+	maxWidth := max(line.height, 2)
+	// Every piece costs a little, or any stroke would split into dots matching '.'
+	pieceCost := 0.05 * float64(line.height)
+	for j := 1; j < len(cuts); j++ {
+		for i := j - 1; i >= 0 && cuts[j]-cuts[i] <= maxWidth; i-- {
+			if best[i].prev < 0 {
+				continue
+			}
+			piece, ok := o.matchColumns(g, cuts[i], cuts[j], line)
+			if !ok {
+				continue
+			}
+			total := best[i].total + piece.score*float64(cuts[j]-cuts[i]) - pieceCost
+			if best[j].prev < 0 || total > best[j].total {
+				best[j] = step{total: total, prev: i, piece: piece}
+			}
+		}
+	}
+
+	last := len(cuts) - 1
+	if best[last].prev < 0 {
+		return nil, 0
+	}
+	var pieces []recognized
+	for j := last; j > 0; j = best[j].prev {
+		pieces = append(pieces, best[j].piece)
+	}
+	slices.Reverse(pieces)
+	return pieces, (best[last].total + pieceCost) / float64(w)
+}
+
+// matchColumns recognises the ink of g between columns x0 and x1. It reports false if
+// there is too little ink there to be a character.
+func (o *OCR) matchColumns(g glyph, x0, x1 int, line textSegment) (recognized, bool) {
+	ink := inkBounds(g.bitmap, image.Rect(x0, 0, x1, g.bitmap.h))
+	// A sliver cut off a stroke would match '.' perfectly
+	if ink.Dx()*ink.Dy() < 3 {
+		return recognized{}, false
+	}
+	bounds := ink.Add(g.bounds.Min)
+	piece := glyph{bounds: bounds, bitmap: g.bitmap.crop(ink), bottom: bounds.Max.Y - line.baseline}
+	r, score := o.match(piece, line)
+	return recognized{r: r, score: score, bounds: bounds}, true
+}
+
+// match returns the template character most similar to g and its score.
+func (o *OCR) match(g glyph, line textSegment) (rune, float64) {
+	best, bestScore := '?', 0.0
+	scaled := make([]bool, g.bitmap.w*g.bitmap.h)
+	for i := range o.templates {
+		if s := templateScore(g, &o.templates[i], line, scaled); s > bestScore {
+			best, bestScore = o.templates[i].r, s
+		}
+	}
+	if bestScore < o.minScore {
+		return '?', bestScore
+	}
+	return best, bestScore
+}
+
+// templateScore returns how similar g is to t, from 0 to 1, comparing g with t scaled to
+// g's size. Rendering at sub-pixel offsets shifts edges by a pixel, so ink counts as
+// matched if the other bitmap has ink within one pixel of it; the exact overlap is mixed
+// in to separate similar shapes. The score is reduced for differences in size, aspect
+// ratio and position relative to the baseline. scaled is scratch space of g's size.
+func templateScore(g glyph, t *glyphTemplate, line textSegment, scaled []bool) float64 {
+	gw, gh := g.bitmap.w, g.bitmap.h
+	tw, th := t.bitmap.w, t.bitmap.h
+	aspect := float64(gw*th) / float64(tw*gh)
+	if aspect > 1 {
+		aspect = 1 / aspect
+	}
+	size := float64(min(gh, th)) / float64(max(gh, th))
+	if aspect < 0.5 || size < 0.6 {
+		return 0
+	}
+
+	for y := 0; y < gh; y++ {
+		ty := y * th / gh
+		for x := 0; x < gw; x++ {
+			scaled[y*gw+x] = t.bitmap.at(x*tw/gw, ty)
+		}
+	}
+	scaledT := &bitmap{w: gw, h: gh, bits: scaled}
+
+	var both, either, ink, hits int
+	for y := 0; y < gh; y++ {
+		for x := 0; x < gw; x++ {
+			a, b := g.bitmap.at(x, y), scaledT.at(x, y)
+			if a && b {
+				both++
+			}
+			if a || b {
+				either++
+			}
+			if a {
+				ink++
+				if scaledT.near(x, y) {
+					hits++
+				}
+			}
+			if b {
+				ink++
+				if g.bitmap.near(x, y) {
+					hits++
+				}
+			}
+		}
+	}
+	if either == 0 {
+		return 0
+	}
+	shape := 0.6*float64(hits)/float64(ink) + 0.4*float64(both)/float64(either)
+	score := shape * math.Sqrt(aspect) * (0.7 + 0.3*size)
+
+	// Tell apart glyphs of similar shape, such as ',' and '\'', by where they sit
+	expected := float64(t.bottom) * float64(gh) / float64(th)
+	if math.Abs(float64(g.bottom)-expected) > max(2, float64(line.height)/6) {
+		score *= 0.7
+	}
+	return score
+}
+
+// binarize converts img to a bitmap using Otsu's threshold on luminance. The minority
+// class is taken to be the ink, so both dark-on-light and light-on-dark text work.
+func binarize(img image.Image) *bitmap {
+	rgba := toRGBA(img)
+	w, h := rgba.Rect.Dx(), rgba.Rect.Dy()
+	lum := make([]uint8, w*h)
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x := 0; x < w; x++ {
+			r, g, b := int(row[x*4]), int(row[x*4+1]), int(row[x*4+2])
+			l := uint8((299*r + 587*g + 114*b) / 1000)
+			lum[y*w+x] = l
+			hist[l]++
+		}
+	}
+
+	// Otsu: pick the threshold that maximises the variance between the two classes
+	total := w * h
+	var sum float64
+	for i, n := range hist {
+		sum += float64(i * n)
+	}
+	var sumB float64
+	var weightB int
+	threshold, bestVar := 0, -1.0
+	for t := 0; t < 256; t++ {
+		weightB += hist[t]
+		if weightB == 0 {
+			continue
+		}
+		weightF := total - weightB
+		if weightF == 0 {
+			break
+		}
+		sumB += float64(t * hist[t])
+		meanB := sumB / float64(weightB)
+		meanF := (sum - sumB) / float64(weightF)
+		if v := float64(weightB) * float64(weightF) * (meanB - meanF) * (meanB - meanF); v > bestVar {
+			threshold, bestVar = t, v
+		}
+	}
+
+	dark := 0
+	for t := 0; t <= threshold; t++ {
+		dark += hist[t]
+	}
+	darkInk := dark*2 <= total
+
+	bm := &bitmap{w: w, h: h, bits: make([]bool, w*h)}
+	for i, l := range lum {
+		bm.bits[i] = (int(l) <= threshold) == darkInk
+	}
+	return bm
+}
+
+// glyph is a character-sized group of ink in a line of text.
+type glyph struct {
+	bounds image.Rectangle // in the coordinates of the binarised image
+	bitmap *bitmap         // the ink inside bounds
+	bottom int             // offset of the bottom edge from the line's baseline
+}
+
+// textSegment is a line of text found by segmentLines.
+type textSegment struct {
+	bounds   image.Rectangle
+	height   int
+	baseline int     // row below the bottom of glyphs that sit on the baseline
+	glyphs   []glyph // left to right
+	spaceGap int     // horizontal gap between glyphs that separates words
+}
+
+// segmentLines splits a bitmap into lines of text at the rows without ink, and each line
+// into glyphs: connected groups of ink, merged when they share columns, as the dot and
+// stem of an 'i' do.
+func segmentLines(bm *bitmap) []textSegment {
+	var lines []textSegment
+	for y := 0; y < bm.h; {
+		if !rowHasInk(bm, y) {
+			y++
+			continue
+		}
+		top := y
+		for y < bm.h && rowHasInk(bm, y) {
+			y++
+		}
+		strip := image.Rect(0, top, bm.w, y)
+		line := textSegment{bounds: strip, height: strip.Dy()}
+
+		groups, _ := components(bm.crop(strip).bits, bm.w, strip.Dy())
+		boxes := make([]image.Rectangle, 0, len(groups))
+		for _, c := range groups {
+			boxes = append(boxes, c.Bounds.Add(strip.Min))
+		}
+		boxes = mergeColumns(boxes, strip.Dy())
+
+		// Most glyphs sit on the baseline. Votes are weighted by height, so that in a short
+		// line punctuation such as '-' does not outvote the letters
+		bottoms := make(map[int]int)
+		for _, b := range boxes {
+			bottoms[b.Max.Y] += b.Dy()
+		}
+		baseline, most := strip.Max.Y, 0
+		for b, n := range bottoms {
+			if n > most || n == most && b < baseline {
+				baseline, most = b, n
+			}
+		}
+
+		line.baseline = baseline
+		for _, b := range boxes {
+			line.glyphs = append(line.glyphs, glyph{bounds: b, bitmap: bm.crop(b), bottom: b.Max.Y - baseline})
+		}
+		line.spaceGap = max(2, (line.height+3)/4)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// mergeColumns sorts boxes left to right and merges the small parts of characters, such
+// as the dot of an 'i' or the bars of '=', into the box they sit above or below: a box
+// less than half the line height tall that does not overlap another vertically but
+// shares at least half of its columns with it. Boxes inside another box, such as a bar of
+// '=' whose other bar touches the next character, are merged too.
+func mergeColumns(boxes []image.Rectangle, lineHeight int) []image.Rectangle {
+	slices.SortFunc(boxes, func(a, b image.Rectangle) int { return a.Min.X - b.Min.X })
+	var out []image.Rectangle
+	for _, b := range boxes {
+		if n := len(out); n > 0 {
+			last := out[n-1]
+			overlap := min(last.Max.X, b.Max.X) - max(last.Min.X, b.Min.X)
+			stacked := last.Max.Y <= b.Min.Y || b.Max.Y <= last.Min.Y
+			small := min(last.Dy(), b.Dy())*2 <= lineHeight
+			inside := b.In(last) || last.In(b)
+			if inside || stacked && small && overlap*2 >= min(last.Dx(), b.Dx()) {
+				out[n-1] = last.Union(b)
+				continue
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func rowHasInk(bm *bitmap, y int) bool {
+	return slices.Contains(bm.bits[y*bm.w:(y+1)*bm.w], true)
+}
+
+// inkBounds returns the smallest rectangle inside r containing all ink of b.
+func inkBounds(b *bitmap, r image.Rectangle) image.Rectangle {
+	var ink image.Rectangle
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if b.at(x, y) {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return ink
+}
+
+// ReadText returns the text in the region of the screen, recognised with DefaultOCR.
+func ReadText(region image.Rectangle) (string, error) {
+	img, err := CaptureRect(region)
+	if err != nil {
+		return "", err
+	}
+	return DefaultOCR().ReadText(img)
+}
+
+// FindText returns the bounding box, in screen coordinates, of the first occurrence of text
+// in the region of the screen, recognised with DefaultOCR. Click its centre to click the text.
+// It returns ErrTextNotFound if the text is not found.
+func FindText(region image.Rectangle, text string) (image.Rectangle, error) {
+	img, err := CaptureRect(region)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	box, err := DefaultOCR().FindText(img, text)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return box.Add(region.Min), nil
+}
@@ -0,0 +1,79 @@
+//go:build windows
+
+package windows
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zzl/go-win32api/v2/win32"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// OCRCharset is the set of characters DefaultOCR has templates for: printable ASCII.
+const OCRCharset = "!\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+
+// defaultOCRFonts are the font files in %WINDIR%\Fonts DefaultOCR renders templates from:
+// the Windows UI font and the fonts older dialogs and web content commonly use.
+var defaultOCRFonts = []string{"segoeui.ttf", "tahoma.ttf", "arial.ttf", "verdana.ttf"}
+
+// defaultOCRSizes are the point sizes of the templates, covering the standard UI sizes.
+var defaultOCRSizes = []float64{8, 9, 10, 11, 12}
+
+var defaultOCR struct {
+	once sync.Once
+	ocr  *OCR
+}
+
+// DefaultOCR returns the shared OCR used by ReadText and FindText. Its templates are
+// rendered on first use from the Windows UI fonts installed on the system, at the standard
+// UI sizes and the system DPI, so nothing has to be downloaded. If none of the fonts can
+// be loaded, the Go font bundled with the library is used instead. More templates can be
+// added to it with AddFace and Train.
+func DefaultOCR() *OCR {
+	defaultOCR.once.Do(func() {
+		o := NewOCR()
+		dpi := float64(win32.GetDpiForSystem())
+		if dpi == 0 {
+			dpi = 96
+		}
+
+		fontsDir := filepath.Join(os.Getenv("WINDIR"), "Fonts")
+		loaded := 0
+		for _, name := range defaultOCRFonts {
+			data, err := os.ReadFile(filepath.Join(fontsDir, name))
+			if err != nil {
+				continue
+			}
+			if addFontTemplates(o, data, dpi) == nil {
+				loaded++
+			}
+		}
+		if loaded == 0 {
+			addFontTemplates(o, goregular.TTF, dpi)
+		}
+		defaultOCR.ocr = o
+	})
+	return defaultOCR.ocr
+}
+
+// addFontTemplates adds templates for OCRCharset rendered from the TrueType or OpenType
+// font in data at each of the default sizes.
+func addFontTemplates(o *OCR, data []byte, dpi float64) error {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return err
+	}
+	for _, size := range defaultOCRSizes {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: dpi, Hinting: font.HintingFull})
+		if err != nil {
+			return err
+		}
+		o.AddFace(face, OCRCharset)
+		face.Close()
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// goRegularFace returns the Go regular font at size points and 96 DPI.
+func goRegularFace(t *testing.T, size float64) font.Face {
+	t.Helper()
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 96, Hinting: font.HintingFull})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { face.Close() })
+	return face
+}
+
+// renderText draws lines of dark text on a light background with face, one below the other.
+func renderText(face font.Face, lines ...string) *image.RGBA {
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil() * 3 / 2
+	width := 0
+	for _, line := range lines {
+		width = max(width, font.MeasureString(face, line).Ceil())
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width+20, lineHeight*len(lines)+20))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	d := font.Drawer{Dst: img, Src: image.Black, Face: face}
+	for i, line := range lines {
+		d.Dot = fixed.P(10, 10+metrics.Ascent.Ceil()+i*lineHeight)
+		d.DrawString(line)
+	}
+	return img
+}
+
+func TestOCRReadText(t *testing.T) {
+	face := goRegularFace(t, 12)
+	o := NewOCR()
+	o.AddFace(face, OCRCharset)
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"word", []string{"Settings"}, "Settings"},
+		{"words", []string{"Save changes"}, "Save changes"},
+		{"digits", []string{"Page 3 of 75"}, "Page 3 of 75"},
+		{"lines", []string{"File Edit", "View Help"}, "File Edit\nView Help"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := o.ReadText(renderText(face, tt.lines...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOCRFindText(t *testing.T) {
+	face := goRegularFace(t, 12)
+	o := NewOCR()
+	o.AddFace(face, OCRCharset)
+	img := renderText(face, "Open Save Close")
+
+	box, err := o.FindText(img, "Save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := 10 + font.MeasureString(face, "Open ").Ceil()
+	if box.Min.X < start-2 || box.Min.X > start+2 {
+		t.Errorf("FindText box %v, want it to start near x=%d", box, start)
+	}
+	if _, err := o.FindText(img, "Quit"); !errors.Is(err, ErrTextNotFound) {
+		t.Errorf("FindText(Quit) error = %v, want ErrTextNotFound", err)
+	}
+}
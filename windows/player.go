@@ -0,0 +1,573 @@
+//go:build windows
+
+package windows
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// ActionKind is the kind of an Action.
+type ActionKind string
+
+const (
+	ActionMove         ActionKind = "move"         // ActionMove moves the cursor to Point
+	ActionClick        ActionKind = "click"        // ActionClick clicks Button Clicks times at Point
+	ActionDrag         ActionKind = "drag"         // ActionDrag drags along Path, or from the cursor to Point
+	ActionScroll       ActionKind = "scroll"       // ActionScroll scrolls Amount notches at Point
+	ActionMouseDown    ActionKind = "mouseDown"    // ActionMouseDown presses Button at Point
+	ActionMouseUp      ActionKind = "mouseUp"      // ActionMouseUp releases Button at Point
+	ActionKey          ActionKind = "key"          // ActionKey presses and releases Key
+	ActionKeyDown      ActionKind = "keyDown"      // ActionKeyDown presses Key
+	ActionKeyUp        ActionKind = "keyUp"        // ActionKeyUp releases Key
+	ActionType         ActionKind = "type"         // ActionType types Text
+	ActionHotkey       ActionKind = "hotkey"       // ActionHotkey presses Keys in order and releases them in reverse
+	ActionWait         ActionKind = "wait"         // ActionWait pauses for Duration
+	ActionWaitForImage ActionKind = "waitForImage" // ActionWaitForImage waits up to Duration for Image to appear in Region
+	ActionScreenshot   ActionKind = "screenshot"   // ActionScreenshot captures Region, saving it to File if set
+)
+
+// Action is a single step of a script run by a Player. Only the fields used by its Kind
+// are read.
+type Action struct {
+	Kind ActionKind
+	Name string // optional label shown in the log and by the debugger
+
+	Point      image.Point     // cursor position for mouse actions, end point of a drag
+	Path       []image.Point   // drag: the points to drag through, from the first to the last
+	Button     MouseButton     // click, drag, mouseDown and mouseUp
+	Clicks     int             // click: number of clicks, 1 if 0
+	Amount     float64         // scroll: notches, positive away from the user or to the right
+	Horizontal bool            // scroll: scroll horizontally
+	Key        KeyboardKeys    // key, keyDown and keyUp
+	Keys       []KeyboardKeys  // hotkey
+	Text       string          // type
+	Image      image.Image     // waitForImage: the image to wait for, see LocateInImage
+	Tolerance  uint8           // waitForImage: allowed difference per channel
+	Region     image.Rectangle // waitForImage and screenshot: screen region, the primary display if empty
+	File       string          // screenshot: PNG file to write
+
+	// Duration is the time a move, click or drag takes to glide to Point, the pause between
+	// keys of a hotkey or characters of a type, the length of a wait, or the timeout of a
+	// waitForImage (10s if 0). All but the timeout are divided by the player's speed.
+	Duration time.Duration
+
+	Retries    int  // overrides the player's WithRetries count if not 0; -1 disables retries
+	Breakpoint bool // pause in the debugger before running the action
+}
+
+// String method for better printing
+func (a Action) String() string {
+	var s string
+	switch a.Kind {
+	case ActionMove, ActionMouseDown, ActionMouseUp:
+		s = fmt.Sprintf("%s %v", a.Kind, a.Point)
+		if a.Kind != ActionMove {
+			s = fmt.Sprintf("%s %v at %v", a.Kind, a.Button, a.Point)
+		}
+	case ActionClick:
+		s = fmt.Sprintf("click %v x%d at %v", a.Button, max(1, a.Clicks), a.Point)
+	case ActionDrag:
+		if len(a.Path) >= 2 {
+			s = fmt.Sprintf("drag %v through %v", a.Button, a.Path)
+		} else {
+			s = fmt.Sprintf("drag %v to %v", a.Button, a.Point)
+		}
+	case ActionScroll:
+		s = fmt.Sprintf("scroll %g at %v", a.Amount, a.Point)
+		if a.Horizontal {
+			s = "h" + s
+		}
+	case ActionKey, ActionKeyDown, ActionKeyUp:
+		s = fmt.Sprintf("%s 0x%02X", a.Kind, int(a.Key))
+	case ActionHotkey:
+		s = fmt.Sprintf("hotkey %v", a.Keys)
+	case ActionType:
+		s = fmt.Sprintf("type %q", a.Text)
+	case ActionWait:
+		s = fmt.Sprintf("wait %v", a.Duration)
+	case ActionWaitForImage:
+		s = fmt.Sprintf("waitForImage in %v", a.Region)
+	case ActionScreenshot:
+		s = fmt.Sprintf("screenshot %v %s", a.Region, a.File)
+	default:
+		s = string(a.Kind)
+	}
+	if a.Name != "" {
+		s = a.Name + ": " + s
+	}
+	return strings.TrimSpace(s)
+}
+
+// ActionsFromRecording converts a recording into actions that replay it, with waits
+// reproducing the time between events.
+func ActionsFromRecording(rec *Recording) []Action {
+	var actions []Action
+	var last time.Duration
+	for _, e := range rec.Events {
+		if gap := e.Time - last; gap > 0 {
+			actions = append(actions, Action{Kind: ActionWait, Duration: gap})
+		}
+		last = e.Time
+		a := Action{Point: image.Pt(e.X, e.Y), Button: e.Button, Key: e.Key}
+		switch e.Kind {
+		case EventMouseMove:
+			a.Kind = ActionMove
+		case EventMouseDown:
+			a.Kind = ActionMouseDown
+		case EventMouseUp:
+			a.Kind = ActionMouseUp
+		case EventScroll, EventHScroll:
+			a.Kind = ActionScroll
+			a.Amount = float64(e.Delta) / float64(win32.WHEEL_DELTA)
+			a.Horizontal = e.Kind == EventHScroll
+		case EventKeyDown:
+			a.Kind = ActionKeyDown
+		case EventKeyUp:
+			a.Kind = ActionKeyUp
+		default:
+			continue
+		}
+		actions = append(actions, a)
+	}
+	return actions
+}
+
+var (
+	// ErrPlaybackAborted is returned by Player.Run when the debugger aborts playback.
+	ErrPlaybackAborted = errors.New("playback aborted")
+	// ErrWaitTimeout is wrapped by the error of a waitForImage action whose image did not
+	// appear in time, along with ErrImageNotFound.
+	ErrWaitTimeout = errors.New("timed out")
+)
+
+// StepError reports the action that stopped playback.
+type StepError struct {
+	Step   int // index of the action in the script
+	Action Action
+	Err    error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %d (%v): %v", e.Step, e.Action, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// StepLog records how an action ran. Logs are returned by Player.Run and can be encoded
+// as JSON.
+type StepLog struct {
+	Step     int           `json:"step"`
+	Kind     ActionKind    `json:"kind"`
+	Action   string        `json:"action"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"` // including retries
+	Attempts int           `json:"attempts"` // 0 if the action was skipped in the debugger
+	Skipped  bool          `json:"skipped,omitempty"`
+	Result   string        `json:"result,omitempty"` // e.g. where an image was found
+	Error    string        `json:"error,omitempty"`
+
+	Err        error       `json:"-"`
+	Screenshot *image.RGBA `json:"-"` // screenshot actions
+}
+
+// DebugCommand tells a Player how to continue after pausing in the debugger.
+type DebugCommand int
+
+const (
+	DebugStep     DebugCommand = iota // DebugStep runs the action and pauses before the next one
+	DebugContinue                     // DebugContinue runs until the next breakpoint
+	DebugSkip                         // DebugSkip skips the action and pauses before the next one
+	DebugAbort                        // DebugAbort stops playback with ErrPlaybackAborted
+)
+
+// DebugFunc is called when playback pauses before the action at index step, at a
+// breakpoint or in step mode. Playback waits for it to return.
+type DebugFunc func(step int, a Action) DebugCommand
+
+// ConsoleDebugger returns a DebugFunc that prints the paused action to out and reads a
+// command from in: s or an empty line to step, c to continue, k to skip and q to quit.
+// Reaching the end of in aborts playback.
+func ConsoleDebugger(in io.Reader, out io.Writer) DebugFunc {
+	scanner := bufio.NewScanner(in)
+	return func(step int, a Action) DebugCommand {
+		for {
+			fmt.Fprintf(out, "paused before step %d: %v\n[s]tep, [c]ontinue, s[k]ip, [q]uit? ", step, a)
+			if !scanner.Scan() {
+				return DebugAbort
+			}
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "", "s", "step":
+				return DebugStep
+			case "c", "continue":
+				return DebugContinue
+			case "k", "skip":
+				return DebugSkip
+			case "q", "quit":
+				return DebugAbort
+			}
+		}
+	}
+}
+
+// The range of speeds accepted by WithSpeed.
+const (
+	MinPlaybackSpeed = 0.5
+	MaxPlaybackSpeed = 10
+)
+
+// PlayerOption configures a Player.
+type PlayerOption func(*Player)
+
+// WithSpeed scales playback: waits, glides and typing intervals are divided by speed. It is
+// clamped to MinPlaybackSpeed to MaxPlaybackSpeed. The default is 1. Timeouts are not
+// scaled, since they depend on the application rather than the script.
+func WithSpeed(speed float64) PlayerOption {
+	return func(p *Player) {
+		p.speed = min(max(speed, MinPlaybackSpeed), MaxPlaybackSpeed)
+	}
+}
+
+// WithSmoothing makes moves, clicks and drags that do not set a Duration glide over
+// duration with EaseInOutQuad instead of jumping, so playback looks like a user moving the
+// mouse and hover effects along the way are triggered.
+func WithSmoothing(duration time.Duration) PlayerOption {
+	return func(p *Player) {
+		p.smoothing = duration
+	}
+}
+
+// WithRetries retries a failed action up to retries times, pausing delay before each retry,
+// e.g. for a waitForImage on a slow application. Actions can override the count with their
+// Retries field. Actions that fail part-way, such as a type, are repeated in full.
+func WithRetries(retries int, delay time.Duration) PlayerOption {
+	return func(p *Player) {
+		p.retries, p.retryDelay = retries, delay
+	}
+}
+
+// WithBreakpoints pauses in the debugger before the actions at the given indexes, in
+// addition to actions with Breakpoint set.
+func WithBreakpoints(steps ...int) PlayerOption {
+	return func(p *Player) {
+		p.breakpoints = append(p.breakpoints, steps...)
+	}
+}
+
+// WithStepMode pauses in the debugger before every action.
+func WithStepMode() PlayerOption {
+	return func(p *Player) {
+		p.stepMode = true
+	}
+}
+
+// WithDebugger sets the function called when playback pauses. The default is a
+// ConsoleDebugger on the standard input and error.
+func WithDebugger(fn DebugFunc) PlayerOption {
+	return func(p *Player) {
+		p.debugger = fn
+	}
+}
+
+// WithFailSafe stops playback with ErrFailSafe before any action, or retry, that starts
+// while the cursor is in a corner of the primary display (see FailSafeTriggered).
+func WithFailSafe() PlayerOption {
+	return func(p *Player) {
+		p.failSafe = true
+	}
+}
+
+// WithStepLog calls fn with the log of each action as soon as it has run, for progress
+// reporting while Run is in progress.
+func WithStepLog(fn func(StepLog)) PlayerOption {
+	return func(p *Player) {
+		p.onStep = fn
+	}
+}
+
+// Player runs scripts of actions, hand-written or converted from a recording with
+// ActionsFromRecording, against the mouse, keyboard and screen functions of this package.
+type Player struct {
+	speed       float64
+	smoothing   time.Duration
+	retries     int
+	retryDelay  time.Duration
+	breakpoints []int
+	stepMode    bool
+	debugger    DebugFunc
+	onStep      func(StepLog)
+	failSafe    bool
+}
+
+// NewPlayer creates a Player.
+func NewPlayer(opts ...PlayerOption) *Player {
+	p := &Player{speed: 1}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.debugger == nil {
+		p.debugger = ConsoleDebugger(os.Stdin, os.Stderr)
+	}
+	return p
+}
+
+// FailSafe reports whether the player was created with WithFailSafe.
+func (p *Player) FailSafe() bool {
+	return p.failSafe
+}
+
+// playback is the state of one Run.
+type playback struct {
+	*Player
+	stepping bool
+	keys     []KeyboardKeys // held down by keyDown actions
+	buttons  []MouseButton  // held down by mouseDown actions
+}
+
+// Run runs actions in order and returns the log of each action that ran or was skipped.
+// It stops at the first action that still fails after its retries, returning a *StepError,
+// or when ctx is cancelled. Keys and mouse buttons pressed by keyDown and mouseDown actions
+// and not yet released are released when Run returns.
+func (p *Player) Run(ctx context.Context, actions []Action) ([]StepLog, error) {
+	pb := &playback{Player: p, stepping: p.stepMode}
+	defer pb.release()
+
+	var logs []StepLog
+	for i, a := range actions {
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
+		if pb.stepping || a.Breakpoint || slices.Contains(p.breakpoints, i) {
+			switch p.debugger(i, a) {
+			case DebugStep:
+				pb.stepping = true
+			case DebugContinue:
+				pb.stepping = false
+			case DebugSkip:
+				pb.stepping = true
+				log := StepLog{Step: i, Kind: a.Kind, Action: a.String(), Started: time.Now(), Skipped: true}
+				logs = append(logs, log)
+				if p.onStep != nil {
+					p.onStep(log)
+				}
+				continue
+			case DebugAbort:
+				return logs, ErrPlaybackAborted
+			}
+		}
+
+		log := pb.runStep(ctx, i, a)
+		logs = append(logs, log)
+		if p.onStep != nil {
+			p.onStep(log)
+		}
+		if log.Err != nil {
+			return logs, &StepError{Step: i, Action: a, Err: log.Err}
+		}
+	}
+	return logs, nil
+}
+
+// runStep runs a, retrying it if it fails.
+func (pb *playback) runStep(ctx context.Context, step int, a Action) StepLog {
+	log := StepLog{Step: step, Kind: a.Kind, Action: a.String(), Started: time.Now()}
+	retries := pb.retries
+	if a.Retries != 0 {
+		retries = max(0, a.Retries)
+	}
+	for {
+		if pb.failSafe && FailSafeTriggered() {
+			log.Err = ErrFailSafe
+			break
+		}
+		log.Attempts++
+		log.Result, log.Screenshot, log.Err = pb.perform(ctx, a)
+		if log.Err == nil || log.Attempts > retries || ctx.Err() != nil {
+			break
+		}
+		if err := sleepCtx(ctx, pb.retryDelay); err != nil {
+			break
+		}
+	}
+	log.Duration = time.Since(log.Started)
+	if log.Err != nil {
+		log.Error = log.Err.Error()
+	}
+	return log
+}
+
+// scale divides a script duration by the playback speed.
+func (pb *playback) scale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / pb.speed)
+}
+
+// glide returns how long a mouse action takes to reach its point.
+func (pb *playback) glide(a Action) time.Duration {
+	if a.Duration > 0 {
+		return pb.scale(a.Duration)
+	}
+	return pb.scale(pb.smoothing)
+}
+
+// perform runs a once, returning a description of its result and, for screenshots, the
+// captured image.
+func (pb *playback) perform(ctx context.Context, a Action) (string, *image.RGBA, error) {
+	switch a.Kind {
+	case ActionMove:
+		cur := Position()
+		return "", nil, tweenCursor(ctx, cur.X, cur.Y, a.Point.X, a.Point.Y, pb.glide(a), EaseInOutQuad)
+
+	case ActionClick:
+		cur := Position()
+		if err := tweenCursor(ctx, cur.X, cur.Y, a.Point.X, a.Point.Y, pb.glide(a), EaseInOutQuad); err != nil {
+			return "", nil, err
+		}
+		return "", nil, Click(a.Point.X, a.Point.Y, WithButton(a.Button), WithClicks(max(1, a.Clicks)))
+
+	case ActionDrag:
+		points := a.Path
+		if len(points) < 2 {
+			cur := Position()
+			points = []image.Point{image.Pt(cur.X, cur.Y), a.Point}
+		}
+		return "", nil, DragPathCtx(ctx, points, pb.glide(a), WithButton(a.Button), WithTween(EaseInOutQuad))
+
+	case ActionScroll:
+		delta := int(math.Round(a.Amount * float64(win32.WHEEL_DELTA)))
+		if a.Horizontal {
+			HorizontalScrollRaw(a.Point.X, a.Point.Y, delta)
+		} else {
+			ScrollRaw(a.Point.X, a.Point.Y, delta)
+		}
+		return "", nil, nil
+
+	case ActionMouseDown:
+		SetCursorPosition(a.Point.X, a.Point.Y)
+		if _, err := MouseDown(a.Button, a.Point.X, a.Point.Y); err != nil {
+			return "", nil, err
+		}
+		pb.buttons = append(pb.buttons, a.Button)
+		return "", nil, nil
+
+	case ActionMouseUp:
+		SetCursorPosition(a.Point.X, a.Point.Y)
+		if _, err := MouseUp(a.Button, a.Point.X, a.Point.Y); err != nil {
+			return "", nil, err
+		}
+		if i := slices.Index(pb.buttons, a.Button); i >= 0 {
+			pb.buttons = slices.Delete(pb.buttons, i, i+1)
+		}
+		return "", nil, nil
+
+	case ActionKey:
+		return "", nil, VPressCtx(ctx, 1, 0, a.Key)
+
+	case ActionKeyDown:
+		if err := VKeyDown(a.Key); err != nil {
+			return "", nil, err
+		}
+		pb.keys = append(pb.keys, a.Key)
+		return "", nil, nil
+
+	case ActionKeyUp:
+		if err := VKeyUp(a.Key); err != nil {
+			return "", nil, err
+		}
+		if i := slices.Index(pb.keys, a.Key); i >= 0 {
+			pb.keys = slices.Delete(pb.keys, i, i+1)
+		}
+		return "", nil, nil
+
+	case ActionType:
+		// TypeWriteCtx takes its interval in milliseconds
+		interval := time.Duration(pb.scale(a.Duration).Milliseconds())
+		return "", nil, TypeWriteCtx(ctx, a.Text, interval)
+
+	case ActionHotkey:
+		return "", nil, HotKeyCtx(ctx, pb.scale(a.Duration), a.Keys...)
+
+	case ActionWait:
+		return "", nil, sleepCtx(ctx, pb.scale(a.Duration))
+
+	case ActionWaitForImage:
+		r, err := waitForImage(ctx, a)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("found at %v", r), nil, nil
+
+	case ActionScreenshot:
+		region := regionOrScreen(a.Region)
+		img, err := CaptureRect(region)
+		if err != nil {
+			return "", nil, err
+		}
+		if a.File == "" {
+			return fmt.Sprintf("captured %v", region), img, nil
+		}
+		if err := SaveScreenshot(a.File, img, WithCaptureRect(region)); err != nil {
+			return "", img, err
+		}
+		return "saved " + a.File, img, nil
+	}
+	return "", nil, fmt.Errorf("unknown action kind %q", a.Kind)
+}
+
+// release lets go of keys and mouse buttons left held by the script.
+func (pb *playback) release() {
+	for i := len(pb.buttons) - 1; i >= 0; i-- {
+		cur := Position()
+		MouseUp(pb.buttons[i], cur.X, cur.Y)
+	}
+	releaseKeys(pb.keys, nil)
+	pb.buttons, pb.keys = nil, nil
+}
+
+// waitForImage polls the screen until a.Image appears in a.Region or the timeout expires.
+func waitForImage(ctx context.Context, a Action) (image.Rectangle, error) {
+	if a.Image == nil {
+		return image.Rectangle{}, errors.New("waitForImage has no image")
+	}
+	region := regionOrScreen(a.Region)
+	timeout := a.Duration
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+
+	const POLL_INTERVAL = 100 * time.Millisecond
+	for {
+		r, err := LocateOnScreen(region, a.Image, a.Tolerance)
+		if !errors.Is(err, ErrImageNotFound) {
+			return r, err
+		}
+		if time.Now().After(deadline) {
+			return image.Rectangle{}, fmt.Errorf("%w: %w after %v", ErrWaitTimeout, ErrImageNotFound, timeout)
+		}
+		if err := sleepCtx(ctx, POLL_INTERVAL); err != nil {
+			return image.Rectangle{}, err
+		}
+	}
+}
+
+// regionOrScreen returns region, or the primary display if it is empty, as Screenshot does.
+func regionOrScreen(region image.Rectangle) image.Rectangle {
+	if region.Empty() {
+		screen := GetScreenDimensions()
+		return image.Rect(0, 0, screen.X, screen.Y)
+	}
+	return region
+}
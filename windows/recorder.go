@@ -0,0 +1,335 @@
+//go:build windows
+
+package windows
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// ErrRecorderRunning is returned by Recorder.Start when a recorder is already running.
+// Only one Recorder can record at a time.
+var ErrRecorderRunning = errors.New("a recorder is already running")
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithThumbnails captures a size x size screenshot centred on every mouse button press and
+// stores it in the event as a PNG, to show what was clicked when reviewing a recording.
+// The screenshot is taken just after the press, so it may already show its effect.
+func WithThumbnails(size int) RecorderOption {
+	return func(r *Recorder) {
+		r.thumbSize = size
+	}
+}
+
+// WithMoveInterval records at most one cursor move per interval, keeping the latest
+// position, to keep recordings small. The default is 15ms; 0 records every move.
+func WithMoveInterval(interval time.Duration) RecorderOption {
+	return func(r *Recorder) {
+		r.moveInterval = interval
+	}
+}
+
+// WithInjectedInput also records synthetic input, such as that sent by this package.
+// By default only input from real devices is recorded.
+func WithInjectedInput() RecorderOption {
+	return func(r *Recorder) {
+		r.injected = true
+	}
+}
+
+// Recorder records the user's mouse and keyboard input with low-level hooks
+// (WH_MOUSE_LL and WH_KEYBOARD_LL) into a Recording, which can be saved and replayed.
+// The hooks see input for every application, including keystrokes typed into password
+// fields, so treat recordings accordingly.
+//
+// Recording is only implemented for Windows. There is no X11 recorder using the XRecord
+// extension, because goautogui has no X11 backend to replay the events on.
+type Recorder struct {
+	thumbSize    int
+	moveInterval time.Duration
+	injected     bool
+
+	mu        sync.Mutex
+	rec       *Recording
+	startTick uint32        // GetTickCount when recording started; hook times are relative to it
+	lastMove  time.Duration // time of the last move event started within the move interval
+	threadID  uint32        // thread running the hooks' message loop
+	done      chan struct{} // closed when the hooks have been removed
+	thumbs    chan thumbRequest
+	thumbDone chan struct{}
+}
+
+// thumbRequest asks for a thumbnail of the screen around p for the event at index.
+type thumbRequest struct {
+	index int
+	p     image.Point
+}
+
+// NewRecorder creates a Recorder. Call Start to begin recording.
+func NewRecorder(opts ...RecorderOption) *Recorder {
+	r := &Recorder{moveInterval: 15 * time.Millisecond}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// activeRecorder is the recorder the hook procedures deliver events to.
+var activeRecorder atomic.Pointer[Recorder]
+
+// hookProcs holds the hook procedures, created once because syscall.NewCallback callbacks
+// are never freed and their number is limited.
+var hookProcs struct {
+	once     sync.Once
+	mouse    uintptr
+	keyboard uintptr
+}
+
+// Start installs the hooks and begins recording. It returns ErrRecorderRunning if any
+// recorder is already recording.
+func (r *Recorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done != nil {
+		return errors.New("recorder is already running")
+	}
+	if !activeRecorder.CompareAndSwap(nil, r) {
+		return ErrRecorderRunning
+	}
+
+	r.rec = &Recording{Started: time.Now(), Screen: virtualScreenRect()}
+	r.startTick = win32.GetTickCount()
+	r.lastMove = -r.moveInterval
+	if r.thumbSize > 0 {
+		r.thumbs = make(chan thumbRequest, 16)
+		r.thumbDone = make(chan struct{})
+		go r.captureThumbnails(r.thumbs, r.thumbDone)
+	}
+
+	ready := make(chan error, 1)
+	done := make(chan struct{})
+	go r.hookLoop(ready, done)
+	if err := <-ready; err != nil {
+		activeRecorder.Store(nil)
+		if r.thumbs != nil {
+			close(r.thumbs)
+			<-r.thumbDone
+			r.thumbs = nil
+		}
+		return err
+	}
+	r.done = done
+	return nil
+}
+
+// hookLoop installs the hooks on a dedicated thread and pumps its messages, which is what
+// makes Windows call the hook procedures, until Stop posts WM_QUIT.
+func (r *Recorder) hookLoop(ready chan<- error, done chan<- struct{}) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(done)
+
+	hookProcs.once.Do(func() {
+		hookProcs.mouse = syscall.NewCallback(mouseHookProc)
+		hookProcs.keyboard = syscall.NewCallback(keyboardHookProc)
+	})
+	hmod, _ := win32.GetModuleHandleW(nil)
+	mouseHook, winerr := win32.SetWindowsHookExW(win32.WH_MOUSE_LL, hookProcs.mouse, win32.HINSTANCE(hmod), 0)
+	if mouseHook == 0 {
+		ready <- fmt.Errorf("SetWindowsHookEx(WH_MOUSE_LL) failed, error=%d", winerr)
+		return
+	}
+	defer win32.UnhookWindowsHookEx(mouseHook)
+	keyboardHook, winerr := win32.SetWindowsHookExW(win32.WH_KEYBOARD_LL, hookProcs.keyboard, win32.HINSTANCE(hmod), 0)
+	if keyboardHook == 0 {
+		ready <- fmt.Errorf("SetWindowsHookEx(WH_KEYBOARD_LL) failed, error=%d", winerr)
+		return
+	}
+	defer win32.UnhookWindowsHookEx(keyboardHook)
+
+	r.threadID = win32.GetCurrentThreadId()
+	ready <- nil
+
+	var msg win32.MSG
+	for {
+		// 0 is WM_QUIT, -1 an error
+		if ret, _ := win32.GetMessageW(&msg, 0, 0, 0); ret == 0 || ret == -1 {
+			return
+		}
+	}
+}
+
+// Stop removes the hooks and returns the recording.
+func (r *Recorder) Stop() (*Recording, error) {
+	r.mu.Lock()
+	done, threadID := r.done, r.threadID
+	r.mu.Unlock()
+	if done == nil {
+		return nil, errors.New("recorder is not running")
+	}
+
+	// The hook procedures take r.mu, so it must not be held while they drain
+	win32.PostThreadMessageW(threadID, win32.WM_QUIT, 0, 0)
+	<-done
+	activeRecorder.CompareAndSwap(r, nil)
+	if r.thumbs != nil {
+		close(r.thumbs)
+		<-r.thumbDone
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done, r.thumbs = nil, nil
+	return r.rec, nil
+}
+
+// Events returns a copy of the events recorded so far.
+func (r *Recorder) Events() []RecordedEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rec == nil {
+		return nil
+	}
+	return append([]RecordedEvent(nil), r.rec.Events...)
+}
+
+// since converts a hook timestamp, in GetTickCount milliseconds, to the time since the
+// recording started.
+func (r *Recorder) since(tick uint32) time.Duration {
+	// The subtraction wraps correctly when the tick count does
+	return max(0, time.Duration(int32(tick-r.startTick))*time.Millisecond)
+}
+
+func mouseHookProc(code uintptr, wParam uintptr, info *win32.MSLLHOOKSTRUCT) uintptr {
+	if r := activeRecorder.Load(); r != nil && int32(code) >= 0 {
+		r.mouseEvent(uint32(wParam), info)
+	}
+	return uintptr(win32.CallNextHookEx(0, int32(code), win32.WPARAM(wParam), win32.LPARAM(unsafe.Pointer(info))))
+}
+
+func keyboardHookProc(code uintptr, wParam uintptr, info *win32.KBDLLHOOKSTRUCT) uintptr {
+	if r := activeRecorder.Load(); r != nil && int32(code) >= 0 {
+		r.keyboardEvent(uint32(wParam), info)
+	}
+	return uintptr(win32.CallNextHookEx(0, int32(code), win32.WPARAM(wParam), win32.LPARAM(unsafe.Pointer(info))))
+}
+
+func (r *Recorder) mouseEvent(msg uint32, info *win32.MSLLHOOKSTRUCT) {
+	if info.Flags&win32.LLMHF_INJECTED != 0 && !r.injected {
+		return
+	}
+	e := RecordedEvent{Time: r.since(info.Time), X: int(info.Pt.X), Y: int(info.Pt.Y)}
+	// For the X buttons and the wheel, the high word of MouseData holds the button or delta
+	high := uint16(info.MouseData >> 16)
+	xButton := MouseX1Button
+	if high == win32.XBUTTON2 {
+		xButton = MouseX2Button
+	}
+	switch msg {
+	case win32.WM_MOUSEMOVE:
+		e.Kind = EventMouseMove
+	case win32.WM_LBUTTONDOWN:
+		e.Kind, e.Button = EventMouseDown, MouseLeftButton
+	case win32.WM_LBUTTONUP:
+		e.Kind, e.Button = EventMouseUp, MouseLeftButton
+	case win32.WM_RBUTTONDOWN:
+		e.Kind, e.Button = EventMouseDown, MouseRightButton
+	case win32.WM_RBUTTONUP:
+		e.Kind, e.Button = EventMouseUp, MouseRightButton
+	case win32.WM_MBUTTONDOWN:
+		e.Kind, e.Button = EventMouseDown, MouseMiddleButton
+	case win32.WM_MBUTTONUP:
+		e.Kind, e.Button = EventMouseUp, MouseMiddleButton
+	case win32.WM_XBUTTONDOWN:
+		e.Kind, e.Button = EventMouseDown, xButton
+	case win32.WM_XBUTTONUP:
+		e.Kind, e.Button = EventMouseUp, xButton
+	case win32.WM_MOUSEWHEEL:
+		e.Kind, e.Delta = EventScroll, int(int16(high))
+	case win32.WM_MOUSEHWHEEL:
+		e.Kind, e.Delta = EventHScroll, int(int16(high))
+	default:
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.rec.Events
+	if e.Kind == EventMouseMove {
+		// Within the interval, replace the last move rather than adding another
+		if n := len(events); n > 0 && events[n-1].Kind == EventMouseMove && e.Time-r.lastMove < r.moveInterval {
+			events[n-1] = e
+			return
+		}
+		r.lastMove = e.Time
+	}
+	r.rec.Events = append(events, e)
+
+	if e.Kind == EventMouseDown && r.thumbs != nil {
+		select {
+		case r.thumbs <- thumbRequest{index: len(r.rec.Events) - 1, p: image.Pt(e.X, e.Y)}:
+		default:
+			// Clicking faster than thumbnails are captured; skip this one rather than
+			// delay the user's input
+		}
+	}
+}
+
+func (r *Recorder) keyboardEvent(msg uint32, info *win32.KBDLLHOOKSTRUCT) {
+	if info.Flags&win32.LLKHF_INJECTED != 0 && !r.injected {
+		return
+	}
+	e := RecordedEvent{
+		Time:     r.since(info.Time),
+		Key:      KeyboardKeys(info.VkCode),
+		ScanCode: int(info.ScanCode),
+		Extended: info.Flags&win32.LLKHF_EXTENDED != 0,
+	}
+	switch msg {
+	case win32.WM_KEYDOWN, win32.WM_SYSKEYDOWN:
+		e.Kind = EventKeyDown
+	case win32.WM_KEYUP, win32.WM_SYSKEYUP:
+		e.Kind = EventKeyUp
+	default:
+		return
+	}
+	r.mu.Lock()
+	r.rec.Events = append(r.rec.Events, e)
+	r.mu.Unlock()
+}
+
+// captureThumbnails captures the requested thumbnails until requests is closed. It runs
+// outside the hook procedures, which must return quickly or Windows drops the hooks.
+func (r *Recorder) captureThumbnails(requests <-chan thumbRequest, done chan<- struct{}) {
+	defer close(done)
+	for req := range requests {
+		half := r.thumbSize / 2
+		rect := image.Rect(req.p.X-half, req.p.Y-half, req.p.X-half+r.thumbSize, req.p.Y-half+r.thumbSize)
+		img, err := CaptureRect(rect, WithClip(), WithCursor())
+		if err != nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			continue
+		}
+		r.mu.Lock()
+		if req.index < len(r.rec.Events) {
+			r.rec.Events[req.index].Thumbnail = buf.Bytes()
+		}
+		r.mu.Unlock()
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
+	"math"
+	"slices"
+	"sync"
 	"syscall"
 	"unsafe"
 
@@ -87,55 +91,161 @@ func OnScreen(x, y int) bool {
 		0 <= y && y < height
 }
 
-// GetDisplayBounds returns the bounds of the display at the specified index.
-// The index starts at 0 for the primary display.
-func GetDisplayBounds(displayIndex int) image.Rectangle {
-	var rects []win32.RECT
+// Pixel returns the color of the screen pixel at (x, y).
+func Pixel(x, y int) (color.RGBA, error) {
+	img, err := Capture(x, y, 1, 1)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	return img.RGBAAt(0, 0), nil
+}
 
-	monitorEnumProc := syscall.NewCallback(func(hMonitor win32.HMONITOR, hdcMonitor win32.HDC, lprcMonitor *win32.RECT, dwData uintptr) uintptr {
-		rects = append(rects, *lprcMonitor)
-		return 1 // continue enumeration
+// PixelMatchesColor reports whether the screen pixel at (x, y) matches c, allowing each
+// of the red, green and blue channels to differ by up to tolerance.
+func PixelMatchesColor(x, y int, c color.Color, tolerance uint8) (bool, error) {
+	px, err := Pixel(x, y)
+	if err != nil {
+		return false, err
+	}
+	want := color.RGBAModel.Convert(c).(color.RGBA)
+	return pixelMatches([]byte{px.R, px.G, px.B, px.A}, want, tolerance), nil
+}
+
+// monitorEnum collects monitor rectangles during EnumDisplayMonitors. The callback is
+// created once because syscall.NewCallback callbacks are never freed and their number is limited.
+var monitorEnum struct {
+	sync.Mutex
+	once  sync.Once
+	proc  uintptr
+	rects []image.Rectangle
+}
+
+// GetAllDisplayBounds returns the bounds of every display in virtual desktop coordinates,
+// in enumeration order (index 0 is the primary display).
+func GetAllDisplayBounds() []image.Rectangle {
+	monitorEnum.once.Do(func() {
+		monitorEnum.proc = syscall.NewCallback(func(hMonitor win32.HMONITOR, hdcMonitor win32.HDC, lprcMonitor *win32.RECT, dwData uintptr) uintptr {
+			r := *lprcMonitor
+			monitorEnum.rects = append(monitorEnum.rects, image.Rect(
+				int(r.Left), int(r.Top),
+				int(r.Right), int(r.Bottom)))
+			return 1 // continue enumeration
+		})
 	})
 
+	monitorEnum.Lock()
+	defer monitorEnum.Unlock()
+	monitorEnum.rects = nil
 	win32.EnumDisplayMonitors(
 		win32.HDC(0),
 		nil,
-		monitorEnumProc,
+		monitorEnum.proc,
 		0,
 	)
+	return monitorEnum.rects
+}
 
+// GetDisplayBounds returns the bounds of the display at the specified index.
+// The index starts at 0 for the primary display.
+func GetDisplayBounds(displayIndex int) image.Rectangle {
+	rects := GetAllDisplayBounds()
 	if displayIndex < 0 || displayIndex >= len(rects) {
 		return image.Rectangle{}
 	}
-	r := rects[displayIndex]
-	return image.Rect(
-		int(r.Left), int(r.Top),
-		int(r.Right), int(r.Bottom))
+	return rects[displayIndex]
 }
 
-// createImage creates an image.RGBA with the specified rectangle dimensions.
-func createImage(rect image.Rectangle) (img *image.RGBA, e error) {
-	img = nil
-	e = errors.New("cannot create image")
-
-	// Ensure the rectangle is valid and not too large.
-	defer func() {
-		err := recover()
-		if err == nil {
-			e = nil
-		}
-	}()
+var (
+	// ErrEmptyRegion is returned when a capture region has no area, e.g. a zero or negative
+	// width or height.
+	ErrEmptyRegion = errors.New("capture region is empty")
+	// ErrRegionOffScreen matches, with errors.Is, the *RegionOffScreenError returned when a
+	// capture region is not covered by the monitors.
+	ErrRegionOffScreen = errors.New("capture region is off-screen")
+)
 
-	// image.NewRGBA may panic if rect is too large.
-	img = image.NewRGBA(rect)
+// RegionOffScreenError is returned when a capture region is not entirely covered by the
+// monitors of the virtual desktop, or, with WithClip, does not overlap any of them.
+type RegionOffScreenError struct {
+	Rect image.Rectangle // the requested region, in screen coordinates
+}
 
-	return img, e
+func (e *RegionOffScreenError) Error() string {
+	return fmt.Sprintf("capture region %v is off-screen", e.Rect)
+}
+
+// Is makes errors.Is(err, ErrRegionOffScreen) report true.
+func (e *RegionOffScreenError) Is(target error) bool {
+	return target == ErrRegionOffScreen
+}
+
+// WithClip clips the capture region to the area covered by the monitors instead of failing
+// when part of it is off-screen. The returned image keeps the requested region's origin:
+// its Bounds() are the clipped area relative to (x, y), so they do not start at (0, 0)
+// when the left or top edge was clipped.
+func WithClip() CaptureOption {
+	return func(c *captureConfig) {
+		c.clip = true
+	}
+}
+
+// validateRegion checks rect, in screen coordinates, against the union of the monitor
+// rectangles. It returns the area to capture: rect itself, or with clip, the bounding box
+// of its visible parts.
+func validateRegion(rect image.Rectangle, clip bool) (image.Rectangle, error) {
+	if rect.Empty() {
+		return rect, ErrEmptyRegion
+	}
+	var covered int
+	var visible image.Rectangle
+	for _, m := range GetAllDisplayBounds() {
+		part := rect.Intersect(m)
+		if part.Empty() {
+			continue
+		}
+		// Monitors do not overlap, so the covered areas add up
+		covered += part.Dx() * part.Dy()
+		visible = visible.Union(part)
+	}
+	if covered == rect.Dx()*rect.Dy() {
+		return rect, nil
+	}
+	if clip && !visible.Empty() {
+		return visible, nil
+	}
+	return rect, &RegionOffScreenError{Rect: rect}
+}
+
+// createImage creates an image.RGBA with the specified rectangle dimensions.
+func createImage(rect image.Rectangle) (*image.RGBA, error) {
+	w, h := rect.Dx(), rect.Dy()
+	if w <= 0 || h <= 0 {
+		return nil, ErrEmptyRegion
+	}
+	// image.NewRGBA panics if the pixel buffer size overflows an int
+	if w > math.MaxInt/4/h {
+		return nil, fmt.Errorf("capture region %v is too large", rect)
+	}
+	return image.NewRGBA(rect), nil
 }
 
 // Capture captures a screenshot of the specified area (x, y, width, height).
-func Capture(x, y, width, height int) (*image.RGBA, error) {
-	rect := image.Rect(0, 0, width, height)
-	img, err := createImage(rect)
+// The area must be covered by the monitors; it returns ErrEmptyRegion for a zero or
+// negative size and a *RegionOffScreenError if part of it is off-screen, unless WithClip
+// is passed. Pass WithCursor to include the mouse cursor.
+func Capture(x, y, width, height int, opts ...CaptureOption) (*image.RGBA, error) {
+	cfg := newCaptureConfig(opts)
+	if width <= 0 || height <= 0 {
+		return nil, ErrEmptyRegion
+	}
+	region := image.Rect(x, y, x+width, y+height)
+	area, err := validateRegion(region, cfg.clip)
+	if err != nil {
+		return nil, err
+	}
+	x, y, width, height = area.Min.X, area.Min.Y, area.Dx(), area.Dy()
+
+	img, err := createImage(area.Sub(region.Min))
 	if err != nil {
 		return nil, err
 	}
@@ -166,6 +276,9 @@ func Capture(x, y, width, height int) (*image.RGBA, error) {
 		code := win32.GetLastError()
 		return nil, fmt.Errorf("BitBlt failed, GetLastError=%d", code)
 	}
+	if cfg.cursor {
+		drawCursor(memDC, x, y)
+	}
 
 	var bih win32.BITMAPINFOHEADER
 
@@ -193,27 +306,114 @@ func Capture(x, y, width, height int) (*image.RGBA, error) {
 	}
 
 	buf := unsafe.Slice((*byte)(memptr), byteCount)
+	swizzleBGRA(img.Pix, buf)
+
+	return img, nil
+}
 
-	pix := img.Pix
-	for dst := 0; dst < byteCount; dst += 4 {
-		// RGBA ← buf[B,G,R,_]
-		pix[dst+0], pix[dst+1], pix[dst+2], pix[dst+3] =
-			buf[dst+2], buf[dst+1], buf[dst+0], 0xFF
+// ErrWindowMinimized is returned by CaptureWindow for a minimized window, which has
+// nothing to render.
+var ErrWindowMinimized = errors.New("window is minimized")
+
+// WithClientArea makes CaptureWindow capture only the client area of the window,
+// without the title bar, borders and menu.
+func WithClientArea() CaptureOption {
+	return func(c *captureConfig) {
+		c.clientArea = true
 	}
+}
 
-	return img, nil
+// WithoutShadow makes CaptureWindow capture only the visible frame of the window. On
+// Windows 10 and later GetWindowRect includes the invisible resize borders and the
+// DWM drop shadow, which otherwise show up as a margin around the capture.
+func WithoutShadow() CaptureOption {
+	return func(c *captureConfig) {
+		c.noShadow = true
+	}
 }
 
-// CaptureWindow captures the screenshot of the specified window handle (hwnd).
-// It uses the Win32 API to capture the window content, including non-client areas.
-func CaptureWindow(hwnd win32.HWND) (*image.RGBA, error) {
+var procDwmGetWindowAttribute = syscall.NewLazyDLL("dwmapi.dll").NewProc("DwmGetWindowAttribute")
+
+// DWMWA_EXTENDED_FRAME_BOUNDS
+const dwmwaExtendedFrameBounds = 9
+
+// extendedFrameBounds returns the window bounds without the DWM shadow, in screen coordinates.
+// It reports false when DWM is unavailable.
+func extendedFrameBounds(hwnd win32.HWND) (image.Rectangle, bool) {
+	if procDwmGetWindowAttribute.Find() != nil {
+		return image.Rectangle{}, false
+	}
+	var rc win32.RECT
+	hr, _, _ := procDwmGetWindowAttribute.Call(uintptr(hwnd), dwmwaExtendedFrameBounds, uintptr(unsafe.Pointer(&rc)), unsafe.Sizeof(rc))
+	if hr != 0 { // S_OK
+		return image.Rectangle{}, false
+	}
+	return image.Rect(int(rc.Left), int(rc.Top), int(rc.Right), int(rc.Bottom)), true
+}
+
+// windowCaptureRect returns the screen area CaptureWindow should return for hwnd, and the
+// full window rectangle PrintWindow renders.
+func windowCaptureRect(hwnd win32.HWND, cfg captureConfig) (target, window image.Rectangle, err error) {
 	var rc win32.RECT
-	ok, winerr := win32.GetWindowRect(hwnd, &rc)
-	if ok == 0 || winerr != win32.ERROR_SUCCESS {
-		return nil, fmt.Errorf("GetWindowRect failed")
+	if ok, _ := win32.GetWindowRect(hwnd, &rc); ok == 0 {
+		return target, window, fmt.Errorf("GetWindowRect failed")
+	}
+	window = image.Rect(int(rc.Left), int(rc.Top), int(rc.Right), int(rc.Bottom))
+	target = window
+
+	switch {
+	case cfg.clientArea:
+		var client win32.RECT
+		if ok, _ := win32.GetClientRect(hwnd, &client); ok == 0 {
+			return target, window, fmt.Errorf("GetClientRect failed")
+		}
+		var origin win32.POINT
+		if win32.ClientToScreen(hwnd, &origin) == 0 {
+			return target, window, fmt.Errorf("ClientToScreen failed")
+		}
+		topLeft := image.Pt(int(origin.X), int(origin.Y))
+		target = image.Rectangle{Min: topLeft, Max: topLeft.Add(image.Pt(int(client.Right), int(client.Bottom)))}
+	case cfg.noShadow:
+		if bounds, ok := extendedFrameBounds(hwnd); ok {
+			target = bounds
+		}
+	}
+	target = target.Intersect(window)
+	if target.Empty() {
+		return target, window, fmt.Errorf("window has an empty capture area")
+	}
+	return target, window, nil
+}
+
+// CaptureWindow captures the screenshot of the specified window handle (hwnd).
+// By default it captures the whole window, including non-client areas; use WithClientArea
+// or WithoutShadow to narrow it down. WithCursor draws the cursor if it is over the window.
+//
+// The window is rendered with PrintWindow, so it is captured correctly even when it is
+// covered by other windows. Some windows (e.g. hardware-accelerated ones) render blank
+// through PrintWindow; in that case the window's area is captured from the screen
+// instead, which does include any windows covering it and leaves out any part of the
+// window that is off-screen.
+//
+// The process is per-monitor DPI aware, so the capture is in physical pixels at the
+// window's native size regardless of the scaling of the monitor it is on.
+//
+// It returns ErrInvalidWindow if hwnd is not a window and ErrWindowMinimized if the window
+// is minimized.
+func CaptureWindow(hwnd win32.HWND, opts ...CaptureOption) (*image.RGBA, error) {
+	if err := validateHwnd(hwnd); err != nil {
+		return nil, err
+	}
+	if win32.IsIconic(hwnd) != 0 {
+		return nil, ErrWindowMinimized
+	}
+	cfg := newCaptureConfig(opts)
+	target, window, err := windowCaptureRect(hwnd, cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	w, h := int(rc.Right-rc.Left), int(rc.Bottom-rc.Top)
+	w, h := window.Dx(), window.Dy()
 
 	hdcScreen := win32.GetWindowDC(hwnd)
 	if hdcScreen == 0 {
@@ -255,47 +455,68 @@ func CaptureWindow(hwnd win32.HWND) (*image.RGBA, error) {
 	old := win32.SelectObject(hdcMem, win32.HGDIOBJ(hBitmap))
 	defer win32.SelectObject(hdcMem, old)
 
-	if win32.PrintWindow(hwnd, hdcMem, win32.PRINT_WINDOW_FLAGS(2)) == 0 { // win32.PRINT_WINDOW_FLAGS(2) is PW_RENDERFULLCONTENT
+	printed := win32.PrintWindow(hwnd, hdcMem, win32.PRINT_WINDOW_FLAGS(win32.PW_RENDERFULLCONTENT)) != 0
+	if !printed {
 		// Flags documented here: https://learn.microsoft.com/en-us/windows/win32/gdi/wm-printclient
 		// Without these flags, the window may not render correctly.
 		flags := win32.PRF_ERASEBKGND | win32.PRF_CHILDREN | win32.PRF_CLIENT | win32.PRF_NONCLIENT
+		// The WM_PRINT result is not meaningful; a blank bitmap is detected below instead
 		win32.SendMessage(hwnd, win32.WM_PRINT, uintptr(hdcMem), uintptr(flags))
 	}
+	win32.GdiFlush()
+
+	buf := unsafe.Slice((*byte)(bitsPtr), w*h*4)
+	off := target.Min.Sub(window.Min)
+	img := image.NewRGBA(image.Rect(0, 0, target.Dx(), target.Dy()))
+	if isBlankBGRA(buf, w, image.Rectangle{Min: off, Max: off.Add(target.Size())}) {
+		// Nothing was rendered; fall back to what is on the screen, clipped to the monitors
+		return CaptureRect(target, slices.Concat(opts, []CaptureOption{WithClip()})...)
+	}
 
-	byteCount := w * h * 4 // 4 bytes per pixel (RGBA)
-
-	buf := unsafe.Slice((*byte)(bitsPtr), byteCount)
-	img := image.NewRGBA(image.Rect(0, 0, w, h))
-
-	for dst := 0; dst < byteCount; dst += 4 {
-		// RGBA ← buf[B,G,R,_]
-		img.Pix[dst+0], img.Pix[dst+1], img.Pix[dst+2], img.Pix[dst+3] =
-			buf[dst+2], buf[dst+1], buf[dst+0], 0xFF
+	if cfg.cursor {
+		drawCursor(hdcMem, window.Min.X, window.Min.Y)
+		win32.GdiFlush()
+	}
+	for row := 0; row < img.Rect.Dy(); row++ {
+		s := buf[((off.Y+row)*w+off.X)*4:][:img.Stride]
+		swizzleBGRA(img.Pix[row*img.Stride:][:img.Stride], s)
 	}
 	return img, nil
 }
 
-// ScreenShot captures a screenshot of the specified area (x, y, width, height).
-func ScreenShot(x, y, width, height int) (*image.RGBA, error) {
-	if !OnScreen(x, y) || !OnScreen(x+width-1, y+height-1) {
-		return nil, errors.New("coordinates are off-screen")
+// isBlankBGRA reports whether every pixel of r in a BGRX buffer with the given width is
+// black, which is what PrintWindow and WM_PRINT leave behind when the window did not draw.
+func isBlankBGRA(buf []byte, width int, r image.Rectangle) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := buf[(y*width+r.Min.X)*4 : (y*width+r.Max.X)*4]
+		for i := 0; i < len(row); i += 4 {
+			if row[i]|row[i+1]|row[i+2] != 0 {
+				return false
+			}
+		}
 	}
-	return Capture(x, y, width, height)
+	return true
+}
+
+// ScreenShot captures a screenshot of the specified area (x, y, width, height).
+// The area may span any of the monitors; it is validated like Capture.
+func ScreenShot(x, y, width, height int, opts ...CaptureOption) (*image.RGBA, error) {
+	return Capture(x, y, width, height, opts...)
 }
 
 // CaptureRect captures specified region of desktop.
-func CaptureRect(rect image.Rectangle) (*image.RGBA, error) {
-	return Capture(rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
+func CaptureRect(rect image.Rectangle, opts ...CaptureOption) (*image.RGBA, error) {
+	return Capture(rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), opts...)
 }
 
 // CaptureDisplay captures the screenshot of the specified display index.
-func CaptureDisplay(displayIndex int) (*image.RGBA, error) {
+func CaptureDisplay(displayIndex int, opts ...CaptureOption) (*image.RGBA, error) {
 	rect := GetDisplayBounds(displayIndex)
-	return CaptureRect(rect)
+	return CaptureRect(rect, opts...)
 }
 
 // CapturePrimaryDisplay captures the screenshot of the primary display.
-func CapturePrimaryDisplay() (*image.RGBA, error) {
+func CapturePrimaryDisplay(opts ...CaptureOption) (*image.RGBA, error) {
 	screen := GetScreenDimensions()
-	return Capture(0, 0, screen.X, screen.Y)
+	return Capture(0, 0, screen.X, screen.Y, opts...)
 }
@@ -0,0 +1,272 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordingFormat selects the container a ScreenRecorder writes.
+type RecordingFormat int
+
+const (
+	RecordingGIF   RecordingFormat = iota // RecordingGIF is an animated GIF with a palette quantised from the frames
+	RecordingAPNG                         // RecordingAPNG is a lossless animated PNG
+	RecordingMJPEG                        // RecordingMJPEG is Motion JPEG in an AVI container
+)
+
+// String method for better printing
+func (f RecordingFormat) String() string {
+	switch f {
+	case RecordingGIF:
+		return "GIF"
+	case RecordingAPNG:
+		return "APNG"
+	case RecordingMJPEG:
+		return "MJPEG"
+	default:
+		return "Unknown Format"
+	}
+}
+
+// recordedFrame is a captured frame and the time it was captured.
+type recordedFrame struct {
+	img *image.RGBA
+	at  time.Time
+}
+
+// ScreenRecorderOption configures a ScreenRecorder.
+type ScreenRecorderOption func(*ScreenRecorder)
+
+// WithKeepLast makes the recorder keep only the frames captured in the last d, so it can run
+// for the whole automation and a clip is only written when a step fails. Frame buffers of
+// dropped frames are reused. By default every frame is kept.
+func WithKeepLast(d time.Duration) ScreenRecorderOption {
+	return func(r *ScreenRecorder) {
+		r.keep = d
+	}
+}
+
+// WithCursorMarker marks the cursor position on each frame with a red crosshair, which stays
+// visible after GIF dithering. Like captures, which include the cursor only with WithCursor,
+// frames have no cursor by default.
+func WithCursorMarker() ScreenRecorderOption {
+	return func(r *ScreenRecorder) {
+		r.drawCursor = true
+	}
+}
+
+// ScreenRecorder captures a region of the screen at a target frame rate into an in-memory
+// buffer and writes the frames as an animated GIF, APNG or MJPEG AVI. Frames are kept
+// uncompressed (4 bytes per pixel), so size the region, frame rate and WithKeepLast window
+// accordingly.
+type ScreenRecorder struct {
+	rect       image.Rectangle
+	fps        int
+	keep       time.Duration
+	drawCursor bool
+
+	mu     sync.Mutex
+	frames []recordedFrame
+	free   []*image.RGBA // buffers of dropped frames, reused for new ones
+	stop   chan struct{}
+	done   chan error
+}
+
+// NewScreenRecorder creates a recorder for rect (in screen coordinates) at fps frames per
+// second. Call Start to begin capturing.
+func NewScreenRecorder(rect image.Rectangle, fps int, opts ...ScreenRecorderOption) (*ScreenRecorder, error) {
+	if rect.Empty() {
+		return nil, errors.New("recording region is empty")
+	}
+	if fps <= 0 {
+		return nil, fmt.Errorf("invalid frame rate %d", fps)
+	}
+	r := &ScreenRecorder{rect: rect, fps: fps}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// NewDisplayRecorder creates a recorder for the display at the specified index.
+func NewDisplayRecorder(displayIndex, fps int, opts ...ScreenRecorderOption) (*ScreenRecorder, error) {
+	return NewScreenRecorder(GetDisplayBounds(displayIndex), fps, opts...)
+}
+
+// Start begins capturing frames in the background.
+func (r *ScreenRecorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return errors.New("recorder is already running")
+	}
+	capturer, err := NewCapturer()
+	if err != nil {
+		return err
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan error, 1)
+	go r.run(capturer, r.stop, r.done)
+	return nil
+}
+
+// Stop stops capturing. The recorded frames are kept and can still be saved.
+// It returns the first capture error, if any.
+func (r *ScreenRecorder) Stop() error {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	return <-done
+}
+
+// Reset discards all recorded frames.
+func (r *ScreenRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.frames {
+		r.free = append(r.free, f.img)
+	}
+	r.frames = nil
+}
+
+func (r *ScreenRecorder) run(capturer *Capturer, stop <-chan struct{}, done chan<- error) {
+	defer capturer.Close()
+	ticker := time.NewTicker(time.Second / time.Duration(r.fps))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			done <- nil
+			return
+		case now := <-ticker.C:
+			if err := r.captureFrame(capturer, now); err != nil {
+				done <- err
+				return
+			}
+		}
+	}
+}
+
+func (r *ScreenRecorder) captureFrame(capturer *Capturer, now time.Time) error {
+	r.mu.Lock()
+	// Drop frames that fell out of the rolling window and recycle their buffers
+	if r.keep > 0 {
+		n := 0
+		for n < len(r.frames) && now.Sub(r.frames[n].at) > r.keep {
+			r.free = append(r.free, r.frames[n].img)
+			n++
+		}
+		r.frames = append(r.frames[:0], r.frames[n:]...)
+	}
+	var img *image.RGBA
+	if len(r.free) > 0 {
+		img = r.free[len(r.free)-1]
+		r.free = r.free[:len(r.free)-1]
+	}
+	r.mu.Unlock()
+
+	if img == nil {
+		img = image.NewRGBA(image.Rect(0, 0, r.rect.Dx(), r.rect.Dy()))
+	}
+	if err := capturer.CaptureInto(img, r.rect.Min.X, r.rect.Min.Y); err != nil {
+		return err
+	}
+	if r.drawCursor {
+		p := Position()
+		DrawMarker(img, image.Pt(p.X, p.Y).Sub(r.rect.Min), MarkerCrosshair, 8, color.RGBA{R: 0xFF, A: 0xFF})
+	}
+
+	r.mu.Lock()
+	r.frames = append(r.frames, recordedFrame{img: img, at: now})
+	r.mu.Unlock()
+	return nil
+}
+
+// snapshot returns copies of the recorded frames, so they can be encoded while recording continues.
+func (r *ScreenRecorder) snapshot() []recordedFrame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	frames := make([]recordedFrame, len(r.frames))
+	for i, f := range r.frames {
+		img := image.NewRGBA(f.img.Rect)
+		copy(img.Pix, f.img.Pix)
+		frames[i] = recordedFrame{img: img, at: f.at}
+	}
+	return frames
+}
+
+// frameDelays returns how long each frame is shown, from the capture timestamps.
+// The last frame is shown for one frame interval.
+func (r *ScreenRecorder) frameDelays(frames []recordedFrame) []time.Duration {
+	delays := make([]time.Duration, len(frames))
+	for i := range frames {
+		if i+1 < len(frames) {
+			delays[i] = frames[i+1].at.Sub(frames[i].at)
+		} else {
+			delays[i] = time.Second / time.Duration(r.fps)
+		}
+	}
+	return delays
+}
+
+// Encode writes the recorded frames to w in the given format. It can be called while the
+// recorder is running; the frames captured so far are written.
+func (r *ScreenRecorder) Encode(w io.Writer, format RecordingFormat) error {
+	frames := r.snapshot()
+	if len(frames) == 0 {
+		return errors.New("no frames recorded")
+	}
+	delays := r.frameDelays(frames)
+	switch format {
+	case RecordingGIF:
+		return encodeGIF(w, frames, delays)
+	case RecordingAPNG:
+		return encodeAPNG(w, frames, delays)
+	case RecordingMJPEG:
+		return encodeMJPEG(w, frames, r.fps)
+	default:
+		return fmt.Errorf("unsupported recording format %v", format)
+	}
+}
+
+// Save writes the recorded frames to path, choosing the format from the file extension:
+// .gif, .png/.apng or .avi.
+func (r *ScreenRecorder) Save(path string) error {
+	var format RecordingFormat
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".gif":
+		format = RecordingGIF
+	case ".png", ".apng":
+		format = RecordingAPNG
+	case ".avi":
+		format = RecordingMJPEG
+	default:
+		return fmt.Errorf("unsupported recording format %q", ext)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.Encode(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,272 @@
+//go:build windows
+
+package windows
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"time"
+)
+
+// encodeGIF writes frames as an animated GIF that loops forever. All frames share one
+// palette, built from the most frequent colours of the recording, and are dithered to it.
+func encodeGIF(w io.Writer, frames []recordedFrame, delays []time.Duration) error {
+	pal := quantize(frames, 256)
+	anim := &gif.GIF{LoopCount: 0}
+	for i, f := range frames {
+		p := image.NewPaletted(f.img.Rect, pal)
+		draw.FloydSteinberg.Draw(p, p.Rect, f.img, f.img.Rect.Min)
+		anim.Image = append(anim.Image, p)
+		// GIF delays are in hundredths of a second; most viewers treat less than 2 as 10
+		anim.Delay = append(anim.Delay, max(2, int(delays[i]/(10*time.Millisecond))))
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// quantize builds a palette of up to size colours for frames using a popularity algorithm
+// on a 5-bit-per-channel histogram. Screen content has few distinct colours, so this keeps
+// UI colours exact where a fixed palette would shift them. Large recordings are sampled.
+func quantize(frames []recordedFrame, size int) color.Palette {
+	type bucket struct {
+		count   int
+		r, g, b int // channel sums, averaged for the palette entry
+	}
+	buckets := make([]bucket, 1<<15)
+
+	// Sample about a million pixels across the recording
+	total := 0
+	for _, f := range frames {
+		total += len(f.img.Pix) / 4
+	}
+	step := max(1, total/(1<<20))
+
+	for _, f := range frames {
+		pix := f.img.Pix
+		for i := 0; i < len(pix); i += 4 * step {
+			r, g, b := int(pix[i]), int(pix[i+1]), int(pix[i+2])
+			k := (r>>3)<<10 | (g>>3)<<5 | b>>3
+			bk := &buckets[k]
+			bk.count++
+			bk.r += r
+			bk.g += g
+			bk.b += b
+		}
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].count > buckets[j].count })
+	pal := make(color.Palette, 0, size)
+	for _, bk := range buckets {
+		if bk.count == 0 || len(pal) == size {
+			break
+		}
+		pal = append(pal, color.RGBA{
+			R: uint8(bk.r / bk.count),
+			G: uint8(bk.g / bk.count),
+			B: uint8(bk.b / bk.count),
+			A: 0xFF,
+		})
+	}
+	if len(pal) == 0 {
+		pal = append(pal, color.Black)
+	}
+	return pal
+}
+
+// encodeAPNG writes frames as an animated PNG that loops forever. Each frame is encoded
+// with image/png and its image data is moved into the APNG frame chunks.
+func encodeAPNG(w io.Writer, frames []recordedFrame, delays []time.Duration) error {
+	var out bytes.Buffer
+	out.WriteString("\x89PNG\r\n\x1a\n")
+
+	var ihdr []byte
+	seq := uint32(0)
+	for i, f := range frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, f.img); err != nil {
+			return err
+		}
+		hdr, idat, err := splitPNG(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			ihdr = hdr
+			writePNGChunk(&out, "IHDR", ihdr)
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:4], uint32(len(frames)))
+			binary.BigEndian.PutUint32(actl[4:8], 0) // loop forever
+			writePNGChunk(&out, "acTL", actl)
+		} else if !bytes.Equal(hdr, ihdr) {
+			// All frames must share the size and pixel format of the first
+			return fmt.Errorf("frame %d has a different PNG header than the first frame", i)
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], seq)
+		binary.BigEndian.PutUint32(fctl[4:8], uint32(f.img.Rect.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:12], uint32(f.img.Rect.Dy()))
+		// x and y offsets (12:20) are 0
+		binary.BigEndian.PutUint16(fctl[20:22], uint16(min(delays[i].Milliseconds(), 0xFFFF)))
+		binary.BigEndian.PutUint16(fctl[22:24], 1000) // delay is in milliseconds
+		// dispose_op and blend_op (24, 25) are 0: none and source
+		writePNGChunk(&out, "fcTL", fctl)
+		seq++
+
+		if i == 0 {
+			writePNGChunk(&out, "IDAT", idat)
+		} else {
+			fdat := make([]byte, 4+len(idat))
+			binary.BigEndian.PutUint32(fdat[0:4], seq)
+			copy(fdat[4:], idat)
+			writePNGChunk(&out, "fdAT", fdat)
+			seq++
+		}
+	}
+	writePNGChunk(&out, "IEND", nil)
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// splitPNG returns the IHDR data and the concatenated IDAT data of an encoded PNG.
+func splitPNG(data []byte) (ihdr, idat []byte, err error) {
+	if len(data) < 8 {
+		return nil, nil, errors.New("invalid PNG data")
+	}
+	for p := 8; p+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[p : p+4]))
+		typ := string(data[p+4 : p+8])
+		if p+12+length > len(data) {
+			return nil, nil, errors.New("truncated PNG chunk")
+		}
+		body := data[p+8 : p+8+length]
+		switch typ {
+		case "IHDR":
+			ihdr = body
+		case "IDAT":
+			idat = append(idat, body...)
+		}
+		p += 12 + length
+	}
+	if ihdr == nil || idat == nil {
+		return nil, nil, errors.New("PNG data has no IHDR or IDAT chunk")
+	}
+	return ihdr, idat, nil
+}
+
+// encodeMJPEG writes frames as Motion JPEG in an AVI (RIFF) container at a constant fps.
+func encodeMJPEG(w io.Writer, frames []recordedFrame, fps int) error {
+	width, height := frames[0].img.Rect.Dx(), frames[0].img.Rect.Dy()
+
+	// movi list: one '00dc' chunk per JPEG frame, and the matching idx1 entries
+	var movi, index bytes.Buffer
+	maxFrame := 0
+	for _, f := range frames {
+		var jpg bytes.Buffer
+		if err := jpeg.Encode(&jpg, f.img, &jpeg.Options{Quality: 85}); err != nil {
+			return err
+		}
+		offset := 4 + movi.Len() // relative to the 'movi' list type
+		writeRIFFChunk(&movi, "00dc", jpg.Bytes())
+		maxFrame = max(maxFrame, jpg.Len())
+
+		index.WriteString("00dc")
+		binary.Write(&index, binary.LittleEndian, []uint32{
+			0x10, // AVIIF_KEYFRAME
+			uint32(offset),
+			uint32(jpg.Len()),
+		})
+	}
+
+	le := func(b *bytes.Buffer, v ...any) {
+		for _, x := range v {
+			binary.Write(b, binary.LittleEndian, x)
+		}
+	}
+
+	var avih bytes.Buffer // MainAVIHeader
+	le(&avih,
+		uint32(time.Second/time.Microsecond)/uint32(fps), // dwMicroSecPerFrame
+		uint32(maxFrame*fps),                             // dwMaxBytesPerSec
+		uint32(0),                                        // dwPaddingGranularity
+		uint32(0x10),                                     // dwFlags: AVIF_HASINDEX
+		uint32(len(frames)),                              // dwTotalFrames
+		uint32(0),                                        // dwInitialFrames
+		uint32(1),                                        // dwStreams
+		uint32(maxFrame),                                 // dwSuggestedBufferSize
+		uint32(width), uint32(height),
+		[4]uint32{}, // dwReserved
+	)
+
+	var strh bytes.Buffer // AVIStreamHeader
+	strh.WriteString("vidsMJPG")
+	le(&strh,
+		uint32(0),           // dwFlags
+		uint16(0),           // wPriority
+		uint16(0),           // wLanguage
+		uint32(0),           // dwInitialFrames
+		uint32(1),           // dwScale
+		uint32(fps),         // dwRate: frames per second = dwRate / dwScale
+		uint32(0),           // dwStart
+		uint32(len(frames)), // dwLength
+		uint32(maxFrame),    // dwSuggestedBufferSize
+		^uint32(0),          // dwQuality: default
+		uint32(0),           // dwSampleSize: varies per frame
+		[4]int16{0, 0, int16(width), int16(height)}, // rcFrame
+	)
+
+	var strf bytes.Buffer // BITMAPINFOHEADER
+	le(&strf, uint32(40), int32(width), int32(height), uint16(1), uint16(24))
+	strf.WriteString("MJPG")
+	le(&strf, uint32(width*height*3), int32(0), int32(0), uint32(0), uint32(0))
+
+	var strl bytes.Buffer
+	writeRIFFChunk(&strl, "strh", strh.Bytes())
+	writeRIFFChunk(&strl, "strf", strf.Bytes())
+
+	var hdrl bytes.Buffer
+	writeRIFFChunk(&hdrl, "avih", avih.Bytes())
+	writeRIFFList(&hdrl, "strl", strl.Bytes())
+
+	var body bytes.Buffer
+	body.WriteString("AVI ")
+	writeRIFFList(&body, "hdrl", hdrl.Bytes())
+	writeRIFFList(&body, "movi", movi.Bytes())
+	writeRIFFChunk(&body, "idx1", index.Bytes())
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	le(&out, uint32(body.Len()))
+	out.Write(body.Bytes())
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// writeRIFFChunk writes a RIFF chunk, padded to an even size.
+func writeRIFFChunk(b *bytes.Buffer, id string, data []byte) {
+	b.WriteString(id)
+	binary.Write(b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	if len(data)%2 == 1 {
+		b.WriteByte(0)
+	}
+}
+
+// writeRIFFList writes a RIFF LIST of the given type containing data.
+func writeRIFFList(b *bytes.Buffer, listType string, data []byte) {
+	b.WriteString("LIST")
+	binary.Write(b, binary.LittleEndian, uint32(4+len(data)))
+	b.WriteString(listType)
+	b.Write(data)
+}
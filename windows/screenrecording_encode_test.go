@@ -0,0 +1,190 @@
+//go:build windows
+
+package windows
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testFrames returns n 16x8 frames, each filled with its own colour, 100ms apart.
+func testFrames(n int) ([]recordedFrame, []time.Duration) {
+	colours := []color.RGBA{{R: 0xFF, A: 0xFF}, {G: 0xFF, A: 0xFF}, {B: 0xFF, A: 0xFF}}
+	start := time.Now()
+	frames := make([]recordedFrame, n)
+	delays := make([]time.Duration, n)
+	for i := range frames {
+		img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+		draw.Draw(img, img.Rect, image.NewUniform(colours[i%len(colours)]), image.Point{}, draw.Src)
+		frames[i] = recordedFrame{img: img, at: start.Add(time.Duration(i) * 100 * time.Millisecond)}
+		delays[i] = 100 * time.Millisecond
+	}
+	return frames, delays
+}
+
+// near reports whether c is within 8 of want on each channel, allowing for lossy encoding.
+func near(c color.Color, want color.RGBA) bool {
+	got := color.RGBAModel.Convert(c).(color.RGBA)
+	d := func(a, b uint8) bool { return max(a, b)-min(a, b) <= 8 }
+	return d(got.R, want.R) && d(got.G, want.G) && d(got.B, want.B)
+}
+
+func TestEncodeGIF(t *testing.T) {
+	frames, delays := testFrames(3)
+	var buf bytes.Buffer
+	if err := encodeGIF(&buf, frames, delays); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 || anim.LoopCount != 0 || !slices.Equal(anim.Delay, []int{10, 10, 10}) {
+		t.Fatalf("GIF has %d frames, loop count %d, delays %v; want 3 looping frames of 10", len(anim.Image), anim.LoopCount, anim.Delay)
+	}
+	for i, img := range anim.Image {
+		if want := frames[i].img.RGBAAt(4, 4); !near(img.At(4, 4), want) {
+			t.Errorf("frame %d is %v, want %v", i, img.At(4, 4), want)
+		}
+	}
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	frames, delays := testFrames(3)
+	var buf bytes.Buffer
+	if err := encodeAPNG(&buf, frames, delays); err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoders without APNG support show the first frame
+	first, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := frames[0].img.RGBAAt(0, 0); color.RGBAModel.Convert(first.At(0, 0)) != want {
+		t.Errorf("default image is %v, want %v", first.At(0, 0), want)
+	}
+
+	var types []string
+	var seqs []uint32
+	for _, c := range readPNGChunks(t, buf.Bytes()) {
+		types = append(types, c.typ)
+		switch c.typ {
+		case "acTL":
+			if n := binary.BigEndian.Uint32(c.data[0:4]); n != 3 {
+				t.Errorf("acTL frame count %d, want 3", n)
+			}
+		case "fcTL":
+			seqs = append(seqs, binary.BigEndian.Uint32(c.data[0:4]))
+			w, h := binary.BigEndian.Uint32(c.data[4:8]), binary.BigEndian.Uint32(c.data[8:12])
+			num, den := binary.BigEndian.Uint16(c.data[20:22]), binary.BigEndian.Uint16(c.data[22:24])
+			if w != 16 || h != 8 || num != 100 || den != 1000 {
+				t.Errorf("fcTL %dx%d delay %d/%d, want 16x8 delay 100/1000", w, h, num, den)
+			}
+		case "fdAT":
+			seqs = append(seqs, binary.BigEndian.Uint32(c.data[0:4]))
+		}
+	}
+	want := []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}
+	if !slices.Equal(types, want) {
+		t.Errorf("chunks %q, want %q", types, want)
+	}
+	// fcTL and fdAT chunks share one sequence
+	if !slices.Equal(seqs, []uint32{0, 1, 2, 3, 4}) {
+		t.Errorf("sequence numbers %v, want 0 to 4", seqs)
+	}
+}
+
+// riffChunk is a chunk of a RIFF file; lists keep their type as the first 4 bytes of data.
+type riffChunk struct {
+	id   string
+	data []byte
+}
+
+// readRIFFChunks splits data into RIFF chunks, skipping padding bytes.
+func readRIFFChunks(t *testing.T, data []byte) []riffChunk {
+	t.Helper()
+	var chunks []riffChunk
+	for len(data) > 0 {
+		if len(data) < 8 {
+			t.Fatalf("truncated RIFF chunk after %d chunks", len(chunks))
+		}
+		n := int(binary.LittleEndian.Uint32(data[4:8]))
+		if len(data) < 8+n {
+			t.Fatalf("RIFF chunk %q is longer than its parent", data[:4])
+		}
+		chunks = append(chunks, riffChunk{string(data[:4]), data[8 : 8+n]})
+		data = data[min(len(data), 8+n+n%2):]
+	}
+	return chunks
+}
+
+func TestEncodeMJPEG(t *testing.T) {
+	frames, _ := testFrames(3)
+	var buf bytes.Buffer
+	if err := encodeMJPEG(&buf, frames, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	riff := readRIFFChunks(t, buf.Bytes())
+	if len(riff) != 1 || riff[0].id != "RIFF" || string(riff[0].data[:4]) != "AVI " {
+		t.Fatalf("not a RIFF AVI file")
+	}
+	top := readRIFFChunks(t, riff[0].data[4:])
+	var ids []string
+	for _, c := range top {
+		id := c.id
+		if id == "LIST" {
+			id += " " + string(c.data[:4])
+		}
+		ids = append(ids, id)
+	}
+	if !slices.Equal(ids, []string{"LIST hdrl", "LIST movi", "idx1"}) {
+		t.Fatalf("AVI chunks %q, want hdrl, movi and idx1", ids)
+	}
+
+	hdrl := readRIFFChunks(t, top[0].data[4:])
+	avih := hdrl[0].data
+	if hdrl[0].id != "avih" || len(avih) != 56 {
+		t.Fatalf("first hdrl chunk %q of %d bytes, want a 56 byte avih", hdrl[0].id, len(avih))
+	}
+	le := binary.LittleEndian.Uint32
+	if usPerFrame, total, w, h := le(avih[0:]), le(avih[16:]), le(avih[32:]), le(avih[36:]); usPerFrame != 100000 || total != 3 || w != 16 || h != 8 {
+		t.Errorf("avih: %dµs per frame, %d frames, %dx%d; want 100000µs, 3 frames, 16x8", usPerFrame, total, w, h)
+	}
+
+	// Every frame is a JPEG of its colour, and idx1 points at it
+	movi := top[1].data
+	idx := top[2].data
+	frameChunks := readRIFFChunks(t, movi[4:])
+	if len(frameChunks) != 3 || len(idx) != 3*16 {
+		t.Fatalf("%d frame chunks and %d index bytes, want 3 frames", len(frameChunks), len(idx))
+	}
+	for i, c := range frameChunks {
+		if c.id != "00dc" {
+			t.Errorf("frame %d chunk %q, want 00dc", i, c.id)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(c.data))
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if want := frames[i].img.RGBAAt(8, 4); !near(img.At(8, 4), want) {
+			t.Errorf("frame %d is %v, want %v", i, img.At(8, 4), want)
+		}
+
+		entry := idx[i*16:]
+		offset, size := le(entry[8:]), le(entry[12:])
+		if string(entry[:4]) != "00dc" || int(size) != len(c.data) || !bytes.Equal(movi[offset+8:offset+8+size], c.data) {
+			t.Errorf("idx1 entry %d (offset %d, size %d) does not point at frame %d", i, offset, size, i)
+		}
+	}
+}
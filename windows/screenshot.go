@@ -0,0 +1,168 @@
+//go:build windows
+
+package windows
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zzl/go-win32api/v2/win32"
+	"golang.org/x/image/bmp"
+)
+
+// SaveOption configures how SaveScreenshot encodes an image.
+type SaveOption func(*saveConfig)
+
+type saveConfig struct {
+	jpegQuality int
+	captureRect image.Rectangle // screen area the image was captured from, if known
+	metadata    bool
+}
+
+// WithJPEGQuality sets the JPEG quality, from 1 to 100. The default is 90.
+func WithJPEGQuality(quality int) SaveOption {
+	return func(c *saveConfig) {
+		c.jpegQuality = quality
+	}
+}
+
+// WithCaptureRect records the screen area the image was captured from in the PNG metadata.
+// Without it the image bounds are recorded.
+func WithCaptureRect(rect image.Rectangle) SaveOption {
+	return func(c *saveConfig) {
+		c.captureRect = rect
+	}
+}
+
+// WithoutMetadata disables the tEXt and pHYs chunks SaveScreenshot adds to PNG files.
+func WithoutMetadata() SaveOption {
+	return func(c *saveConfig) {
+		c.metadata = false
+	}
+}
+
+// SaveScreenshot writes img to path, choosing the encoder from the file extension:
+// .png, .jpg/.jpeg, .bmp or .gif. PNG files get tEXt metadata with the capture time,
+// monitor layout, capture rectangle and DPI, and a pHYs chunk with the DPI.
+func SaveScreenshot(path string, img image.Image, opts ...SaveOption) error {
+	cfg := saveConfig{jpegQuality: 90, captureRect: img.Bounds(), metadata: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".png":
+		err = png.Encode(&buf, img)
+		if err == nil && cfg.metadata {
+			err = addPNGMetadata(&buf, screenshotMetadata(cfg.captureRect), win32.GetDpiForSystem())
+		}
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: cfg.jpegQuality})
+	case ".bmp":
+		err = bmp.Encode(&buf, img)
+	case ".gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return fmt.Errorf("unsupported image format %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// Screenshot captures the region of the screen and saves it to path, like pyautogui's
+// screenshot(imageFilename=..., region=...). An empty region captures the primary display.
+// The captured image is returned as well.
+func Screenshot(region image.Rectangle, path string, opts ...SaveOption) (*image.RGBA, error) {
+	if region.Empty() {
+		screen := GetScreenDimensions()
+		region = image.Rect(0, 0, screen.X, screen.Y)
+	}
+	img, err := CaptureRect(region)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]SaveOption{WithCaptureRect(region)}, opts...)
+	if err := SaveScreenshot(path, img, opts...); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// screenshotMetadata returns the tEXt entries describing a capture of rect.
+func screenshotMetadata(rect image.Rectangle) [][2]string {
+	var layout []string
+	for i, r := range GetAllDisplayBounds() {
+		layout = append(layout, fmt.Sprintf("%d:%d,%d,%d,%d", i, r.Min.X, r.Min.Y, r.Max.X, r.Max.Y))
+	}
+	return [][2]string{
+		{"Creation Time", time.Now().Format(time.RFC3339)},
+		{"Software", "goautogui"},
+		{"Monitor Layout", strings.Join(layout, ";")},
+		{"Capture Rect", fmt.Sprintf("%d,%d,%d,%d", rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y)},
+		{"DPI", fmt.Sprint(win32.GetDpiForSystem())},
+	}
+}
+
+// addPNGMetadata inserts a pHYs chunk for dpi and a tEXt chunk for each keyword/value pair
+// into the encoded PNG in buf, right after the IHDR chunk.
+func addPNGMetadata(buf *bytes.Buffer, text [][2]string, dpi uint32) error {
+	data := buf.Bytes()
+	// 8-byte signature, then IHDR: 4 length + 4 type + 13 data + 4 CRC
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+		return fmt.Errorf("unexpected PNG layout")
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:ihdrEnd])
+
+	if dpi > 0 {
+		// pHYs stores pixels per metre; 1 inch = 0.0254 m
+		ppm := uint32(float64(dpi)/0.0254 + 0.5)
+		phys := make([]byte, 9)
+		binary.BigEndian.PutUint32(phys[0:4], ppm)
+		binary.BigEndian.PutUint32(phys[4:8], ppm)
+		phys[8] = 1 // unit is the metre
+		writePNGChunk(&out, "pHYs", phys)
+	}
+	for _, kv := range text {
+		// tEXt: keyword, null separator, Latin-1 text
+		writePNGChunk(&out, "tEXt", []byte(kv[0]+"\x00"+kv[1]))
+	}
+
+	out.Write(data[ihdrEnd:])
+	buf.Reset()
+	_, err := io.Copy(buf, &out)
+	return err
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[0:4], uint32(len(data)))
+	copy(hdr[4:8], typ)
+	w.Write(hdr[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:8])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
@@ -0,0 +1,103 @@
+//go:build windows
+
+package windows
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// readPNGChunks splits an encoded PNG into its chunks, failing t on a bad signature or CRC.
+func readPNGChunks(t *testing.T, data []byte) []pngChunk {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Fatal("missing PNG signature")
+	}
+	var chunks []pngChunk
+	for rest := data[8:]; len(rest) > 0; {
+		if len(rest) < 12 {
+			t.Fatalf("truncated chunk after %d chunks", len(chunks))
+		}
+		n := binary.BigEndian.Uint32(rest[:4])
+		if uint64(len(rest)) < 12+uint64(n) {
+			t.Fatalf("chunk %q is longer than the file", rest[4:8])
+		}
+		c := pngChunk{string(rest[4:8]), rest[8 : 8+n]}
+		if got, want := binary.BigEndian.Uint32(rest[8+n:12+n]), crc32.ChecksumIEEE(rest[4:8+n]); got != want {
+			t.Errorf("chunk %s: CRC %#x, want %#x", c.typ, got, want)
+		}
+		chunks = append(chunks, c)
+		rest = rest[12+n:]
+	}
+	return chunks
+}
+
+func TestAddPNGMetadata(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.RGBA{R: 0xFF, A: 0xFF})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	text := [][2]string{{"Software", "goautogui"}, {"Capture Rect", "0,0,3,2"}}
+	if err := addPNGMetadata(&buf, text, 96); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := readPNGChunks(t, buf.Bytes())
+	var types []string
+	for _, c := range chunks {
+		types = append(types, c.typ)
+	}
+	// The metadata goes right after IHDR, before the image data
+	if len(types) < 5 || !slices.Equal(types[:4], []string{"IHDR", "pHYs", "tEXt", "tEXt"}) || types[4] != "IDAT" {
+		t.Fatalf("chunks %q, want IHDR, pHYs, two tEXt, then IDAT", types)
+	}
+
+	// 96 dpi is 3780 pixels per metre
+	phys := chunks[1].data
+	if len(phys) != 9 || binary.BigEndian.Uint32(phys[0:4]) != 3780 || binary.BigEndian.Uint32(phys[4:8]) != 3780 || phys[8] != 1 {
+		t.Errorf("pHYs = %v, want 3780 pixels per metre on both axes", phys)
+	}
+	for i, kv := range text {
+		if got, want := string(chunks[2+i].data), kv[0]+"\x00"+kv[1]; got != want {
+			t.Errorf("tEXt %d = %q, want %q", i, got, want)
+		}
+	}
+
+	// The pixels are unchanged
+	got, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Bounds() != img.Bounds() || color.RGBAModel.Convert(got.At(1, 1)) != img.At(1, 1) {
+		t.Errorf("decoded image %v does not match the original", got.Bounds())
+	}
+}
+
+func TestAddPNGMetadataWithoutDPI(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err := addPNGMetadata(&buf, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range readPNGChunks(t, buf.Bytes()) {
+		if c.typ == "pHYs" || c.typ == "tEXt" {
+			t.Errorf("unexpected %s chunk", c.typ)
+		}
+	}
+	if err := addPNGMetadata(bytes.NewBufferString("not a PNG"), nil, 96); err == nil {
+		t.Error("addPNGMetadata accepted data that is not a PNG")
+	}
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package windows
+
+// TweenFunc maps the elapsed fraction t of a movement (0 to 1) to the fraction of the
+// distance covered at that time. It lets movements accelerate or decelerate instead of
+// moving at a constant speed, like the pytweening functions used by pyautogui.
+type TweenFunc func(t float64) float64
+
+// Linear moves at a constant speed.
+func Linear(t float64) float64 {
+	return t
+}
+
+// EaseInQuad starts slowly and accelerates.
+func EaseInQuad(t float64) float64 {
+	return t * t
+}
+
+// EaseOutQuad starts quickly and decelerates.
+func EaseOutQuad(t float64) float64 {
+	return -t * (t - 2)
+}
+
+// EaseInOutQuad accelerates through the first half and decelerates through the second.
+func EaseInOutQuad(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	t = t*2 - 1
+	return -0.5 * (t*(t-2) - 1)
+}
+
+// EaseInCubic starts slowly and accelerates, more sharply than EaseInQuad.
+func EaseInCubic(t float64) float64 {
+	return t * t * t
+}
+
+// EaseOutCubic starts quickly and decelerates, more sharply than EaseOutQuad.
+func EaseOutCubic(t float64) float64 {
+	t--
+	return t*t*t + 1
+}
+
+// EaseInOutCubic accelerates through the first half and decelerates through the second,
+// more sharply than EaseInOutQuad.
+func EaseInOutCubic(t float64) float64 {
+	t *= 2
+	if t < 1 {
+		return 0.5 * t * t * t
+	}
+	t -= 2
+	return 0.5 * (t*t*t + 2)
+}
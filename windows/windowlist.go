@@ -0,0 +1,155 @@
+//go:build windows
+
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/zzl/go-win32api/v2/win32"
+)
+
+// ErrWindowNotFound is returned when no window matches a title.
+var ErrWindowNotFound = errors.New("window not found")
+
+// Window describes a top-level window.
+type Window struct {
+	Hwnd       win32.HWND
+	Title      string
+	Class      string
+	PID        uint32
+	Bounds     image.Rectangle // without the DWM shadow where available
+	Minimized  bool
+	Foreground bool
+}
+
+// windowEnum collects window handles during EnumWindows, like monitorEnum.
+var windowEnum struct {
+	sync.Mutex
+	once  sync.Once
+	proc  uintptr
+	hwnds []win32.HWND
+}
+
+// DWMWA_CLOAKED
+const dwmwaCloaked = 14
+
+// cloaked reports whether DWM hides hwnd, as it does with windows of other virtual
+// desktops and suspended UWP apps, which are "visible" but not on screen.
+func cloaked(hwnd win32.HWND) bool {
+	if procDwmGetWindowAttribute.Find() != nil {
+		return false
+	}
+	var v uint32
+	hr, _, _ := procDwmGetWindowAttribute.Call(uintptr(hwnd), dwmwaCloaked, uintptr(unsafe.Pointer(&v)), unsafe.Sizeof(v))
+	return hr == 0 && v != 0
+}
+
+// ListWindows returns the visible top-level windows with a title, front to back. Windows
+// cloaked by DWM are left out.
+func ListWindows() []Window {
+	windowEnum.once.Do(func() {
+		windowEnum.proc = syscall.NewCallback(func(hwnd win32.HWND, lParam win32.LPARAM) uintptr {
+			windowEnum.hwnds = append(windowEnum.hwnds, hwnd)
+			return 1 // continue enumeration
+		})
+	})
+
+	windowEnum.Lock()
+	windowEnum.hwnds = nil
+	win32.EnumWindows(windowEnum.proc, 0)
+	hwnds := windowEnum.hwnds
+	windowEnum.Unlock()
+
+	foreground := win32.GetForegroundWindow()
+	var windows []Window
+	for _, hwnd := range hwnds {
+		if win32.IsWindowVisible(hwnd) == 0 || cloaked(hwnd) {
+			continue
+		}
+		title := windowText(hwnd)
+		if title == "" {
+			continue
+		}
+		windows = append(windows, describeWindow(hwnd, title, foreground))
+	}
+	return windows
+}
+
+// FindWindows returns the windows of ListWindows whose title contains title, ignoring case.
+func FindWindows(title string) []Window {
+	title = strings.ToLower(title)
+	var found []Window
+	for _, w := range ListWindows() {
+		if strings.Contains(strings.ToLower(w.Title), title) {
+			found = append(found, w)
+		}
+	}
+	return found
+}
+
+func windowText(hwnd win32.HWND) string {
+	n, _ := win32.GetWindowTextLengthW(hwnd)
+	if n == 0 {
+		return ""
+	}
+	buf := make([]uint16, n+1)
+	n, _ = win32.GetWindowTextW(hwnd, &buf[0], int32(len(buf)))
+	return syscall.UTF16ToString(buf[:n])
+}
+
+func describeWindow(hwnd win32.HWND, title string, foreground win32.HWND) Window {
+	w := Window{Hwnd: hwnd, Title: title, Minimized: win32.IsIconic(hwnd) != 0, Foreground: hwnd == foreground}
+	var class [256]uint16
+	if n, _ := win32.GetClassNameW(hwnd, &class[0], int32(len(class))); n > 0 {
+		w.Class = syscall.UTF16ToString(class[:n])
+	}
+	win32.GetWindowThreadProcessId(hwnd, &w.PID)
+	if bounds, ok := extendedFrameBounds(hwnd); ok {
+		w.Bounds = bounds
+	} else {
+		var rc win32.RECT
+		if ok, _ := win32.GetWindowRect(hwnd, &rc); ok != 0 {
+			w.Bounds = image.Rect(int(rc.Left), int(rc.Top), int(rc.Right), int(rc.Bottom))
+		}
+	}
+	return w
+}
+
+// ActivateWindow restores hwnd if it is minimized and brings it to the foreground.
+//
+// Windows only lets the foreground process, or one that received the last input event,
+// change the foreground window. If SetForegroundWindow is refused, ActivateWindow taps Alt,
+// which counts as such an input event, and tries again. It returns an error if hwnd is
+// still not the foreground window.
+func ActivateWindow(hwnd win32.HWND) error {
+	if err := validateHwnd(hwnd); err != nil {
+		return err
+	}
+	if win32.IsIconic(hwnd) != 0 {
+		win32.ShowWindow(hwnd, win32.SW_RESTORE)
+	}
+	if win32.SetForegroundWindow(hwnd) == 0 {
+		if err := VKeyDown(KEY_MENU); err != nil {
+			return err
+		}
+		if err := VKeyUp(KEY_MENU); err != nil {
+			return err
+		}
+		win32.SetForegroundWindow(hwnd)
+	}
+	// The foreground change is processed asynchronously by the window's thread.
+	for range 10 {
+		if win32.GetForegroundWindow() == hwnd {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("cannot activate window %#x", hwnd)
+}
@@ -3,6 +3,9 @@
 package windows
 
 import (
+	"context"
+	"time"
+
 	"github.com/zzl/go-win32api/v2/win32"
 )
 
@@ -10,3 +13,19 @@ func init() {
 	// Set the DPI awareness context to Per Monitor V2 for true pixel metrics
 	win32.SetProcessDpiAwarenessContext(win32.DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2)
 }
+
+// sleepCtx pauses for d or until ctx is cancelled, whichever comes first.
+// It returns ctx.Err() if the context was cancelled.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}